@@ -0,0 +1,211 @@
+package irc
+
+import (
+	"context"
+	"strings"
+)
+
+// Types of the batches defined by the IRCv3 specifications.
+const (
+	BatchTypeNetsplit        = "netsplit"
+	BatchTypeNetjoin         = "netjoin"
+	BatchTypeChathistory     = "chathistory"
+	BatchTypeLabeledResponse = "labeled-response"
+	BatchTypeMultiline       = "draft/multiline"
+)
+
+// MessageBatch is a group of related messages that has been sent by the server (see the
+// batch capability). Batches may contain further batches.
+type MessageBatch struct {
+	// Ref is the reference tag that has been assigned to the batch by the server.
+	Ref string
+	// Type is the type of the batch (e.g. BatchTypeNetsplit).
+	Type string
+	// Params contains the parameters following the type (e.g. the target of a multiline
+	// batch or the servers affected by a netsplit).
+	Params []string
+	// Tags contains the tags of the BATCH message that has opened the batch (e.g. the
+	// label of a labeled response).
+	Tags Tags
+	// Messages contains the messages of the batch in the order in which they have been
+	// received. The messages of nested batches are not part of it. Batches keep no more
+	// than maxBatchMessages messages, further messages are delivered on their own.
+	Messages []Message
+	// Batches contains the nested batches.
+	Batches []*MessageBatch
+	// Parent is the batch that contains this batch (nil for batches that are not nested).
+	Parent *MessageBatch
+}
+
+// Text reassembles the text of a draft/multiline batch: the texts of the PRIVMSG and
+// NOTICE messages are joined by line breaks, unless a message is marked to be
+// concatenated with the previous one.
+func (b *MessageBatch) Text() string {
+	var sb strings.Builder
+	first := true
+	for _, msg := range b.Messages {
+		if msg.Command() != PrivmsgCommand && msg.Command() != NoticeCommand || len(msg.Parameters()) < 2 {
+			continue
+		}
+		if _, concat := msg.Tags().Get(MultilineConcatMessageTag); !first && !concat {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(msg.Parameters()[1])
+		first = false
+	}
+	return sb.String()
+}
+
+// BatchMessage is the BATCH message that has closed a batch. It is delivered to the
+// handlers and the In() channel along with the completed batch.
+type BatchMessage interface {
+	Message
+	isBatch()
+	// Batch returns the batch that has been closed.
+	Batch() *MessageBatch
+}
+
+type batchMessage struct {
+	message
+	batch *MessageBatch
+}
+
+func (msg *batchMessage) isBatch() {}
+
+func (msg *batchMessage) Batch() *MessageBatch {
+	return msg.batch
+}
+
+// BatchDelivery determines how the messages of batches are delivered to the handlers and
+// the In() channel. The connection's own state tracking always processes all messages as
+// soon as they arrive.
+type BatchDelivery int
+
+const (
+	// BatchDeliveryStreaming delivers the messages of a batch (including the BATCH
+	// messages) as soon as they arrive. The closing BATCH messages of the batch and of
+	// its nested batches are delivered as BatchMessage.
+	BatchDeliveryStreaming BatchDelivery = iota
+	// BatchDeliveryBuffered holds the messages of a batch, including its nested batches,
+	// back until the batch has been completed. The batch is then delivered as a single
+	// BatchMessage.
+	BatchDeliveryBuffered
+)
+
+// WithBatchDelivery configures how batches of the given types (e.g. BatchTypeChathistory)
+// are delivered. If no types are given, the delivery applies to all the batches whose
+// types have not been configured explicitly. Nested batches are delivered like the
+// batch that contains them. Batches are streamed by default. The number of messages a
+// batch holds back is limited, the messages exceeding the limit are delivered right away.
+func WithBatchDelivery(delivery BatchDelivery, types ...string) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		if len(types) == 0 {
+			types = []string{""}
+		}
+		for _, typ := range types {
+			conn.batches.delivery[typ] = delivery
+		}
+	}
+}
+
+// BatchHandlerFunc processes a completed batch.
+type BatchHandlerFunc func(ctx context.Context, batch *MessageBatch)
+
+// HandleBatch registers a handler for completed batches of the given type. If the type
+// is empty, the handler is invoked for all batches. Nested batches that are being
+// streamed (see WithBatchDelivery()) are passed to the handler on their own as well. The
+// returned function removes the handler again.
+func (conn *clientConnection) HandleBatch(batchType string, fn BatchHandlerFunc, opts ...HandlerOption) (remove func()) {
+	return conn.Handle(BatchCommand, func(ctx context.Context, msg Message) {
+		if bm, ok := msg.(BatchMessage); ok && (batchType == "" || bm.Batch().Type == batchType) {
+			fn(ctx, bm.Batch())
+		}
+	}, opts...)
+}
+
+// Limits that protect against servers that open batches without ever closing them.
+const (
+	// maxOpenBatches is the maximum number of batches that are open at the same time.
+	// Further batches are not tracked, their messages are delivered on their own.
+	maxOpenBatches = 64
+	// maxBatchMessages is the maximum number of messages that are kept by a batch.
+	maxBatchMessages = 10000
+)
+
+// batchTracker keeps track of the open batches of a session. It is only used by the
+// goroutine that reads the messages from the server.
+type batchTracker struct {
+	delivery map[string]BatchDelivery // batch type ("" for all other types) -> delivery
+	open     map[string]*MessageBatch // reference tag -> batch
+}
+
+func newBatchTracker() batchTracker {
+	return batchTracker{delivery: make(map[string]BatchDelivery), open: make(map[string]*MessageBatch)}
+}
+
+// reset forgets about all open batches.
+func (t *batchTracker) reset() {
+	t.open = make(map[string]*MessageBatch)
+}
+
+// process adds the given message to the batch it belongs to and returns the message that
+// is to be delivered instead, which is nil if the message is being held back.
+func (t *batchTracker) process(msg Message) Message {
+	parent := t.open[msg.Tags()[BatchMessageTag]]
+	params := msg.Parameters()
+	if msg.Command() == BatchCommand && len(params) > 0 && len(params[0]) > 1 {
+		ref := params[0][1:]
+		switch params[0][0] {
+		case '+':
+			if len(t.open) >= maxOpenBatches {
+				return msg
+			}
+			b := &MessageBatch{Ref: ref, Tags: msg.Tags(), Parent: parent}
+			if len(params) > 1 {
+				b.Type, b.Params = params[1], params[2:]
+			}
+			if parent != nil {
+				parent.Batches = append(parent.Batches, b)
+			}
+			t.open[ref] = b
+			if t.isBuffered(b) {
+				return nil
+			}
+			return msg
+		case '-':
+			b, ok := t.open[ref]
+			if !ok {
+				return msg
+			}
+			delete(t.open, ref)
+			if b.Parent != nil && t.isBuffered(b) {
+				return nil
+			}
+			return &batchMessage{
+				message: message{prefix: msg.Prefix(), command: msg.Command(), parameters: params, tags: msg.Tags()},
+				batch:   b,
+			}
+		}
+	}
+	if parent == nil || len(parent.Messages) >= maxBatchMessages {
+		return msg
+	}
+	parent.Messages = append(parent.Messages, msg)
+	if t.isBuffered(parent) {
+		return nil
+	}
+	return msg
+}
+
+// isBuffered checks if the given batch is held back until the outermost batch containing
+// it has been completed.
+func (t *batchTracker) isBuffered(b *MessageBatch) bool {
+	for b.Parent != nil {
+		b = b.Parent
+	}
+	delivery, ok := t.delivery[b.Type]
+	if !ok {
+		delivery = t.delivery[""]
+	}
+	return delivery == BatchDeliveryBuffered
+}
@@ -0,0 +1,171 @@
+package irc
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// processBatches feeds the given raw messages into the tracker and returns the commands
+// (or, for batch messages, the types of the batches) of the delivered messages.
+func processBatches(t *testing.T, tracker *batchTracker, lines ...string) (delivered []string) {
+	for _, line := range lines {
+		msg, err := NewMessageFromString(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", line, err)
+		}
+		if msg = tracker.process(msg); msg == nil {
+			continue
+		}
+		if bm, ok := msg.(BatchMessage); ok {
+			delivered = append(delivered, "batch:"+bm.Batch().Type)
+		} else {
+			delivered = append(delivered, msg.Command().String())
+		}
+	}
+	return
+}
+
+var nestedBatch = []string{
+	"@label=l1 :irc.example.com BATCH +outer labeled-response",
+	"@batch=outer :irc.example.com BATCH +inner chathistory #test",
+	"@batch=inner :jane!j@localhost PRIVMSG #test :Hello",
+	"@batch=inner :jane!j@localhost PRIVMSG #test :World",
+	":irc.example.com BATCH -inner",
+	"@batch=outer :irc.example.com 366 johndoe #test :End of /NAMES list.",
+	":irc.example.com BATCH -outer",
+}
+
+func TestBatchTracker_Streaming(t *testing.T) {
+	tracker := newBatchTracker()
+	delivered := processBatches(t, &tracker, nestedBatch...)
+	want := []string{"BATCH", "BATCH", "PRIVMSG", "PRIVMSG", "batch:chathistory", "366", "batch:labeled-response"}
+	if !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered %v, expected %v", delivered, want)
+	}
+}
+
+func TestBatchTracker_Buffered(t *testing.T) {
+	tracker := newBatchTracker()
+	tracker.delivery[BatchTypeLabeledResponse] = BatchDeliveryBuffered
+	var outer *MessageBatch
+	for _, line := range nestedBatch {
+		msg, _ := NewMessageFromString(line)
+		if delivered := tracker.process(msg); delivered != nil {
+			bm, ok := delivered.(BatchMessage)
+			if !ok || outer != nil {
+				t.Fatalf("unexpected delivery of %q", delivered)
+			}
+			outer = bm.Batch()
+		}
+	}
+	if outer == nil {
+		t.Fatal("the batch should have been delivered")
+	}
+	if outer.Ref != "outer" || outer.Tags[LabelMessageTag] != "l1" || len(outer.Messages) != 1 || len(outer.Batches) != 1 {
+		t.Errorf("outer batch -> %+v", outer)
+	}
+	inner := outer.Batches[0]
+	if inner.Parent != outer || inner.Type != BatchTypeChathistory || !reflect.DeepEqual(inner.Params, []string{"#test"}) || len(inner.Messages) != 2 {
+		t.Errorf("inner batch -> %+v", inner)
+	}
+	if len(tracker.open) != 0 {
+		t.Errorf("open batches -> %v", tracker.open)
+	}
+
+	// Other types are still streamed.
+	delivered := processBatches(t, &tracker,
+		":irc.example.com BATCH +s netsplit a.example.com b.example.com",
+		"@batch=s :jane!j@localhost QUIT :a.example.com b.example.com",
+		":irc.example.com BATCH -s",
+		":irc.example.com BATCH -unknown")
+	if want := []string{"BATCH", "QUIT", "batch:netsplit", "BATCH"}; !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered %v, expected %v", delivered, want)
+	}
+}
+
+func TestBatchTracker_Limits(t *testing.T) {
+	tracker := newBatchTracker()
+	tracker.delivery[""] = BatchDeliveryBuffered
+	for i := 0; i < maxOpenBatches; i++ {
+		processBatches(t, &tracker, ":irc.example.com BATCH +b"+strconv.Itoa(i)+" example")
+	}
+	delivered := processBatches(t, &tracker,
+		":irc.example.com BATCH +excess example",
+		"@batch=excess :jane!j@localhost PRIVMSG #test :Hello",
+		":irc.example.com BATCH -excess")
+	if want := []string{"BATCH", "PRIVMSG", "BATCH"}; !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered %v, expected %v", delivered, want)
+	}
+
+	for i := 0; i < maxBatchMessages; i++ {
+		processBatches(t, &tracker, "@batch=b0 :jane!j@localhost PRIVMSG #test :"+strconv.Itoa(i))
+	}
+	delivered = processBatches(t, &tracker,
+		"@batch=b0 :jane!j@localhost PRIVMSG #test :excess",
+		":irc.example.com BATCH -b0")
+	if want := []string{"PRIVMSG", "batch:example"}; !reflect.DeepEqual(delivered, want) {
+		t.Errorf("delivered %v, expected %v", delivered, want)
+	}
+}
+
+func TestMessageBatch_Text(t *testing.T) {
+	tracker := newBatchTracker()
+	tracker.delivery[""] = BatchDeliveryBuffered
+	var batch *MessageBatch
+	for _, line := range []string{
+		":jane!j@localhost BATCH +m draft/multiline #test",
+		"@batch=m :jane!j@localhost PRIVMSG #test :Hello",
+		"@batch=m;draft/multiline-concat :jane!j@localhost PRIVMSG #test :, world",
+		"@batch=m :jane!j@localhost PRIVMSG #test :Bye",
+		":jane!j@localhost BATCH -m",
+	} {
+		msg, _ := NewMessageFromString(line)
+		if bm, ok := tracker.process(msg).(BatchMessage); ok {
+			batch = bm.Batch()
+		}
+	}
+	if batch == nil || batch.Type != BatchTypeMultiline || batch.Text() != "Hello, world\nBye" {
+		t.Errorf("Text() -> %+v", batch)
+	}
+}
+
+func TestClientConnection_HandleBatch(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}),
+		WithBatchDelivery(BatchDeliveryBuffered, BatchTypeNetsplit), WithQuitTimeout(fakeServerQuitTimeout))
+	quits := 0
+	conn.Handle(QuitCommand, func(ctx context.Context, msg Message) { quits++ })
+	batches := make(chan *MessageBatch, 1)
+	conn.HandleBatch(BatchTypeNetsplit, func(ctx context.Context, batch *MessageBatch) { batches <- batch })
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send(":johndoe!johndoe@localhost JOIN #test",
+		":irc.example.com 353 johndoe = #test :johndoe jane john",
+		":irc.example.com BATCH +split netsplit a.example.com b.example.com",
+		"@batch=split :jane!j@localhost QUIT :a.example.com b.example.com",
+		"@batch=split :john!j@localhost QUIT :a.example.com b.example.com",
+		":irc.example.com BATCH -split",
+		"PING sync")
+	srv.expect("PONG")
+
+	batch := <-batches
+	if len(batch.Messages) != 2 || !reflect.DeepEqual(batch.Params, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("batch -> %+v", batch)
+	}
+	if quits != 0 {
+		t.Errorf("%d QUIT messages have been delivered on their own", quits)
+	}
+	// The state tracking processes the messages of the batch right away.
+	if ch, _ := conn.Channel("#test"); len(ch.Members()) != 1 {
+		t.Errorf("Members() -> %v", ch.Members())
+	}
+	conn.Close()
+	conn.Wait()
+}
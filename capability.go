@@ -1,5 +1,30 @@
 package irc
 
+import "strings"
+
+// CapabilityNegotiationVersion is the version of the IRCv3 capability negotiation
+// protocol that is announced by the client when sending "CAP LS".
+const CapabilityNegotiationVersion = "302"
+
+// Subcommands of the CAP command.
+const (
+	capSubcommandLS   = "LS"
+	capSubcommandList = "LIST"
+	capSubcommandReq  = "REQ"
+	capSubcommandAck  = "ACK"
+	capSubcommandNak  = "NAK"
+	capSubcommandEnd  = "END"
+	capSubcommandNew  = "NEW"
+	capSubcommandDel  = "DEL"
+)
+
+// capabilityListContinuation is sent as additional parameter in multi-line CAP replies
+// to indicate, that more replies will follow.
+const capabilityListContinuation = "*"
+
+// capabilityValueSeparator separates a capability's name from its value (e.g. "sasl=PLAIN,EXTERNAL").
+const capabilityValueSeparator = "="
+
 type Capability string
 
 const (
@@ -39,10 +64,20 @@ const (
 
 	InivteNotify Capability = "invite-notify"
 
+	// The labeled-response extension allows clients to correlate the responses of the server with
+	// the commands that caused them, by attaching a label to the commands (see ClientConnection.Request()).
+	// It is used along with the batch extension.
+	LabeledResponse Capability = "labeled-response"
+
 	Metadata Capability = "metadata"
 
 	Monitor Capability = "monitor"
 
+	// The draft/multiline extension allows clients to send messages that consist of multiple lines (or
+	// of a single line that exceeds the maximum message length) as a batch, which servers and clients
+	// treat as a single message. Its value announces the limits (e.g. "max-bytes=4096,max-lines=24").
+	Multiline Capability = "draft/multiline"
+
 	MultiPrefix Capability = "multi-prefix"
 
 	SASL Capability = "sasl"
@@ -61,3 +96,19 @@ const (
 func (c Capability) String() string {
 	return string(c)
 }
+
+// parseCapabilityList parses a space-separated list of capabilities as contained
+// within the replies to the CAP command. Capabilities may carry a value (as in
+// "sasl=PLAIN,EXTERNAL"); capabilities without a value will be mapped to an empty string.
+func parseCapabilityList(str string) map[Capability]string {
+	caps := make(map[Capability]string)
+	for _, c := range strings.Fields(str) {
+		nameAndValue := strings.SplitN(c, capabilityValueSeparator, 2)
+		value := ""
+		if len(nameAndValue) == 2 {
+			value = nameAndValue[1]
+		}
+		caps[Capability(nameAndValue[0])] = value
+	}
+	return caps
+}
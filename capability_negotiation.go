@@ -0,0 +1,249 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// capNegotiationTimeout defines how long Open() waits for the capability negotiation
+// to complete. Servers that do not support capability negotiation at all might simply
+// ignore the CAP command, so we'll give up after this period and carry on without any
+// capabilities.
+const capNegotiationTimeout = 15 * time.Second
+
+// capRequestMaxLen limits the length of the capability list sent within a single
+// "CAP REQ" message, so that the message will never exceed MsgMaxLen.
+const capRequestMaxLen = 400
+
+// capNegotiation tracks the state of the capability negotiation that takes place
+// while a connection is being registered.
+type capNegotiation struct {
+	mu       sync.Mutex
+	wanted   map[Capability]bool
+	pending  int // number of unanswered "CAP REQ" messages
+	listed   bool
+	finished bool
+	err      error
+	done     chan struct{}
+}
+
+func newCapNegotiation(wanted []Capability) *capNegotiation {
+	n := &capNegotiation{
+		wanted: make(map[Capability]bool),
+		done:   make(chan struct{}),
+	}
+	for _, c := range wanted {
+		n.wanted[c] = true
+	}
+	return n
+}
+
+// isWanted checks, if the given capability has been asked for by the user.
+func (n *capNegotiation) isWanted(c Capability) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.wanted[c]
+}
+
+// finish marks the negotiation as completed. The returned value is true, if the
+// negotiation had not been finished before, thus only the first caller will be
+// able to send "CAP END".
+func (n *capNegotiation) finish(err error) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.finished {
+		return false
+	}
+	n.finished = true
+	n.err = err
+	close(n.done)
+	return true
+}
+
+// isFinished checks if the negotiation is over.
+func (n *capNegotiation) isFinished() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.finished
+}
+
+// wait blocks until the negotiation has been finished or the timeout has elapsed.
+// The returned value timedOut reports the latter.
+func (n *capNegotiation) wait(timeout time.Duration) (timedOut bool, err error) {
+	select {
+	case <-n.done:
+		n.mu.Lock()
+		err = n.err
+		n.mu.Unlock()
+	case <-time.After(timeout):
+		timedOut = true
+	}
+	return
+}
+
+// handleCapMessage processes CAP replies sent by the server, both during the registration
+// of the connection and afterwards (e.g. "CAP NEW" and "CAP DEL" sent due to cap-notify).
+func (conn *clientConnection) handleCapMessage(msg Message) {
+	params := msg.Parameters()
+	if len(params) < 3 {
+		return
+	}
+	subcommand := strings.ToUpper(params[1])
+	caps := parseCapabilityList(params[len(params)-1])
+	more := len(params) > 3 && params[2] == capabilityListContinuation
+
+	switch subcommand {
+	case capSubcommandLS:
+		conn.addAvailableCapabilities(caps)
+		if !more {
+			conn.onCapabilitiesListed()
+		}
+	case capSubcommandNew:
+		conn.addAvailableCapabilities(caps)
+		conn.requestCapabilities(conn.wantedCapabilitiesFrom(caps))
+	case capSubcommandDel:
+		conn.capMu.Lock()
+		for c := range caps {
+			delete(conn.capabilities, c)
+			delete(conn.capabilityValues, c)
+		}
+		conn.capMu.Unlock()
+	case capSubcommandAck:
+		conn.capMu.Lock()
+		for c := range caps {
+			name := Capability(strings.TrimLeft(string(c), "-~="))
+			conn.capabilities[name] = !strings.HasPrefix(string(c), "-")
+		}
+		conn.capMu.Unlock()
+		conn.onCapabilitiesAnswered()
+	case capSubcommandNak:
+		conn.onCapabilitiesAnswered()
+	}
+}
+
+// addAvailableCapabilities records capabilities that have been advertised by the server.
+func (conn *clientConnection) addAvailableCapabilities(caps map[Capability]string) {
+	conn.capMu.Lock()
+	defer conn.capMu.Unlock()
+	for c, v := range caps {
+		// cap-notify is enabled implicitly when negotiating with version 302 or later.
+		conn.capabilities[c] = conn.capabilities[c] || c == CapNotify
+		conn.capabilityValues[c] = v
+	}
+}
+
+// wantedCapabilitiesFrom filters the given capabilities, so that only the ones
+// which have been asked for by the user and which are not yet enabled are retained.
+func (conn *clientConnection) wantedCapabilitiesFrom(caps map[Capability]string) (wanted []Capability) {
+	for c := range caps {
+		if conn.capNegotiation.isWanted(c) && !conn.HasCapability(c) {
+			wanted = append(wanted, c)
+		}
+	}
+	return
+}
+
+// onCapabilitiesListed is invoked once the (possibly multi-line) reply to "CAP LS" has
+// been received completely.
+func (conn *clientConnection) onCapabilitiesListed() {
+	n := conn.capNegotiation
+	n.mu.Lock()
+	alreadyListed := n.listed
+	n.listed = true
+	n.mu.Unlock()
+	if alreadyListed {
+		return
+	}
+	conn.capMu.RLock()
+	available := make(map[Capability]string, len(conn.capabilityValues))
+	for c, v := range conn.capabilityValues {
+		available[c] = v
+	}
+	conn.capMu.RUnlock()
+	conn.requestCapabilities(conn.wantedCapabilitiesFrom(available))
+	conn.continueCapNegotiation()
+}
+
+// onCapabilitiesAnswered is invoked whenever a "CAP REQ" has either been acknowledged or rejected.
+func (conn *clientConnection) onCapabilitiesAnswered() {
+	n := conn.capNegotiation
+	n.mu.Lock()
+	if n.pending > 0 {
+		n.pending--
+	}
+	n.mu.Unlock()
+	conn.continueCapNegotiation()
+}
+
+// requestCapabilities sends as many "CAP REQ" messages as needed to request the given capabilities.
+func (conn *clientConnection) requestCapabilities(caps []Capability) {
+	var batches []string
+	batch := ""
+	for _, c := range caps {
+		if batch != "" && len(batch)+1+len(c) > capRequestMaxLen {
+			batches = append(batches, batch)
+			batch = ""
+		}
+		if batch != "" {
+			batch += " "
+		}
+		batch += c.String()
+	}
+	if batch != "" {
+		batches = append(batches, batch)
+	}
+	n := conn.capNegotiation
+	n.mu.Lock()
+	n.pending += len(batches)
+	n.mu.Unlock()
+	for _, b := range batches {
+		conn.out <- NewMessageWithoutPrefix(CapCommand, capSubcommandReq, b)
+	}
+}
+
+// continueCapNegotiation ends the capability negotiation, once the server's capabilities
+// have been listed and all capability requests have been answered. If SASL authentication
+// has been configured, the authentication takes place before the negotiation is ended.
+func (conn *clientConnection) continueCapNegotiation() {
+	n := conn.capNegotiation
+	n.mu.Lock()
+	ready := n.listed && n.pending == 0 && !n.finished
+	n.mu.Unlock()
+	if !ready {
+		return
+	}
+	if conn.saslAuth != nil {
+		conn.startSASLAuthentication()
+		return
+	}
+	conn.endCapNegotiation(nil)
+}
+
+// endCapNegotiation sends "CAP END" (unless the negotiation has been finished before).
+func (conn *clientConnection) endCapNegotiation(err error) {
+	if conn.capNegotiation.finish(err) {
+		conn.out <- NewMessageWithoutPrefix(CapCommand, capSubcommandEnd)
+	}
+}
+
+// abortCapNegotiation finishes the capability negotiation without sending "CAP END".
+// This is used when the server does not support capability negotiation at all, if
+// the SASL authentication failed or if the connection has been lost during the negotiation.
+func (conn *clientConnection) abortCapNegotiation(err error) {
+	conn.capNegotiation.finish(err)
+}
+
+// isCapNegotiationRejection checks if the given message is the server's way to tell us
+// that it does not know anything about capability negotiation.
+func isCapNegotiationRejection(msg Message) bool {
+	switch msg.Command() {
+	case UnknownCommandError, InvalidCapCommandError:
+		params := msg.Parameters()
+		return len(params) > 1 && strings.EqualFold(params[1], CapCommand.String())
+	case NotRegisteredError:
+		return true
+	default:
+		return false
+	}
+}
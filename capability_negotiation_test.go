@@ -0,0 +1,111 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientConnection_CapNegotiation(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithCapabilities(MultiPrefix, SASL, ServerTime), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(
+		":irc.example.com CAP * LS * :multi-prefix extended-join",
+		":irc.example.com CAP * LS :sasl=PLAIN,EXTERNAL cap-notify",
+	)
+	req := srv.expect("CAP REQ")
+	srv.send(":irc.example.com CAP * ACK :" + req.Parameters()[1])
+	srv.expect("CAP END")
+
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	for _, c := range []Capability{MultiPrefix, SASL, CapNotify} {
+		if !conn.HasCapability(c) {
+			t.Errorf(`expected capability "%s" to be enabled`, c)
+		}
+	}
+	for _, c := range []Capability{ExtendedJoin, ServerTime} {
+		if conn.HasCapability(c) {
+			t.Errorf(`didn't expect capability "%s" to be enabled`, c)
+		}
+	}
+	if v, ok := conn.CapabilityValue(SASL); !ok || v != "PLAIN,EXTERNAL" {
+		t.Errorf(`CapabilityValue("%s") -> ("%s", %v), expected: ("PLAIN,EXTERNAL", true)`, SASL, v, ok)
+	}
+
+	// cap-notify
+	srv.send(":irc.example.com CAP johndoe NEW :server-time")
+	srv.expect("CAP REQ server-time")
+	srv.send(":irc.example.com CAP johndoe ACK server-time", ":irc.example.com CAP johndoe DEL :multi-prefix")
+	srv.send(":irc.example.com PING :sync")
+	srv.expect("PONG")
+	if !conn.HasCapability(ServerTime) {
+		t.Errorf(`expected capability "%s" to be enabled after CAP NEW`, ServerTime)
+	}
+	if conn.HasCapability(MultiPrefix) {
+		t.Errorf(`didn't expect capability "%s" to be enabled after CAP DEL`, MultiPrefix)
+	}
+}
+
+func TestClientConnection_CapNegotiationNak(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithCapabilities(MultiPrefix), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(":irc.example.com CAP * LS :multi-prefix")
+	srv.expect("CAP REQ multi-prefix")
+	srv.send(":irc.example.com CAP * NAK multi-prefix")
+	srv.expect("CAP END")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	if conn.HasCapability(MultiPrefix) {
+		t.Errorf(`didn't expect capability "%s" to be enabled`, MultiPrefix)
+	}
+}
+
+func TestClientConnection_CapNegotiationUnsupported(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithCapabilities(MultiPrefix), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(":irc.example.com 421 * CAP :Unknown command")
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Open() -> %v", err)
+		}
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("Open() did not return although the server doesn't support capabilities")
+	}
+	if len(conn.Capabilities()) != 0 {
+		t.Errorf("expected no capabilities, found: %v", conn.Capabilities())
+	}
+}
+
+func TestParseCapabilityList(t *testing.T) {
+	caps := parseCapabilityList("multi-prefix sasl=PLAIN,EXTERNAL  draft/foo=")
+	if len(caps) != 3 {
+		t.Errorf("expected 3 capabilities, found: %d", len(caps))
+	}
+	if v, ok := caps[SASL]; !ok || v != "PLAIN,EXTERNAL" {
+		t.Errorf(`unexpected value for capability "%s": "%s"`, SASL, v)
+	}
+	if v, ok := caps[MultiPrefix]; !ok || v != "" {
+		t.Errorf(`unexpected value for capability "%s": "%s"`, MultiPrefix, v)
+	}
+}
@@ -0,0 +1,145 @@
+package irc
+
+import "sort"
+
+// CaseFoldingMap is a map whose keys are compared according to a casemapping, so that e.g.
+// "Foo[" and "foo{" refer to the same entry when using CasemappingRFC1459. The key that
+// has been used to store an entry is being retained.
+// The zero value is an empty map using DefaultCasemapping. Like Go's built-in maps,
+// CaseFoldingMap is not safe for concurrent use.
+type CaseFoldingMap[V any] struct {
+	casemapping Casemapping
+	entries     map[string]caseFoldingEntry[V] // keyed by the lowercase key
+}
+
+type caseFoldingEntry[V any] struct {
+	key   string
+	value V
+}
+
+// NewCaseFoldingMap creates an empty map that compares its keys according to the given
+// casemapping.
+func NewCaseFoldingMap[V any](casemapping Casemapping) *CaseFoldingMap[V] {
+	return &CaseFoldingMap[V]{casemapping: casemapping}
+}
+
+// Casemapping returns the casemapping that is used to compare keys.
+func (m *CaseFoldingMap[V]) Casemapping() Casemapping {
+	if m.casemapping == "" {
+		return DefaultCasemapping
+	}
+	return m.casemapping
+}
+
+// SetCasemapping changes the casemapping that is used to compare keys, e.g. once the
+// server has announced its casemapping. Entries whose keys become equivalent are being
+// merged; which one of them survives is unspecified.
+func (m *CaseFoldingMap[V]) SetCasemapping(casemapping Casemapping) {
+	m.casemapping = casemapping
+	if len(m.entries) == 0 {
+		return
+	}
+	entries := m.entries
+	m.entries = make(map[string]caseFoldingEntry[V], len(entries))
+	for _, e := range entries {
+		m.entries[m.fold(e.key)] = e
+	}
+}
+
+func (m *CaseFoldingMap[V]) fold(key string) string {
+	return m.Casemapping().ToLower(key)
+}
+
+// Get returns the value stored for the given key and whether the key is present.
+func (m *CaseFoldingMap[V]) Get(key string) (value V, ok bool) {
+	e, ok := m.entries[m.fold(key)]
+	return e.value, ok
+}
+
+// Has checks if the given key is present.
+func (m *CaseFoldingMap[V]) Has(key string) bool {
+	_, ok := m.entries[m.fold(key)]
+	return ok
+}
+
+// Key returns the key that has been used to store the entry equivalent to the given key.
+func (m *CaseFoldingMap[V]) Key(key string) (original string, ok bool) {
+	e, ok := m.entries[m.fold(key)]
+	return e.key, ok
+}
+
+// Set stores the value for the given key, replacing the entry of any equivalent key.
+func (m *CaseFoldingMap[V]) Set(key string, value V) {
+	if m.entries == nil {
+		m.entries = make(map[string]caseFoldingEntry[V])
+	}
+	m.entries[m.fold(key)] = caseFoldingEntry[V]{key: key, value: value}
+}
+
+// Delete removes the entry of the given key.
+func (m *CaseFoldingMap[V]) Delete(key string) {
+	delete(m.entries, m.fold(key))
+}
+
+// Rename moves the entry of the key oldKey to the key newKey (e.g. after a NICK message)
+// and returns false, if there has been no such entry.
+func (m *CaseFoldingMap[V]) Rename(oldKey string, newKey string) bool {
+	e, ok := m.entries[m.fold(oldKey)]
+	if !ok {
+		return false
+	}
+	delete(m.entries, m.fold(oldKey))
+	m.Set(newKey, e.value)
+	return true
+}
+
+// Len returns the number of entries.
+func (m *CaseFoldingMap[V]) Len() int {
+	return len(m.entries)
+}
+
+// Keys returns the keys of all entries (as they have been stored), sorted by their
+// lowercase representation.
+func (m *CaseFoldingMap[V]) Keys() []string {
+	folded := make([]string, 0, len(m.entries))
+	for k := range m.entries {
+		folded = append(folded, k)
+	}
+	sort.Strings(folded)
+	keys := make([]string, len(folded))
+	for i, k := range folded {
+		keys[i] = m.entries[k].key
+	}
+	return keys
+}
+
+// Range calls fn for each entry until fn returns false. The order is unspecified.
+func (m *CaseFoldingMap[V]) Range(fn func(key string, value V) bool) {
+	for _, e := range m.entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// NicknameMap is a CaseFoldingMap keyed by nicknames.
+type NicknameMap[V any] struct {
+	CaseFoldingMap[V]
+}
+
+// NewNicknameMap creates an empty map keyed by nicknames, which are compared according
+// to the given casemapping (see ClientConnection.Casemapping()).
+func NewNicknameMap[V any](casemapping Casemapping) *NicknameMap[V] {
+	return &NicknameMap[V]{CaseFoldingMap[V]{casemapping: casemapping}}
+}
+
+// ChannelMap is a CaseFoldingMap keyed by channel names.
+type ChannelMap[V any] struct {
+	CaseFoldingMap[V]
+}
+
+// NewChannelMap creates an empty map keyed by channel names, which are compared according
+// to the given casemapping (see ClientConnection.Casemapping()).
+func NewChannelMap[V any](casemapping Casemapping) *ChannelMap[V] {
+	return &ChannelMap[V]{CaseFoldingMap[V]{casemapping: casemapping}}
+}
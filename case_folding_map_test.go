@@ -0,0 +1,56 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCaseFoldingMap(t *testing.T) {
+	m := NewNicknameMap[int](CasemappingRFC1459)
+	m.Set("Foo[", 1)
+	m.Set("bar", 2)
+	if v, ok := m.Get("foo{"); !ok || v != 1 {
+		t.Errorf("Get(foo{) -> %d, %v", v, ok)
+	}
+	if key, _ := m.Key("FOO{"); key != "Foo[" {
+		t.Errorf("Key(FOO{) -> %q, expected the original key", key)
+	}
+	m.Set("FOO[", 3)
+	if m.Len() != 2 {
+		t.Errorf("equivalent keys should replace each other, but Len() -> %d", m.Len())
+	}
+	if !m.Rename("foo[", "Baz") || m.Has("foo[") {
+		t.Error("Rename() should move the entry")
+	}
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"bar", "Baz"}) {
+		t.Errorf("Keys() -> %q", keys)
+	}
+	m.Delete("BAR")
+	if m.Has("bar") || m.Len() != 1 {
+		t.Errorf("Delete() should remove the entry: %q", m.Keys())
+	}
+}
+
+func TestCaseFoldingMap_ZeroValue(t *testing.T) {
+	var m ChannelMap[string]
+	if _, ok := m.Get("#test"); ok || m.Len() != 0 {
+		t.Error("the zero value should be an empty map")
+	}
+	m.Set("#Test[", "topic")
+	if v, _ := m.Get("#test{"); v != "topic" || m.Casemapping() != DefaultCasemapping {
+		t.Errorf("the zero value should use the default casemapping: %q", v)
+	}
+}
+
+func TestCaseFoldingMap_SetCasemapping(t *testing.T) {
+	m := NewCaseFoldingMap[bool](CasemappingASCII)
+	m.Set("foo[", true)
+	m.Set("foo{", false)
+	if m.Len() != 2 {
+		t.Fatalf("ascii should not consider [ and { to be equivalent: %q", m.Keys())
+	}
+	m.SetCasemapping(CasemappingRFC1459)
+	if m.Len() != 1 || !m.Has("FOO[") {
+		t.Errorf("entries should be merged after changing the casemapping: %q", m.Keys())
+	}
+}
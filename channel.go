@@ -2,45 +2,115 @@ package irc
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
-// channelNameRegex is a regular expression that is being used to identify valid IRC
-// channel names.
-var channelNameRegex = regexp.MustCompile(`[#&+!][a-zA-Z0-9#&+!]{1,49}`)
+// defaultISupport contains the features that apply unless the server announces anything
+// else. It must not be modified.
+var defaultISupport = NewISupport()
 
 // Validates a given IRC channel name and returns either true, if
 // the given name is a valid name for an IRC channel, or false
-// if it not correct according to the RfC(s).
+// if it not correct according to the RfC(s). The default CHANTYPES
+// and CHANNELLEN apply, use ISupport.IsChannelName() to validate
+// channel names according to the features announced by a server.
 func isValidChannelName(name string) bool {
-	return channelNameRegex.MatchString(name)
+	return defaultISupport.IsChannelName(name)
 }
 
 // Channel is basically a group of gathered users.
 type Channel interface {
 	Name() string
 	Topic() string
+	// TopicSetBy returns the nickname (or the prefix) of the user that has set the topic.
+	TopicSetBy() string
+	// TopicSetAt returns the time the topic has been set. The zero time is returned if
+	// it is unknown.
+	TopicSetAt() time.Time
+	// CreatedAt returns the time the channel has been created. The zero time is returned
+	// if it is unknown.
+	CreatedAt() time.Time
+	// Modes returns the channel modes along with their parameters. Modes without
+	// parameter map to an empty string. List modes (e.g. bans) are not included.
+	Modes() map[rune]string
+	// Members returns all known members of the channel, sorted by their nicknames.
+	Members() []ChannelMember
+	// Member looks up a member of the channel by its nickname.
+	Member(nickname string) (member ChannelMember, ok bool)
 	Equal(ch Channel) bool
 	fmt.Stringer
 }
 
+// ChannelMember is a user that has joined a channel.
+type ChannelMember struct {
+	Nickname string
+	// Prefixes contains the membership prefixes of the user (e.g. "@+" for an operator
+	// that has been voiced), ordered by their rank.
+	Prefixes string
+	// ranks contains the membership prefixes announced by the server (see
+	// ISupport.PrefixSymbols), ordered by their rank.
+	ranks string
+}
+
+// HasPrefix checks if the member has been granted the given membership prefix (e.g. '@').
+func (m ChannelMember) HasPrefix(prefix rune) bool {
+	return strings.ContainsRune(m.Prefixes, prefix)
+}
+
+// IsOperator checks if the member is a channel operator (or has an even higher rank,
+// like channel owners and admins). The ranks are taken from the PREFIX announced by the
+// server; if it is unknown, only '@' is considered.
+func (m ChannelMember) IsOperator() bool {
+	ranks := m.ranks
+	if ranks == "" {
+		ranks = defaultPrefixSymbols
+	}
+	op := strings.IndexByte(ranks, '@')
+	return op != -1 && strings.ContainsAny(m.Prefixes, ranks[:op+1])
+}
+
+// IsHalfOperator checks if the member is a half-operator.
+func (m ChannelMember) IsHalfOperator() bool {
+	return m.HasPrefix('%')
+}
+
+// HasVoice checks if the member has been voiced.
+func (m ChannelMember) HasVoice() bool {
+	return m.HasPrefix('+')
+}
+
 type channel struct {
-	name  string
-	topic string
+	name        string
+	topic       string
+	topicSetBy  string
+	topicSetAt  time.Time
+	createdAt   time.Time
+	modes       map[rune]string
+	members     map[string]*ChannelMember // keyed by the lowercase nickname
+	casemapping Casemapping
 }
 
 // NewChannel creates a new channel with the given name.
 func NewChannel(name string) (ch Channel, err error) {
 	if isValidChannelName(name) {
-		ch = &channel{
-			name: name,
-		}
+		ch = newChannel(name, DefaultCasemapping)
 	} else {
 		err = fmt.Errorf("invalid channel name: '%s'", name)
 	}
 	return
 }
 
+func newChannel(name string, casemapping Casemapping) *channel {
+	return &channel{
+		name:        name,
+		modes:       make(map[rune]string),
+		members:     make(map[string]*ChannelMember),
+		casemapping: casemapping,
+	}
+}
+
 func (ch *channel) Name() string {
 	return ch.name
 }
@@ -49,11 +119,62 @@ func (ch *channel) Topic() string {
 	return ch.topic
 }
 
+func (ch *channel) TopicSetBy() string {
+	return ch.topicSetBy
+}
+
+func (ch *channel) TopicSetAt() time.Time {
+	return ch.topicSetAt
+}
+
+func (ch *channel) CreatedAt() time.Time {
+	return ch.createdAt
+}
+
+func (ch *channel) Modes() map[rune]string {
+	modes := make(map[rune]string, len(ch.modes))
+	for m, p := range ch.modes {
+		modes[m] = p
+	}
+	return modes
+}
+
+func (ch *channel) Members() []ChannelMember {
+	members := make([]ChannelMember, 0, len(ch.members))
+	for _, m := range ch.members {
+		members = append(members, *m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return ch.casemapping.ToLower(members[i].Nickname) < ch.casemapping.ToLower(members[j].Nickname)
+	})
+	return members
+}
+
+func (ch *channel) Member(nickname string) (member ChannelMember, ok bool) {
+	m, ok := ch.members[ch.casemapping.ToLower(nickname)]
+	if ok {
+		member = *m
+	}
+	return
+}
+
+// clone creates a deep copy of the channel.
+func (ch *channel) clone() *channel {
+	c := *ch
+	c.modes = ch.Modes()
+	c.members = make(map[string]*ChannelMember, len(ch.members))
+	for k, m := range ch.members {
+		member := *m
+		c.members[k] = &member
+	}
+	return &c
+}
+
 // Equal compares two channel definitions for equality.
 // Channel names in IRC are case in-sensitive, so therefore we'll
 // have to keep that in mind when comparing using string comparisons.
 func (ch *channel) Equal(that Channel) bool {
-	return toLowercase(ch.name) == toLowercase(that.Name())
+	return ch.casemapping.ToLower(ch.name) == ch.casemapping.ToLower(that.Name())
 }
 
 // String returns a string representation of the channel, usually it's name.
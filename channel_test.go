@@ -19,6 +19,11 @@ func TestIsValidChannelName(t *testing.T) {
 		{"+plusChan", true},
 		{"@what?", false},
 		{"'yankeedoo'", false},
+		{"#go-nuts", true},
+		{"#with space", false},
+		{"#a,#b", false},
+		{"foo#bar", false},
+		{"#", false},
 	}
 	for _, tt := range testdata {
 		if valid := isValidChannelName(tt.name); valid != tt.valid {
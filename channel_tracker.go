@@ -0,0 +1,308 @@
+package irc
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChannelTracker keeps track of the state of the channels that the client has joined:
+// their members (along with their membership prefixes), modes, topics and creation times.
+// The tracker has to be fed with all the messages received from the server. Connections
+// maintain a tracker of their own, which can be queried using ClientConnection.Channel().
+// The membership prefixes, channel modes and the casemapping announced by the server
+// using RPL_ISUPPORT are being honoured.
+type ChannelTracker struct {
+	mu       sync.RWMutex
+	nickname string
+	channels map[string]*channel // keyed by the lowercase channel name
+	names    map[string]map[string]*ChannelMember
+	isupport *ISupport
+}
+
+// NewChannelTracker creates a tracker that does not know about any channels yet.
+func NewChannelTracker() *ChannelTracker {
+	t := &ChannelTracker{}
+	t.Reset()
+	return t
+}
+
+// Reset forgets about all channels. This is necessary whenever a new connection to the
+// server is being established.
+func (t *ChannelTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nickname = ""
+	t.channels = make(map[string]*channel)
+	t.names = make(map[string]map[string]*ChannelMember)
+	t.isupport = NewISupport()
+}
+
+// Channel returns a snapshot of the current state of the given channel. If the channel
+// has not been joined, ok will be false.
+func (t *ChannelTracker) Channel(name string) (ch Channel, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c, ok := t.channels[t.toLowercase(name)]
+	if ok {
+		ch = c.clone()
+	}
+	return
+}
+
+// Channels returns snapshots of all the channels that have been joined.
+func (t *ChannelTracker) Channels() []Channel {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	channels := make([]Channel, 0, len(t.channels))
+	for _, c := range t.channels {
+		channels = append(channels, c.clone())
+	}
+	return channels
+}
+
+// Process updates the state of the channels according to the given message.
+func (t *ChannelTracker) Process(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	params := msg.Parameters()
+	nick := ""
+	if pfx := msg.Prefix(); pfx != nil {
+		nick = pfx.Nickname()
+	}
+	switch msg.Command() {
+	case WelcomeReply:
+		if len(params) > 0 {
+			t.nickname = params[0]
+		}
+	case ISupportReply:
+		t.isupport.Update(msg)
+	case NickCommand:
+		if len(params) > 0 && nick != "" {
+			t.renameMember(nick, params[0])
+		}
+	case JoinCommand:
+		if len(params) > 0 && nick != "" {
+			t.join(params[0], nick)
+		}
+	case PartCommand:
+		if len(params) > 0 && nick != "" {
+			for _, name := range splitList(params[0]) {
+				t.leave(name, nick)
+			}
+		}
+	case KickCommand:
+		if len(params) > 1 {
+			t.leave(params[0], params[1])
+		}
+	case QuitCommand:
+		for _, c := range t.channels {
+			delete(c.members, t.toLowercase(nick))
+		}
+	case ModeCommand:
+		if len(params) > 1 {
+			if c, ok := t.channels[t.toLowercase(params[0])]; ok {
+				t.applyModes(c, params[1], params[2:])
+			}
+		}
+	case TopicCommand:
+		if len(params) > 1 {
+			if c, ok := t.channels[t.toLowercase(params[0])]; ok {
+				c.topic = params[1]
+				c.topicSetBy = nick
+				c.topicSetAt = time.Now()
+			}
+		}
+	case ChannelModeIsReply:
+		if len(params) > 2 {
+			if c, ok := t.channels[t.toLowercase(params[1])]; ok {
+				c.modes = make(map[rune]string)
+				t.applyModes(c, params[2], params[3:])
+			}
+		}
+	case CreationTimeReply:
+		if len(params) > 2 {
+			if c, ok := t.channels[t.toLowercase(params[1])]; ok {
+				c.createdAt = parseUnixTime(params[2])
+			}
+		}
+	case NoTopicReply:
+		if len(params) > 1 {
+			if c, ok := t.channels[t.toLowercase(params[1])]; ok {
+				c.topic, c.topicSetBy, c.topicSetAt = "", "", time.Time{}
+			}
+		}
+	case TopicReply:
+		if len(params) > 2 {
+			if c, ok := t.channels[t.toLowercase(params[1])]; ok {
+				c.topic = params[2]
+			}
+		}
+	case TopicWhoTimeReply:
+		if len(params) > 3 {
+			if c, ok := t.channels[t.toLowercase(params[1])]; ok {
+				c.topicSetBy = params[2]
+				c.topicSetAt = parseUnixTime(params[3])
+			}
+		}
+	case NamesReply:
+		// "<client> <symbol> <channel> :<names>" (older servers omit the symbol).
+		if len(params) > 2 {
+			t.addNames(params[len(params)-2], params[len(params)-1])
+		}
+	case EndOfNamesReply:
+		if len(params) > 1 {
+			t.finishNames(params[1])
+		}
+	}
+}
+
+// toLowercase converts the given nickname or channel name according to the casemapping
+// of the server.
+func (t *ChannelTracker) toLowercase(str string) string {
+	return t.isupport.Casemapping.ToLower(str)
+}
+
+// isSelf checks if the given nickname is our own one.
+func (t *ChannelTracker) isSelf(nickname string) bool {
+	return t.nickname != "" && t.toLowercase(nickname) == t.toLowercase(t.nickname)
+}
+
+func (t *ChannelTracker) join(name string, nickname string) {
+	key := t.toLowercase(name)
+	if t.isSelf(nickname) {
+		t.channels[key] = newChannel(name, t.isupport.Casemapping)
+	}
+	if c, ok := t.channels[key]; ok {
+		if _, exists := c.members[t.toLowercase(nickname)]; !exists {
+			c.members[t.toLowercase(nickname)] = &ChannelMember{Nickname: nickname, ranks: t.isupport.PrefixSymbols}
+		}
+	}
+}
+
+func (t *ChannelTracker) leave(name string, nickname string) {
+	key := t.toLowercase(name)
+	if t.isSelf(nickname) {
+		delete(t.channels, key)
+		delete(t.names, key)
+		return
+	}
+	if c, ok := t.channels[key]; ok {
+		delete(c.members, t.toLowercase(nickname))
+	}
+}
+
+func (t *ChannelTracker) renameMember(oldNickname string, newNickname string) {
+	if t.isSelf(oldNickname) {
+		t.nickname = newNickname
+	}
+	oldKey, newKey := t.toLowercase(oldNickname), t.toLowercase(newNickname)
+	for _, c := range t.channels {
+		if m, ok := c.members[oldKey]; ok {
+			delete(c.members, oldKey)
+			m.Nickname = newNickname
+			c.members[newKey] = m
+		}
+	}
+}
+
+// addNames collects the members listed within a RPL_NAMREPLY. The member list of the
+// channel will be replaced once RPL_ENDOFNAMES has been received.
+func (t *ChannelTracker) addNames(name string, names string) {
+	key := t.toLowercase(name)
+	if _, ok := t.channels[key]; !ok {
+		return
+	}
+	members, ok := t.names[key]
+	if !ok {
+		members = make(map[string]*ChannelMember)
+		t.names[key] = members
+	}
+	for _, entry := range strings.Fields(names) {
+		i := 0
+		for i < len(entry) && strings.IndexByte(t.isupport.PrefixSymbols, entry[i]) != -1 {
+			i++
+		}
+		nickname := entry[i:]
+		// Strip the user and host, if the server has sent them (userhost-in-names).
+		if excl := strings.IndexRune(nickname, '!'); excl != -1 {
+			nickname = nickname[:excl]
+		}
+		if nickname == "" {
+			continue
+		}
+		members[t.toLowercase(nickname)] = &ChannelMember{
+			Nickname: nickname,
+			Prefixes: t.sortPrefixes(entry[:i]),
+			ranks:    t.isupport.PrefixSymbols,
+		}
+	}
+}
+
+func (t *ChannelTracker) finishNames(name string) {
+	key := t.toLowercase(name)
+	members, ok := t.names[key]
+	delete(t.names, key)
+	if c, joined := t.channels[key]; joined && ok {
+		c.members = members
+	}
+}
+
+// applyModes applies the given mode changes (e.g. "+o-v" with the arguments "alice" and
+// "bob") to the channel.
+func (t *ChannelTracker) applyModes(c *channel, modes string, args []string) {
+	// Changes lacking their arguments are still being applied as good as possible.
+	changes, _ := ParseModeChanges(t.isupport, modes, args)
+	for _, change := range changes {
+		switch change.Type {
+		case ModeTypePrefix:
+			if m, isMember := c.members[t.toLowercase(change.Arg)]; change.Arg != "" && isMember {
+				symbol := t.isupport.PrefixSymbols[strings.IndexRune(t.isupport.PrefixModes, change.Mode)]
+				prefixes := strings.Replace(m.Prefixes, string(symbol), "", -1)
+				if change.Adding {
+					prefixes += string(symbol)
+				}
+				m.Prefixes = t.sortPrefixes(prefixes)
+			}
+		case ModeTypeList:
+		default:
+			if change.Adding {
+				c.modes[change.Mode] = change.Arg
+			} else {
+				delete(c.modes, change.Mode)
+			}
+		}
+	}
+}
+
+// sortPrefixes orders the given membership prefixes by their rank.
+func (t *ChannelTracker) sortPrefixes(prefixes string) string {
+	var sb strings.Builder
+	for i := 0; i < len(t.isupport.PrefixSymbols); i++ {
+		if strings.IndexByte(prefixes, t.isupport.PrefixSymbols[i]) != -1 {
+			sb.WriteByte(t.isupport.PrefixSymbols[i])
+		}
+	}
+	return sb.String()
+}
+
+// parseUnixTime converts a unix timestamp (in seconds) into a time. The zero time is
+// returned if the timestamp is invalid.
+func parseUnixTime(str string) time.Time {
+	secs, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// Channel returns the current state of a channel that has been joined.
+func (conn *clientConnection) Channel(name string) (ch Channel, ok bool) {
+	return conn.channels.Channel(name)
+}
+
+// Channels returns the current state of all the channels that have been joined.
+func (conn *clientConnection) Channels() []Channel {
+	return conn.channels.Channels()
+}
@@ -0,0 +1,220 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+// processRaw feeds the given raw messages into the tracker.
+func processRaw(t *testing.T, tracker *ChannelTracker, lines ...string) {
+	for _, line := range lines {
+		msg, err := NewMessageFromString(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", line, err)
+		}
+		tracker.Process(msg)
+	}
+}
+
+// joinedTracker returns a tracker that has joined "#test" along with a few other users.
+func joinedTracker(t *testing.T) *ChannelTracker {
+	tracker := NewChannelTracker()
+	processRaw(t, tracker,
+		":irc.example.com 001 me :Welcome",
+		":me!me@localhost JOIN #test",
+		":irc.example.com 353 me = #test :@+alice bob +carol me",
+		":irc.example.com 366 me #test :End of /NAMES list.",
+	)
+	return tracker
+}
+
+func TestChannelTracker_Names(t *testing.T) {
+	tracker := joinedTracker(t)
+	ch, ok := tracker.Channel("#TEST")
+	if !ok {
+		t.Fatal("channel #test should be tracked")
+	}
+	var testData = []struct {
+		nick     string
+		prefixes string
+		op       bool
+		voice    bool
+	}{
+		{"alice", "@+", true, true},
+		{"bob", "", false, false},
+		{"Carol", "+", false, true},
+		{"me", "", false, false},
+	}
+	for _, td := range testData {
+		m, ok := ch.Member(td.nick)
+		if !ok {
+			t.Errorf("%s should be a member of #test", td.nick)
+			continue
+		}
+		if m.Prefixes != td.prefixes || m.IsOperator() != td.op || m.HasVoice() != td.voice {
+			t.Errorf("Member(%s) -> %+v (op: %v, voice: %v)", td.nick, m, m.IsOperator(), m.HasVoice())
+		}
+	}
+	if members := ch.Members(); len(members) != 4 || members[0].Nickname != "alice" {
+		t.Errorf("Members() -> %+v", members)
+	}
+}
+
+func TestChannelTracker_Membership(t *testing.T) {
+	tracker := joinedTracker(t)
+	processRaw(t, tracker,
+		":dave!dave@localhost JOIN #test",
+		":bob!bob@localhost PART #test :Bye",
+		":alice!alice@localhost KICK #test carol :Behave",
+		":alice!alice@localhost NICK alicia",
+		":dave!dave@localhost QUIT :Gone",
+		":irc.example.com 353 me = #other :nobody",
+	)
+	ch, _ := tracker.Channel("#test")
+	for _, nick := range []string{"bob", "carol", "alice", "dave"} {
+		if _, ok := ch.Member(nick); ok {
+			t.Errorf("%s should no longer be a member of #test", nick)
+		}
+	}
+	if m, ok := ch.Member("alicia"); !ok || !m.IsOperator() {
+		t.Errorf("Member(alicia) -> %+v, %v; expected the renamed operator", m, ok)
+	}
+	if _, ok := tracker.Channel("#other"); ok {
+		t.Error("channels that have not been joined should not be tracked")
+	}
+
+	processRaw(t, tracker, ":alicia!alice@localhost KICK #test me :Out")
+	if _, ok := tracker.Channel("#test"); ok {
+		t.Error("#test should no longer be tracked after we have been kicked")
+	}
+}
+
+func TestChannelTracker_Modes(t *testing.T) {
+	tracker := joinedTracker(t)
+	processRaw(t, tracker,
+		":irc.example.com 324 me #test +ntk secret",
+		":irc.example.com 329 me #test 1500000000",
+		":alice!alice@localhost MODE #test +lo-v+b 10 bob alice *!*@spam",
+		":alice!alice@localhost MODE #test -k secret",
+	)
+	ch, _ := tracker.Channel("#test")
+	modes := ch.Modes()
+	if len(modes) != 3 || modes['l'] != "10" || modes['n'] != "" || modes['t'] != "" {
+		t.Errorf("Modes() -> %q", modes)
+	}
+	if m, _ := ch.Member("bob"); !m.IsOperator() {
+		t.Errorf("bob should be an operator: %+v", m)
+	}
+	if m, _ := ch.Member("alice"); m.Prefixes != "@" {
+		t.Errorf("alice should no longer be voiced: %+v", m)
+	}
+	if !ch.CreatedAt().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("CreatedAt() -> %v", ch.CreatedAt())
+	}
+}
+
+func TestChannelTracker_Topic(t *testing.T) {
+	tracker := joinedTracker(t)
+	processRaw(t, tracker,
+		":irc.example.com 332 me #test :Welcome to #test",
+		":irc.example.com 333 me #test alice!alice@localhost 1500000000",
+	)
+	ch, _ := tracker.Channel("#test")
+	if ch.Topic() != "Welcome to #test" || ch.TopicSetBy() != "alice!alice@localhost" || !ch.TopicSetAt().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("topic -> %q by %q at %v", ch.Topic(), ch.TopicSetBy(), ch.TopicSetAt())
+	}
+
+	processRaw(t, tracker, ":bob!bob@localhost TOPIC #test :Off-topic")
+	ch, _ = tracker.Channel("#test")
+	if ch.Topic() != "Off-topic" || ch.TopicSetBy() != "bob" || ch.TopicSetAt().IsZero() {
+		t.Errorf("topic -> %q by %q at %v", ch.Topic(), ch.TopicSetBy(), ch.TopicSetAt())
+	}
+}
+
+func TestChannelTracker_Snapshot(t *testing.T) {
+	tracker := joinedTracker(t)
+	ch, _ := tracker.Channel("#test")
+	processRaw(t, tracker, ":bob!bob@localhost PART #test")
+	if _, ok := ch.Member("bob"); !ok {
+		t.Error("snapshots should not be affected by later changes")
+	}
+}
+
+func TestClientConnection_Channel(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send(":johndoe!johndoe@localhost JOIN #test",
+		":irc.example.com 353 johndoe = #test :@johndoe +jane",
+		":irc.example.com 366 johndoe #test :End of /NAMES list.",
+		"PING sync")
+	srv.expect("PONG")
+
+	ch, ok := conn.Channel("#test")
+	if !ok {
+		t.Fatal("channel #test should be tracked")
+	}
+	if m, ok := ch.Member("jane"); !ok || !m.HasVoice() {
+		t.Errorf("Member(jane) -> %+v, %v", m, ok)
+	}
+	if len(conn.Channels()) != 1 {
+		t.Errorf("Channels() -> %v", conn.Channels())
+	}
+	conn.Close()
+	conn.Wait()
+}
+
+func TestChannelTracker_ISupport(t *testing.T) {
+	tracker := NewChannelTracker()
+	processRaw(t, tracker,
+		":irc.example.com 001 me :Welcome",
+		":irc.example.com 005 me CASEMAPPING=ascii PREFIX=(qaohv)~&@%+ CHANMODES=beI,k,lf,imnst :are supported by this server",
+		":me!me@localhost JOIN #test",
+		":irc.example.com 353 me = #test :~owner %half [brackets] me",
+		":irc.example.com 366 me #test :End of /NAMES list.",
+		":owner!owner@localhost MODE #test +af-h+f half half half 5:10",
+	)
+	ch, _ := tracker.Channel("#test")
+	if m, ok := ch.Member("half"); !ok || m.Prefixes != "&" || !m.IsOperator() || m.IsHalfOperator() {
+		t.Errorf("Member(half) -> %+v, %v", m, ok)
+	}
+	if m, ok := ch.Member("Owner"); !ok || m.Prefixes != "~" || !m.IsOperator() {
+		t.Errorf("Member(Owner) -> %+v, %v", m, ok)
+	}
+	if modes := ch.Modes(); len(modes) != 1 || modes['f'] != "5:10" {
+		t.Errorf("Modes() -> %q", modes)
+	}
+	if _, ok := ch.Member("{brackets}"); ok {
+		t.Error("ascii casemapping should not consider [] and {} to be equivalent")
+	}
+	if _, ok := ch.Member("[BRACKETS]"); !ok {
+		t.Error("Member([BRACKETS]) should be found")
+	}
+}
+
+func TestChannelMember_IsOperator(t *testing.T) {
+	tracker := NewChannelTracker()
+	processRaw(t, tracker,
+		":irc.example.com 001 me :Welcome",
+		":irc.example.com 005 me PREFIX=(Yqaohv)!~&@%+ :are supported by this server",
+		":me!me@localhost JOIN #test",
+		":irc.example.com 353 me = #test :!boss %half +voice me",
+		":irc.example.com 366 me #test :End of /NAMES list.",
+	)
+	ch, _ := tracker.Channel("#test")
+	for nick, op := range map[string]bool{"boss": true, "half": false, "voice": false, "me": false} {
+		if m, _ := ch.Member(nick); m.IsOperator() != op {
+			t.Errorf("Member(%s).IsOperator() -> %v, expected: %v", nick, !op, op)
+		}
+	}
+	// Without the PREFIX of the server, only '@' denotes operators.
+	if m := (ChannelMember{Nickname: "amp", Prefixes: "&"}); m.IsOperator() {
+		t.Error("IsOperator() should not assume '&' to be a membership prefix")
+	}
+}
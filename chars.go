@@ -1,21 +1,110 @@
 package irc
 
-import "strings"
+import (
+	"strings"
 
-// stringLowercaser can be used to convert a string used in the IRC protocol to it's lowercase representation.
-// Background: Because of IRC's Scandinavian origin, the characters {}|^ are considered to be the lower case
-// equivalents of the characters []\~, respectively.
-var stringLowercaser = strings.NewReplacer("{", "[", "}", "]", "|", "\\", "^", "~")
+	"golang.org/x/text/secure/precis"
+)
 
-// stringLowercaser can be used to convert a string used in the IRC protocol to it's uppercase representation.
-// Background: Because of IRC's Scandinavian origin, the characters {}|^ are considered to be the lower case
-// equivalents of the characters []\~, respectively.
-var stringUppercaser = strings.NewReplacer("[", "{", "]", "}", "\\", "|", "~", "^")
+// Casemapping determines which characters are considered to be equivalent when comparing
+// nicknames and channel names. Servers announce the casemapping they use within the
+// CASEMAPPING token of RPL_ISUPPORT.
+type Casemapping string
 
-func toLowercase(str string) string {
-	return strings.ToLower(stringLowercaser.Replace(str))
+const (
+	// CasemappingASCII only considers the letters A to Z to be the upper case equivalents
+	// of the letters a to z.
+	CasemappingASCII Casemapping = "ascii"
+	// CasemappingRFC1459 extends CasemappingASCII. Because of IRC's Scandinavian origin, the
+	// characters {}|^ are considered to be the lower case equivalents of the characters []\~,
+	// respectively. This is the default casemapping.
+	CasemappingRFC1459 Casemapping = "rfc1459"
+	// CasemappingStrictRFC1459 is like CasemappingRFC1459, but does not consider ^ to be the
+	// lower case equivalent of ~.
+	CasemappingStrictRFC1459 Casemapping = "strict-rfc1459"
+	// CasemappingRFC7613 applies the PRECIS UsernameCaseMapped profile (RfC-7613), which
+	// maps Unicode characters to lower case and normalizes them. Strings that are not
+	// allowed by the profile are mapped like CasemappingASCII.
+	CasemappingRFC7613 Casemapping = "rfc7613"
+)
+
+// DefaultCasemapping is used unless the server announces a different casemapping.
+const DefaultCasemapping = CasemappingRFC1459
+
+var (
+	asciiLowercaser         = strings.NewReplacer(asciiCasePairs(true)...)
+	asciiUppercaser         = strings.NewReplacer(asciiCasePairs(false)...)
+	rfc1459Lowercaser       = strings.NewReplacer(append(asciiCasePairs(true), "[", "{", "\\", "|", "]", "}", "~", "^")...)
+	rfc1459Uppercaser       = strings.NewReplacer(append(asciiCasePairs(false), "{", "[", "|", "\\", "}", "]", "^", "~")...)
+	strictRFC1459Lowercaser = strings.NewReplacer(append(asciiCasePairs(true), "[", "{", "\\", "|", "]", "}")...)
+	strictRFC1459Uppercaser = strings.NewReplacer(append(asciiCasePairs(false), "{", "[", "|", "\\", "}", "]")...)
+)
+
+// asciiCasePairs creates the replacement pairs that convert the letters A to Z to lower
+// case or the letters a to z to upper case, respectively.
+func asciiCasePairs(toLower bool) []string {
+	pairs := make([]string, 0, 52)
+	for c := 'A'; c <= 'Z'; c++ {
+		upper, lower := string(c), string(c+'a'-'A')
+		if toLower {
+			pairs = append(pairs, upper, lower)
+		} else {
+			pairs = append(pairs, lower, upper)
+		}
+	}
+	return pairs
+}
+
+// ToLower converts the given string to its lower case representation. Characters that
+// are not covered by the casemapping remain unchanged. Unknown casemappings are treated
+// like DefaultCasemapping.
+func (cm Casemapping) ToLower(str string) string {
+	switch cm {
+	case CasemappingASCII:
+		return asciiLowercaser.Replace(str)
+	case CasemappingRFC7613:
+		if folded, err := precis.UsernameCaseMapped.String(str); err == nil {
+			return folded
+		}
+		return asciiLowercaser.Replace(str)
+	case CasemappingStrictRFC1459:
+		return strictRFC1459Lowercaser.Replace(str)
+	default:
+		return rfc1459Lowercaser.Replace(str)
+	}
+}
+
+// ToUpper converts the given string to its upper case representation. Characters that
+// are not covered by the casemapping remain unchanged. Unknown casemappings are treated
+// like DefaultCasemapping.
+func (cm Casemapping) ToUpper(str string) string {
+	switch cm {
+	case CasemappingASCII:
+		return asciiUppercaser.Replace(str)
+	case CasemappingRFC7613:
+		return strings.ToUpper(str)
+	case CasemappingStrictRFC1459:
+		return strictRFC1459Uppercaser.Replace(str)
+	default:
+		return rfc1459Uppercaser.Replace(str)
+	}
+}
+
+// IsKnown checks if the casemapping is supported by this package.
+func (cm Casemapping) IsKnown() bool {
+	switch cm {
+	case CasemappingASCII, CasemappingRFC1459, CasemappingStrictRFC1459, CasemappingRFC7613:
+		return true
+	default:
+		return false
+	}
+}
+
+// Equal checks if the given strings are equivalent according to the casemapping.
+func (cm Casemapping) Equal(a string, b string) bool {
+	return cm.ToLower(a) == cm.ToLower(b)
 }
 
-func toUppercase(str string) string {
-	return stringUppercaser.Replace(strings.ToUpper(str))
+func (cm Casemapping) String() string {
+	return string(cm)
 }
@@ -0,0 +1,66 @@
+package irc
+
+import "testing"
+
+func TestCasemapping_ToLower(t *testing.T) {
+	testData := []struct {
+		casemapping Casemapping
+		in          string
+		out         string
+	}{
+		{CasemappingASCII, "Nick[]\\~", "nick[]\\~"},
+		{CasemappingRFC1459, "Nick[]\\~", "nick{}|^"},
+		{CasemappingStrictRFC1459, "Nick[]\\~", "nick{}|~"},
+		{Casemapping("unknown"), "Nick[]\\~", "nick{}|^"},
+		{CasemappingRFC1459, "Ünïcödé", "Ünïcödé"},
+		{CasemappingRFC7613, "Ünïcödé", "ünïcödé"},
+		{CasemappingRFC7613, "ＡＢＣ", "abc"},
+		{CasemappingRFC7613, "Nick[]\\~", "nick[]\\~"},
+		{CasemappingRFC7613, "with space", "with space"},
+	}
+	for _, td := range testData {
+		if out := td.casemapping.ToLower(td.in); out != td.out {
+			t.Errorf("%s.ToLower(%q) -> %q, expected: %q", td.casemapping, td.in, out, td.out)
+		}
+	}
+}
+
+func TestCasemapping_ToUpper(t *testing.T) {
+	testData := []struct {
+		casemapping Casemapping
+		in          string
+		out         string
+	}{
+		{CasemappingASCII, "nick{}|^", "NICK{}|^"},
+		{CasemappingRFC1459, "nick{}|^", "NICK[]\\~"},
+		{CasemappingStrictRFC1459, "nick{}|^", "NICK[]\\^"},
+	}
+	for _, td := range testData {
+		if out := td.casemapping.ToUpper(td.in); out != td.out {
+			t.Errorf("%s.ToUpper(%q) -> %q, expected: %q", td.casemapping, td.in, out, td.out)
+		}
+	}
+}
+
+func TestCasemapping_Equal(t *testing.T) {
+	if !CasemappingRFC1459.Equal("Foo[Bar]", "foo{bar}") {
+		t.Error("rfc1459 should consider [] and {} to be equivalent")
+	}
+	if CasemappingASCII.Equal("Foo[Bar]", "foo{bar}") {
+		t.Error("ascii should not consider [] and {} to be equivalent")
+	}
+	if !CasemappingRFC7613.Equal("Σίσυφος", "σίσυφος") {
+		t.Error("rfc7613 should fold Unicode characters")
+	}
+}
+
+func TestCasemapping_IsKnown(t *testing.T) {
+	for _, cm := range []Casemapping{CasemappingASCII, CasemappingRFC1459, CasemappingStrictRFC1459, CasemappingRFC7613} {
+		if !cm.IsKnown() {
+			t.Errorf("%s should be known", cm)
+		}
+	}
+	if Casemapping("unknown").IsKnown() {
+		t.Error("unknown casemappings should not be known")
+	}
+}
@@ -2,10 +2,14 @@ package irc
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
+	"time"
 )
 
 // ConnectionState is a bit mask that determines the current connection state.
@@ -22,6 +26,8 @@ func (cs ConnectionState) String() string {
 		str = "OPEN"
 	case ConnectionStateReady:
 		str = "READY"
+	case ConnectionStateReconnecting:
+		str = "RECONNECTING"
 	default:
 		str = fmt.Sprintf("UNKNOWN (%d)", cs)
 	}
@@ -32,6 +38,10 @@ func (cs ConnectionState) String() string {
 // the connection to a server has already been
 var ConnectionAlreadyEstablished = fmt.Errorf("connection has already been established")
 
+// errConnectionLost is reported by Open(), if the connection to the server ends before
+// the capability negotiation or the registration has been completed.
+var errConnectionLost = fmt.Errorf("connection to the server has been lost")
+
 // connectionMsgBufSize defines the buffer size to be used for incoming and outgoing
 // messages that are send and received by a connection.
 const connectionMsgBufSize = 64
@@ -49,6 +59,9 @@ const (
 	// Usually, this is the case, after the NICK, USER and possible the PASS commands have
 	// been send and successfully acknowledged by the server.
 	ConnectionStateReady ConnectionState = 0x4
+	// ConnectionStateReconnecting indicates, that the connection has been lost and that
+	// an attempt to re-establish the connection is about to be made.
+	ConnectionStateReconnecting ConnectionState = 0x8
 )
 
 // ClientConnection encapsulates details about a client<>server connection.
@@ -56,130 +69,572 @@ type ClientConnection interface {
 	State() <-chan ConnectionState
 	Hostname() string
 	Port() int
+	// Nickname returns the nickname that is currently being used on this connection.
+	Nickname() string
 	Capabilities() []Capability
 	HasCapability(Capability) bool
+	// CapabilityValue returns the value that the server advertised for the given capability
+	// (e.g. "PLAIN,EXTERNAL" for "sasl") and whether the capability is offered at all.
+	CapabilityValue(Capability) (value string, ok bool)
+	// ServerInfo returns a snapshot of what is known about the server, including the
+	// features it has announced using RPL_ISUPPORT.
+	ServerInfo() *ServerInfo
+	// Casemapping returns the casemapping that the server uses to compare nicknames and
+	// channel names (see NewNicknameMap() and NewChannelMap()).
+	Casemapping() Casemapping
+	// Lag returns the round-trip time of the latest keepalive PING (see WithKeepalive()).
+	Lag() time.Duration
+	// SendQueueStats returns metrics of the outgoing message queue (see WithFloodControl()).
+	SendQueueStats() SendQueueStats
+	// SendText sends a PRIVMSG or NOTICE to the target. Texts that exceed the maximum
+	// message length are split into multiple messages (see MessageSplitter).
+	SendText(command Command, target string, text string) error
+	// Channel returns the current state of a channel that has been joined.
+	Channel(name string) (ch Channel, ok bool)
+	// Channels returns the current state of all the channels that have been joined.
+	Channels() []Channel
+	// User returns what is known about the user with the given nickname (see UserRegistry).
+	User(nickname string) (user UserInfo, ok bool)
+	// Users returns what is known about all the users the client shares a channel with.
+	Users() []UserInfo
+	// UserFromPrefix resolves the prefix of a message into a user record. If the user is
+	// unknown, the record only contains the information of the prefix and ok is false.
+	UserFromPrefix(prefix Prefix) (user UserInfo, ok bool)
+	// Whois queries information about the user with the given nickname and waits for the
+	// replies of the server. Like the other queries, it cannot be called from a serial
+	// handler (see HandlerModeSerial), an error is returned in that case.
+	Whois(ctx context.Context, nickname string) (*WhoisResult, error)
+	// Who queries the users matching the given mask and waits for the replies of the server.
+	Who(ctx context.Context, mask string) ([]WhoEntry, error)
+	// Whowas queries information about the former users of the given nickname and waits
+	// for the replies of the server.
+	Whowas(ctx context.Context, nickname string, count int) ([]WhowasEntry, error)
+	// Request sends the given message and waits for the response of the server, which is
+	// recognized using labeled-response if possible (see WithRequestTimeout()).
+	Request(ctx context.Context, msg Message) ([]Message, error)
+	// In delivers the messages received from the server. It has to be drained, unless
+	// WithoutInChannel() has been used: once its buffer is full, no further messages are
+	// read from the server, which will eventually drop the connection.
 	In() <-chan Message
 	Out() chan<- Message
+	// Err delivers the errors that occur while the connection is open. If the errors are
+	// not consumed, the oldest ones are dropped.
 	Err() <-chan error
+	// Handle registers a handler for incoming messages with the given command. Use AnyCommand
+	// to register a handler for all messages. The returned function removes the handler again.
+	Handle(cmd Command, fn HandlerFunc, opts ...HandlerOption) (remove func())
+	// HandleNumericRange registers a handler for all numeric replies within the given range.
+	// The returned function removes the handler again.
+	HandleNumericRange(from int, to int, fn HandlerFunc, opts ...HandlerOption) (remove func())
+	// HandleBatch registers a handler for completed batches of the given type (see
+	// WithBatchDelivery()). The returned function removes the handler again.
+	HandleBatch(batchType string, fn BatchHandlerFunc, opts ...HandlerOption) (remove func())
+	// Use adds middleware that wraps all registered handlers.
+	Use(mw ...Middleware)
 	// Open establishes a connection to the configured IRC server.
 	// If the connection cannot be established, "ConnectionAlreadyEstablished" will be returned
 	// as error. If a "real" error happens while trying to establish the connection, this error
 	// will be returned instead.
 	Open() (err error)
+	// OpenContext is like Open(), but gives up once the given context is done. The context
+	// only governs establishing the connection, not its lifetime.
+	OpenContext(ctx context.Context) (err error)
 	Wait()
 	io.Closer
 }
 
 type clientConnection struct {
-	state        chan ConnectionState
-	hostname     string
-	port         int
-	capabilities map[Capability]bool
-	tcpConn      net.Conn // Underlying TCP connection.
-	in           chan Message
-	out          chan Message
-	err          chan error
-	wg           sync.WaitGroup
-}
-
-type ConnectionHandler func(
-	conn *ClientConnection,
-	state <-chan ConnectionState,
-	in <-chan *Message,
-	out chan<- *Message,
-	err <-chan error,
-)
+	stateMu              sync.Mutex
+	state                chan ConnectionState
+	errMu                sync.Mutex
+	hostname             string
+	port                 int
+	capMu                sync.RWMutex
+	capabilities         map[Capability]bool // advertised capabilities, set to true if enabled.
+	capabilityValues     map[Capability]string
+	capNegotiation       *capNegotiation
+	isupport             *ISupport // features announced by the server (RPL_ISUPPORT).
+	wantedCapabilities   []Capability
+	saslMechanism        SASLMechanism
+	saslAuth             *saslAuthentication
+	registration         *Registration
+	registrationProgress *registrationProgress
+	nickMu               sync.RWMutex
+	nickname             string
+	userhost             string // our own "user@host", if known.
+	tlsConfig            *tls.Config
+	certificatePins      []string
+	servers              []Server
+	serverIndex          int
+	reconnectPolicy      *ReconnectPolicy
+	sendQueue            *sendQueue // nil, unless flood control has been enabled.
+	keepalive            *keepalive // nil, unless keepalive has been enabled.
+	transport            Transport  // nil, unless a transport has been configured explicitly.
+	proxy                *Proxy     // used for servers that don't have a proxy of their own.
+	encodings            connectionEncodings
+	joinedChannels       map[string]*joinedChannel // lowercase channel name -> channel
+	joinKeys             map[string]string         // lowercase channel name -> key of a pending JOIN
+	channels             *ChannelTracker
+	users                *UserRegistry
+	queries              queryTracker
+	batches              batchTracker
+	connMu               sync.RWMutex
+	active               bool          // true between Open() and the final shutdown of the connection.
+	closing              chan struct{} // closed once Close() has been called.
+	session              *session      // current session, nil while (re-)connecting.
+	quitMessage          string
+	quitTimeout          time.Duration
+	requestTimeout       time.Duration
+	ctx                  context.Context // cancelled once the connection has been closed down.
+	cancel               context.CancelFunc
+	dispatcher           dispatcher
+	inDisabled           bool
+	in                   chan Message
+	out                  chan Message
+	err                  chan error
+	wg                   sync.WaitGroup
+}
+
+// ClientConnectionOption can be used to customize a client connection upon its creation.
+type ClientConnectionOption func(conn *clientConnection)
+
+// WithCapabilities configures the capabilities that will be requested during the
+// capability negotiation, if they are offered by the server. Capabilities that are
+// announced later on (via "CAP NEW") will be requested as well.
+func WithCapabilities(caps ...Capability) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.wantedCapabilities = append(conn.wantedCapabilities, caps...)
+	}
+}
 
 // NewClientConnection prepares a new connection that can be used to connect to the
 // given IRC server.
-func NewClientConnection(hostname string, port int) ClientConnection {
-	return &clientConnection{
-		state:        make(chan ConnectionState, 4),
-		hostname:     hostname,
-		capabilities: make(map[Capability]bool),
-		port:         port,
-		in:           make(chan Message, connectionMsgBufSize), // from server
-		out:          make(chan Message, connectionMsgBufSize), // to server
-		err:          make(chan error),                         // message-related errors
-		wg:           sync.WaitGroup{},
+func NewClientConnection(hostname string, port int, opts ...ClientConnectionOption) ClientConnection {
+	conn := &clientConnection{
+		state:            make(chan ConnectionState, 16),
+		hostname:         hostname,
+		capabilities:     make(map[Capability]bool),
+		capabilityValues: make(map[Capability]string),
+		isupport:         NewISupport(),
+		port:             port,
+		servers:          []Server{{Hostname: hostname, Port: uint(port)}},
+		joinedChannels:   make(map[string]*joinedChannel),
+		joinKeys:         make(map[string]string),
+		channels:         NewChannelTracker(),
+		users:            NewUserRegistry(),
+		batches:          newBatchTracker(),
+		in:               make(chan Message, connectionMsgBufSize), // from server
+		out:              make(chan Message, connectionMsgBufSize), // to server
+		err:              make(chan error, 16),                     // message-related errors
+		quitTimeout:      defaultQuitTimeout,
+		requestTimeout:   defaultRequestTimeout,
+		wg:               sync.WaitGroup{},
 	}
+	for _, opt := range opts {
+		opt(conn)
+	}
+	return conn
 }
 
+// NewClientConnectionFromURL prepares a new connection to the IRC server the given URL
+// points to. For "ircs://" URLs, the connection will be established using TLS. "ws://"
+// and "wss://" URLs connect via WebSocket, "unix://" URLs connect to a Unix socket.
+// If the given URL is invalid, the returned error err will be non-nil.
+func NewClientConnectionFromURL(url URL, opts ...ClientConnectionOption) (conn ClientConnection, err error) {
+	if !url.IsValid() {
+		err = fmt.Errorf("invalid URL: %s", url)
+		return
+	}
+	if url.Protocol() == "ircs" {
+		opts = append([]ClientConnectionOption{WithTLS(nil)}, opts...)
+	}
+	server := serverFromURL(url)
+	opts = append([]ClientConnectionOption{
+		func(conn *clientConnection) {
+			conn.servers[0] = server
+		},
+	}, opts...)
+	conn = NewClientConnection(url.Hostname(), url.Port(), opts...)
+	return
+}
+
+// Open establishes the connection and negotiates the capabilities with the server
+// ("CAP LS 302"). If SASL has been configured, the authentication will be performed
+// as part of the negotiation. If a registration has been configured, the connection
+// registers itself (PASS, NICK and USER). The method returns once the negotiation
+// and the registration have been completed. If either one fails, the connection will
+// be closed and the error that caused the failure will be returned.
+// If a reconnect policy has been configured, the connection will be re-established
+// automatically whenever it gets lost after Open() has returned successfully.
 func (conn *clientConnection) Open() (err error) {
-	if conn.tcpConn != nil {
+	return conn.OpenContext(context.Background())
+}
+
+// OpenContext is like Open(), but dialing the server, the capability negotiation and the
+// registration are aborted once the given context is done, in which case the context's
+// error will be returned. The context only governs establishing the connection, use
+// Close() to shut the connection down afterwards.
+func (conn *clientConnection) OpenContext(ctx context.Context) (err error) {
+	conn.connMu.Lock()
+	if conn.active {
+		conn.connMu.Unlock()
 		err = ConnectionAlreadyEstablished
 		return
 	}
+	conn.active = true
+	conn.closing = make(chan struct{})
+	conn.ctx, conn.cancel = context.WithCancel(context.Background())
+	conn.connMu.Unlock()
+
+	if conn.registration != nil {
+		if err = conn.registration.validate(); err != nil {
+			conn.deactivate()
+			return
+		}
+	}
+	if err = validateCertificatePins(conn.certificatePins); err != nil {
+		conn.deactivate()
+		return
+	}
 
-	addr := fmt.Sprintf("%s:%d", conn.hostname, conn.port)
-	if conn.tcpConn, err = net.Dial("tcp", addr); err != nil {
-		err = fmt.Errorf("connection to IRC server %s failed: %v", addr, err)
-		conn.err <- err
+	conn.wg.Add(1)
+	var done <-chan struct{}
+	openCtx, cancel := conn.closingContext(ctx)
+	defer cancel()
+	if done, err = conn.connect(openCtx); err != nil {
+		conn.deactivate()
+		conn.wg.Done()
+		return
+	}
+	go conn.supervise(done)
+	return
+}
+
+// connect establishes a single session with the current server: The server is dialed,
+// the INPUT and OUTPUT goroutines are started, the capabilities are negotiated and the
+// connection gets registered. The returned channel done will be closed once the session
+// has ended.
+func (conn *clientConnection) connect(ctx context.Context) (done <-chan struct{}, err error) {
+	server := conn.currentServer()
+	sock, err := conn.dial(ctx, server)
+	if err != nil {
+		if ctx.Err() == nil {
+			err = fmt.Errorf("connection to IRC server %s failed: %v", net.JoinHostPort(server.Hostname, strconv.Itoa(int(server.Port))), err)
+			conn.reportError(err)
+		} else {
+			err = ctx.Err()
+		}
+		return
+	}
+
+	conn.capMu.Lock()
+	conn.capabilities = make(map[Capability]bool)
+	conn.capabilityValues = make(map[Capability]string)
+	conn.isupport = NewISupport()
+	conn.capMu.Unlock()
+	conn.capNegotiation = newCapNegotiation(conn.wantedCapabilities)
+	conn.channels.Reset()
+	conn.users.Reset()
+	conn.batches.reset()
+	conn.nickMu.Lock()
+	conn.userhost = ""
+	conn.nickMu.Unlock()
+	conn.registrationProgress = nil
+	if conn.registration != nil {
+		conn.registrationProgress = newRegistrationProgress()
+	}
+	if conn.keepalive != nil {
+		conn.keepalive.setLag(0)
+	}
+	conn.saslAuth = nil
+	if conn.saslMechanism != nil {
+		conn.saslAuth = &saslAuthentication{mechanism: conn.saslMechanism}
+	}
+
+	// Messages that could not be sent within the previous session would be sent ahead of
+	// the capability negotiation and the registration, so they are dropped.
+	for len(conn.out) > 0 {
+		<-conn.out
+	}
+
+	s := newSession(sock)
+	conn.connMu.Lock()
+	conn.session = s
+	conn.connMu.Unlock()
+	conn.wg.Add(1)
+	go conn.readLoop(s)
+	if conn.sendQueue != nil {
+		go conn.writeQueuedLoop(s)
 	} else {
+		go conn.writeLoop(s)
+	}
+	// Abort the negotiation and the registration once the context is done. The watcher
+	// has to be stopped before returning, so that it cannot close an established session.
+	stopWatching, watcherStopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watcherStopped)
+		select {
+		case <-ctx.Done():
+			s.close()
+		case <-stopWatching:
+		}
+	}()
+	defer func() {
+		close(stopWatching)
+		<-watcherStopped
+	}()
+
+	conn.out <- NewMessageWithoutPrefix(CapCommand, capSubcommandLS, CapabilityNegotiationVersion)
+	if conn.registration != nil {
+		conn.register()
+	}
+	var timedOut bool
+	if timedOut, err = conn.capNegotiation.wait(capNegotiationTimeout); timedOut {
+		if conn.saslAuth != nil {
+			err = &SASLError{Mechanism: conn.saslMechanism.Name(), Message: "timed out"}
+		} else {
+			conn.endCapNegotiation(nil)
+		}
+	}
+	if err == nil && conn.registrationProgress != nil {
+		err = conn.registrationProgress.wait(registrationTimeout)
+	}
+	if err != nil {
+		// Closing the socket terminates the INPUT goroutine, which in turn reports
+		// the connection as being closed.
+		s.close()
+		<-s.done
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return
+	}
+	if conn.keepalive != nil {
 		conn.wg.Add(1)
+		go conn.keepaliveLoop(s)
+	}
+	done = s.done
+	return
+}
 
-		// INPUT and CONNECTION CHECKS
-		go func() {
-			defer conn.wg.Done()
-			defer func() { conn.state <- ConnectionStateClosed }()
-			conn.state <- ConnectionStateOpen
-			reader := bufio.NewReader(conn.tcpConn)
-			scanner := bufio.NewScanner(reader)
-			for conn.tcpConn != nil && scanner.Scan() {
-				str := string(scanner.Text())
-				var msg Message
-				if msg, err = NewMessageFromString(str); err != nil {
-					conn.err <- err
-					continue
-				}
-
-				// Some messages will be used to trigger certain actions at this point.
-				// They will still be relayed to the "in" channel, though.
-				switch msg.Command() {
-				case WelcomeReply:
-					// Once we receive RPL_WELCOME, we can rest assured that the connection to
-					// the server has been established successfully and the USER and NICK commands
-					// have been acknowledged.
-					conn.state <- ConnectionStateReady
-				case PingCommand:
-					// PING messages will be handled directly at this point, thus a PONG reply is
-					// going to be send immediately.
-					conn.out <- NewPongMessage(EmptyPrefix, msg.Parameters()[0])
-				default:
-					break
-				}
-
-				conn.in <- msg
+// readLoop reads messages from the socket of the given session until the socket gets
+// closed. The session's done channel will be closed once the loop has ended.
+func (conn *clientConnection) readLoop(s *session) {
+	defer conn.wg.Done()
+	defer conn.notifyState(ConnectionStateClosed)
+	defer close(s.done)
+	defer func() {
+		conn.connMu.Lock()
+		if conn.session == s {
+			conn.session = nil
+		}
+		conn.connMu.Unlock()
+		s.close()
+	}()
+	defer conn.abortCapNegotiation(errConnectionLost)
+	defer conn.queries.abort(errConnectionLost)
+	if conn.registrationProgress != nil {
+		defer conn.registrationProgress.finish(errConnectionLost)
+	}
+	conn.notifyState(ConnectionStateOpen)
+	reader := bufio.NewReader(s.sock)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		str := conn.decodeLine(scanner.Bytes())
+		msg, err := NewMessageFromString(str)
+		if err != nil {
+			// Empty lines are tolerated silently.
+			if pe, ok := err.(*ParseError); !ok || pe.Reason != ParseErrorEmptyMessage {
+				conn.reportError(err)
+			}
+			continue
+		}
+		msg = NewTypedMessage(msg)
+		s.touch()
+
+		if !conn.capNegotiation.isFinished() && isCapNegotiationRejection(msg) {
+			// The server doesn't know anything about capabilities.
+			conn.abortCapNegotiation(nil)
+		}
+
+		// Some messages will be used to trigger certain actions at this point.
+		// They will still be relayed to the "in" channel, though.
+		switch msg.Command() {
+		case CapCommand:
+			conn.handleCapMessage(msg)
+		case AuthenticateCommand:
+			conn.handleAuthenticateMessage(msg)
+		case LoggedInReply, LoggedOutReply, NickLockedError, SASLSuccessReply, SASLFailError,
+			SASLTooLongError, SASLAbortedError, SASLAlreadyError, SASLMechsReply:
+			conn.handleSASLReply(msg)
+		case WelcomeReply:
+			// Once we receive RPL_WELCOME, we can rest assured that the connection to
+			// the server has been established successfully and the USER and NICK commands
+			// have been acknowledged.
+			conn.abortCapNegotiation(nil)
+			conn.handleRegistrationReply(msg)
+			conn.trackUserhost(msg)
+			conn.notifyState(ConnectionStateReady)
+		case NicknameInUseError, ErroneousNicknameError, UnavailableResourceError,
+			PasswordMismatchError, YoureBannedCreepError, ErrorCommand:
+			conn.handleRegistrationReply(msg)
+		case ISupportReply:
+			conn.capMu.Lock()
+			conn.isupport.Update(msg)
+			conn.capMu.Unlock()
+		case NickCommand:
+			conn.handleNickMessage(msg)
+		case JoinCommand, PartCommand, KickCommand:
+			if err := conn.trackJoinedChannels(msg); err != nil {
+				conn.reportError(err)
 			}
-		}()
-
-		// OUTPUT
-		go func() {
-			for conn.tcpConn != nil {
-				select {
-				case msg := <-conn.out:
-					conn.send(msg)
-				case state := <-conn.state:
-					if state != ConnectionStateReady {
-						break
-					}
-				}
+			conn.trackUserhost(msg)
+		case ModeCommand, ChannelModeIsReply, NoSuchChannelError, TooManyChannelsError, ChannelIsFullError,
+			InviteOnlyChanError, BannedFromChanError, BadChannelKeyError, BadChanMaskError:
+			if err := conn.trackJoinedChannels(msg); err != nil {
+				conn.reportError(err)
 			}
-		}()
+		case PingCommand:
+			// PING messages will be handled directly at this point, thus a PONG reply is
+			// going to be send immediately. It bypasses Out(), which may be full.
+			if ping, ok := msg.(PingMessage); ok {
+				s.sendUrgent(NewPongMessage(EmptyPrefix, ping.Server1()))
+			}
+		case PongCommand:
+			conn.handlePongMessage(s, msg)
+		default:
+			break
+		}
 
+		conn.channels.Process(msg)
+		conn.users.Process(msg)
+		conn.queries.process(msg)
+		if msg = conn.batches.process(msg); msg == nil {
+			continue
+		}
+		conn.dispatcher.dispatch(conn.ctx, msg)
+		if !conn.inDisabled {
+			select {
+			case conn.in <- msg:
+			case <-conn.closing:
+			}
+		}
 	}
+}
 
-	return
+// writeLoop transmits the messages that are queued for sending until the session has ended.
+func (conn *clientConnection) writeLoop(s *session) {
+	for {
+		select {
+		case msg := <-s.urgent:
+			if !conn.write(s, msg) {
+				return
+			}
+		case msg := <-conn.out:
+			if !conn.write(s, msg) {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write transmits a message within the given session. If the message cannot be sent,
+// the error is reported and the session is closed, just like if the connection had been
+// lost while reading. False is returned in that case.
+func (conn *clientConnection) write(s *session, msg Message) bool {
+	err := conn.send(s.sock, msg)
+	if err == nil {
+		return true
+	}
+	if !s.isClosed() {
+		s.close()
+		conn.reportError(err)
+	}
+	return false
+}
+
+// supervise waits for sessions to end and reconnects, if a reconnect policy has been configured.
+func (conn *clientConnection) supervise(done <-chan struct{}) {
+	defer conn.wg.Done()
+	defer conn.dispatcher.wait()
+	defer conn.deactivate()
+	for done != nil {
+		<-done
+		done = conn.reconnect()
+	}
+}
+
+// deactivate marks the connection as being closed for good, so that it may be opened again.
+// Handlers will be notified by cancelling their context.
+func (conn *clientConnection) deactivate() {
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+	conn.active = false
+	conn.cancel()
+}
+
+// isClosing checks, if Close() has been called.
+func (conn *clientConnection) isClosing() bool {
+	select {
+	case <-conn.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// reportError relays an error to the Err() channel without ever blocking. If nobody
+// consumes the errors, the oldest ones are being dropped.
+func (conn *clientConnection) reportError(err error) {
+	conn.errMu.Lock()
+	defer conn.errMu.Unlock()
+	for {
+		select {
+		case conn.err <- err:
+			return
+		default:
+			select {
+			case <-conn.err:
+			default:
+			}
+		}
+	}
 }
 
 func (conn *clientConnection) Hostname() string {
+	conn.connMu.RLock()
+	defer conn.connMu.RUnlock()
 	return conn.hostname
 }
 
 func (conn *clientConnection) Port() int {
+	conn.connMu.RLock()
+	defer conn.connMu.RUnlock()
 	return conn.port
 }
 
+// ServerInfo returns a snapshot of what is known about the server.
+func (conn *clientConnection) ServerInfo() *ServerInfo {
+	srv := NewServerInfo(conn.Hostname(), conn.Port())
+	conn.capMu.RLock()
+	defer conn.capMu.RUnlock()
+	srv.capabilities = make(map[Capability]bool, len(conn.capabilities))
+	for c, enabled := range conn.capabilities {
+		srv.capabilities[c] = enabled
+	}
+	srv.isupport = conn.isupport.Clone()
+	return srv
+}
+
+// Casemapping returns the casemapping that the server has announced.
+func (conn *clientConnection) Casemapping() Casemapping {
+	conn.capMu.RLock()
+	defer conn.capMu.RUnlock()
+	return conn.isupport.Casemapping
+}
+
 func (conn *clientConnection) State() <-chan ConnectionState {
 	return conn.state
 }
@@ -197,6 +652,8 @@ func (conn *clientConnection) Err() <-chan error {
 }
 
 // Wait will block (thus: wait) until the connection has been closed down.
+// If a reconnect policy has been configured, this will not happen before Close()
+// has been called or all reconnect attempts have failed.
 func (conn *clientConnection) Wait() {
 	conn.wg.Wait()
 }
@@ -205,33 +662,26 @@ func (conn *clientConnection) Wait() {
 // There is usually no need to call this method directly, because the provided
 // channel, which can be accessed by the "Out()" method offers a much better
 // way to dispatch messages.
-func (conn *clientConnection) send(msg Message) (err error) {
-	str := fmt.Sprintf("%s\r\n", msg.String())
-	if _, err = fmt.Fprint(conn.tcpConn, str); err != nil {
-		err = fmt.Errorf("could not send message: %v", err)
+func (conn *clientConnection) send(sock net.Conn, msg Message) (err error) {
+	conn.trackJoinKeys(msg)
+	line := msg.String()
+	// Legacy encodings would be mangled by transports that only carry UTF-8.
+	if ws, ok := sock.(*webSocketConn); !ok || !ws.isUTF8Only() {
+		line = conn.encodeLine(msg, line)
 	}
-	return
-}
-
-// Close closes a client connection.
-// If the connection has already been closed, no attempt to
-// close the connection (again) will be made.
-// If the underlying TCP socket cannot be closed, the error
-// that has been returned from attempting to close the socket
-// will be returned and should be handled by the caller.
-func (conn *clientConnection) Close() (err error) {
-	conn.state <- ConnectionStateClosing
-	if conn.tcpConn != nil {
-		err = conn.tcpConn.Close()
-		conn.state <- ConnectionStateClosed
+	str := fmt.Sprintf("%s\r\n", line)
+	if _, err = fmt.Fprint(sock, str); err != nil {
+		err = fmt.Errorf("could not send message: %v", err)
 	}
-	conn.tcpConn = nil
 	return
 }
 
 // Capabilities lists all the capabilities that the
-// server reports as being supported.
+// server reports as being supported and that have been
+// enabled for this connection.
 func (conn *clientConnection) Capabilities() []Capability {
+	conn.capMu.RLock()
+	defer conn.capMu.RUnlock()
 	caps := make([]Capability, len(conn.capabilities))
 	i := 0
 	act := 0
@@ -245,8 +695,19 @@ func (conn *clientConnection) Capabilities() []Capability {
 	return caps[:act]
 }
 
-// HasCapability checks if the server that the client is connected to supports a given capability.
+// HasCapability checks if the server that the client is connected to supports a given capability
+// and if the capability has been enabled for the connection.
 func (conn *clientConnection) HasCapability(capability Capability) bool {
+	conn.capMu.RLock()
+	defer conn.capMu.RUnlock()
 	v, e := conn.capabilities[capability]
 	return e && v
 }
+
+// CapabilityValue returns the value of a capability as advertised by the server.
+func (conn *clientConnection) CapabilityValue(capability Capability) (value string, ok bool) {
+	conn.capMu.RLock()
+	defer conn.capMu.RUnlock()
+	value, ok = conn.capabilityValues[capability]
+	return
+}
@@ -69,8 +69,10 @@ func TestConnection__IRCD_Integration(t *testing.T) {
 				case s := <-conn.State():
 					t.Logf("ClientConnection state changed: %v\n", s)
 					if s == ConnectionStateOpen {
-						conn.Out() <- NickMessage("johndoe")
-						conn.Out() <- NewUserMessage(EmptyPrefix, "j.doe", "John Doe")
+						nick, _ := NewNickMessage(EmptyPrefix, "johndoe")
+						conn.Out() <- nick
+						user, _ := NewUserMessage(EmptyPrefix, "j.doe", "John Doe")
+						conn.Out() <- user
 					}
 				}
 			}
@@ -20,11 +20,26 @@ type ClientPreferences struct {
 
 type Network struct {
 	Servers []Server `json:"servers"`
+	// Proxy is used to reach all the servers of the network that don't have a proxy of
+	// their own.
+	Proxy *Proxy `json:"proxy,omitempty"`
+	// Encoding is the legacy encoding used on the network (e.g. "latin1"), see WithEncoding().
+	Encoding string `json:"encoding,omitempty"`
+	// ChannelEncodings maps channel names to their legacy encodings, see WithChannelEncoding().
+	ChannelEncodings map[string]string `json:"channelEncodings,omitempty"`
 }
 
 type Server struct {
 	Hostname string `json:"hostname"`
 	Port     uint   `json:"port"`
+	TLS      bool   `json:"tls,omitempty"`
+	// Transport selects how the server is being reached (TransportTCP, TransportUnix or
+	// TransportWebSocket). Defaults to TransportTCP.
+	Transport string `json:"transport,omitempty"`
+	// Path is the path of the Unix socket or the resource of the WebSocket endpoint.
+	Path string `json:"path,omitempty"`
+	// Proxy is used to reach the server. Overrides the proxy of the network.
+	Proxy *Proxy `json:"proxy,omitempty"`
 }
 
 // NewPreferences creates a new (and empty) structure for holding preferences.
@@ -13,4 +13,7 @@ func TestConfiguration_ReadFile(t *testing.T) {
 	if p.JsonSchema != JsonSchemaUrl {
 		t.Errorf(`unexpected JSON schema URI encountered: "%s"`, p.JsonSchema)
 	}
+	if proxy := p.Networks["freenode"].Proxy; proxy == nil || proxy.Type != ProxySOCKS5 || proxy.Port != 1080 {
+		t.Errorf("unexpected proxy configuration: %+v", proxy)
+	}
 }
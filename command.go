@@ -1,33 +1,81 @@
 package irc
 
-import "regexp"
+import (
+	"regexp"
+	"strconv"
+)
 
 type Command string
 
 // replyCommandRegexp is a regular expression that can be used to check if a
 // given command is a (numeric) IRC message reply code.
-var replyCommandRegexp = regexp.MustCompile("\\d{3}")
+var replyCommandRegexp = regexp.MustCompile("\\A\\d{3}\\z")
 
+// Commands as defined by RfC-2812 (and a few IRCv3 extensions).
 const (
-	JoinCommand Command = "JOIN"
-	NickCommand Command = "NICK"
-	OperCommand Command = "OPER"
-	PassCommand Command = "PASS"
-	PingCommand Command = "PING"
-	PongCommand Command = "PONG"
-	UserCommand Command = "USER"
-	QuitCommand Command = "QUIT"
+	AccountCommand      Command = "ACCOUNT"
+	AckCommand          Command = "ACK"
+	AdminCommand        Command = "ADMIN"
+	AuthenticateCommand Command = "AUTHENTICATE"
+	AwayCommand         Command = "AWAY"
+	BatchCommand        Command = "BATCH"
+	CapCommand          Command = "CAP"
+	ChghostCommand      Command = "CHGHOST"
+	ConnectCommand      Command = "CONNECT"
+	DieCommand          Command = "DIE"
+	ErrorCommand        Command = "ERROR"
+	InfoCommand         Command = "INFO"
+	InviteCommand       Command = "INVITE"
+	IsOnCommand         Command = "ISON"
+	JoinCommand         Command = "JOIN"
+	KickCommand         Command = "KICK"
+	KillCommand         Command = "KILL"
+	LinksCommand        Command = "LINKS"
+	ListCommand         Command = "LIST"
+	LUsersCommand       Command = "LUSERS"
+	ModeCommand         Command = "MODE"
+	MonitorCommand      Command = "MONITOR"
+	MotdCommand         Command = "MOTD"
+	NamesCommand        Command = "NAMES"
+	NickCommand         Command = "NICK"
+	NoticeCommand       Command = "NOTICE"
+	OperCommand         Command = "OPER"
+	PartCommand         Command = "PART"
+	PassCommand         Command = "PASS"
+	PingCommand         Command = "PING"
+	PongCommand         Command = "PONG"
+	PrivmsgCommand      Command = "PRIVMSG"
+	QuitCommand         Command = "QUIT"
+	RehashCommand       Command = "REHASH"
+	RestartCommand      Command = "RESTART"
+	ServiceCommand      Command = "SERVICE"
+	ServListCommand     Command = "SERVLIST"
+	SetnameCommand      Command = "SETNAME"
+	SQueryCommand       Command = "SQUERY"
+	SQuitCommand        Command = "SQUIT"
+	StatsCommand        Command = "STATS"
+	SummonCommand       Command = "SUMMON"
+	TimeCommand         Command = "TIME"
+	TopicCommand        Command = "TOPIC"
+	TraceCommand        Command = "TRACE"
+	UserCommand         Command = "USER"
+	UserHostCommand     Command = "USERHOST"
+	UsersCommand        Command = "USERS"
+	VersionCommand      Command = "VERSION"
+	WallopsCommand      Command = "WALLOPS"
+	WhoCommand          Command = "WHO"
+	WhoisCommand        Command = "WHOIS"
+	WhowasCommand       Command = "WHOWAS"
 )
 
 // Numerics in the range from 001 to 099 are used for client-server
 // connections only and should never travel between servers.  Replies
 // generated in the response to commands are found in the range from 200
 // to 399.
-//
-// The server sends Replies 001 to 004 to a user upon
-// successful registration.
 const (
-
+	// The server sends Replies 001 to 004 to a user upon
+	// successful registration.
+	//
 	// "Welcome to the Internet Relay Network
 	// <nick>!<user>@<host>"
 	WelcomeReply Command = "001"
@@ -39,6 +87,7 @@ const (
 	CreatedReply Command = "003"
 
 	// "<servername> <version> <available user modes>
+	// <available channel modes>"
 	MyInfoReply Command = "004"
 
 	// Sent by the server to a user to suggest an alternative
@@ -46,6 +95,9 @@ const (
 	// refused because the server is already full.
 	//
 	// "Try server <server name>, port <port number>"
+	//
+	// Deprecated: Modern servers use this numeric for RPL_ISUPPORT instead,
+	// see ISupportReply.
 	BounceReply Command = "005"
 
 	// Reply format used by USERHOST to list replies to
@@ -61,8 +113,1143 @@ const (
 	//
 	// ":*1<reply> *( " " <reply> )"
 	UserHostReply Command = "302"
+
+	// Reply format used by ISON to list replies to the
+	// query list.
+	//
+	// ":*1<nick> *( " " <nick> )"
+	IsOnReply Command = "303"
+
+	// These replies are used with the AWAY command (if
+	// allowed).  RPL_AWAY is sent to any client sending a
+	// PRIVMSG to a client which is away.  RPL_AWAY is only
+	// sent by the server to which the client is connected.
+	// Replies RPL_UNAWAY and RPL_NOWAWAY are sent when the
+	// client removes and sets an AWAY message.
+	//
+	// "<nick> :<away message>"
+	AwayReply Command = "301"
+
+	// ":You are no longer marked as being away"
+	UnAwayReply Command = "305"
+
+	// ":You have been marked as being away"
+	NowAwayReply Command = "306"
+
+	// Replies 311 - 313, 317 - 319 are all replies
+	// generated in response to a WHOIS message.  Given that
+	// there are enough parameters present, the answering
+	// server MUST either formulate a reply out of the above
+	// numerics (if the query nick is found) or return an
+	// error reply.  The '*' in RPL_WHOISUSER is there as
+	// the literal character and not as a wild card.  For
+	// each reply set, only RPL_WHOISCHANNELS may appear
+	// more than once (for long lists of channel names).
+	// The '@' and '+' characters next to the channel name
+	// indicate whether a client is a channel operator or
+	// has been granted permission to speak on a moderated
+	// channel.  The RPL_ENDOFWHOIS reply is used to mark
+	// the end of processing a WHOIS message.
+	//
+	// "<nick> <user> <host> * :<real name>"
+	WhoisUserReply Command = "311"
+
+	// "<nick> <server> :<server info>"
+	WhoisServerReply Command = "312"
+
+	// "<nick> :is an IRC operator"
+	WhoisOperatorReply Command = "313"
+
+	// "<nick> <integer> :seconds idle"
+	WhoisIdleReply Command = "317"
+
+	// "<nick> :End of WHOIS list"
+	EndOfWhoisReply Command = "318"
+
+	// "<nick> :*( ( "@" / "+" ) <channel> " " )"
+	WhoisChannelsReply Command = "319"
+
+	// When replying to a WHOWAS message, a server MUST use
+	// the replies RPL_WHOWASUSER, RPL_WHOISSERVER or
+	// ERR_WASNOSUCHNICK for each nickname in the presented
+	// list.  At the end of all reply batches, there MUST
+	// be RPL_ENDOFWHOWAS (even if there was only one reply
+	// and it was an error).
+	//
+	// "<nick> <user> <host> * :<real name>"
+	WhowasUserReply Command = "314"
+
+	// "<nick> :End of WHOWAS"
+	EndOfWhowasReply Command = "369"
+
+	// Replies RPL_LIST, RPL_LISTEND mark the actual replies
+	// with data and end of the server's response to a LIST
+	// command.  If there are no channels available to return,
+	// only the end reply MUST be sent.
+	//
+	// Obsolete. Not used.
+	ListStartReply Command = "321"
+
+	// "<channel> <# visible> :<topic>"
+	ListReply Command = "322"
+
+	// ":End of LIST"
+	ListEndReply Command = "323"
+
+	// When sending a TOPIC message to determine the
+	// channel topic, one of two replies is sent.  If
+	// the topic is set, RPL_TOPIC is sent back else
+	// RPL_NOTOPIC.
+	//
+	// "<channel> <nickname>"
+	UniqOpIsReply Command = "325"
+
+	// "<channel> <mode> <mode params>"
+	ChannelModeIsReply Command = "324"
+
+	// "<channel> :No topic is set"
+	NoTopicReply Command = "331"
+
+	// "<channel> :<topic>"
+	TopicReply Command = "332"
+
+	// Returned by the server to indicate that the
+	// attempted INVITE message was successful and is
+	// being passed onto the end client.
+	//
+	// "<channel> <nick>"
+	InvitingReply Command = "341"
+
+	// Returned by a server answering a SUMMON message to
+	// indicate that it is summoning that user.
+	//
+	// "<user> :Summoning user to IRC"
+	SummoningReply Command = "342"
+
+	// When listing the 'invitations masks' for a given channel,
+	// a server is required to send the list back using the
+	// RPL_INVITELIST and RPL_ENDOFINVITELIST messages.  A
+	// separate RPL_INVITELIST is sent for each active mask.
+	// After the masks have been listed (or if none present) a
+	// RPL_ENDOFINVITELIST MUST be sent.
+	//
+	// "<channel> <invitemask>"
+	InviteListReply Command = "346"
+
+	// "<channel> :End of channel invite list"
+	EndOfInviteListReply Command = "347"
+
+	// When listing the 'exception masks' for a given channel,
+	// a server is required to send the list back using the
+	// RPL_EXCEPTLIST and RPL_ENDOFEXCEPTLIST messages.  A
+	// separate RPL_EXCEPTLIST is sent for each active mask.
+	// After the masks have been listed (or if none present)
+	// a RPL_ENDOFEXCEPTLIST MUST be sent.
+	//
+	// "<channel> <exceptionmask>"
+	ExceptListReply Command = "348"
+
+	// "<channel> :End of channel exception list"
+	EndOfExceptListReply Command = "349"
+
+	// Reply by the server showing its version details.
+	// The <version> is the version of the software being
+	// used (including any patchlevel revisions) and the
+	// <debuglevel> is used to indicate if the server is
+	// running in "debug mode".
+	//
+	// The "comments" field may contain any comments about
+	// the version or further version details.
+	//
+	// "<version>.<debuglevel> <server> :<comments>"
+	VersionReply Command = "351"
+
+	// The RPL_WHOREPLY and RPL_ENDOFWHO pair are used
+	// to answer a WHO message.  The RPL_WHOREPLY is only
+	// sent if there is an appropriate match to the WHO
+	// query.  If there is a list of parameters supplied
+	// with a WHO message, a RPL_ENDOFWHO MUST be sent
+	// after processing each list item with <name> being
+	// the item.
+	//
+	// "<channel> <user> <host> <server> <nick>
+	// ( "H" / "G" > ["*"] [ ( "@" / "+" ) ]
+	// :<hopcount> <real name>"
+	WhoReply Command = "352"
+
+	// "<name> :End of WHO list"
+	EndOfWhoReply Command = "315"
+
+	// "@" is used for secret channels, "*" for private
+	// channels, and "=" for others (public channels).
+	//
+	// "( "=" / "*" / "@" ) <channel>
+	// :[ "@" / "+" ] <nick> *( " " [ "@" / "+" ] <nick> )
+	NamesReply Command = "353"
+
+	// To reply to a NAMES message, a reply pair consisting
+	// of RPL_NAMREPLY and RPL_ENDOFNAMES is sent by the
+	// server back to the client.  If there is no channel
+	// found as in the query, then only RPL_ENDOFNAMES is
+	//
+	// returned.  The exception to this is when a NAMES
+	// message is sent with no parameters and all visible
+	// channels and contents are sent back in a series of
+	// RPL_NAMEREPLY messages with a RPL_ENDOFNAMES to mark
+	// the end.
+	//
+	// "<channel> :End of NAMES list"
+	EndOfNamesReply Command = "366"
+
+	// In replying to the LINKS message, a server MUST send
+	// replies back using the RPL_LINKS numeric and mark the
+	// end of the list using an RPL_ENDOFLINKS reply.
+	//
+	// "<mask> <server> :<hopcount> <server info>"
+	LinksReply Command = "364"
+
+	// "<mask> :End of LINKS list"
+	EndOfLinksReply Command = "365"
+
+	// When listing the active 'bans' for a given channel,
+	// a server is required to send the list back using the
+	// RPL_BANLIST and RPL_ENDOFBANLIST messages.  A separate
+	// RPL_BANLIST is sent for each active banmask.  After the
+	// banmasks have been listed (or if none present) a
+	// RPL_ENDOFBANLIST MUST be sent.
+	//
+	// "<channel> <banmask>"
+	BanListReply Command = "367"
+
+	// "<channel> :End of channel ban list"
+	EndOfBanListReply Command = "368"
+
+	// A server responding to an INFO message is required to
+	// send all its 'info' in a series of RPL_INFO messages
+	// with a RPL_ENDOFINFO reply to indicate the end of the
+	// replies.
+	//
+	// ":<string>"
+	InfoReply Command = "371"
+
+	// ":End of INFO list"
+	EndOfInfoReply Command = "374"
+
+	// When responding to the MOTD message and the MOTD file
+	// is found, the file is displayed line by line, with
+	// each line no longer than 80 characters, using
+	//
+	// RPL_MOTD format replies.  These MUST be surrounded
+	// by a RPL_MOTDSTART (before the RPL_MOTDs) and an
+	// RPL_ENDOFMOTD (after).
+	//
+	// ":- <server> Message of the day - "
+	MotdStartReply Command = "375"
+
+	// ":- <text>"
+	MotdReply Command = "372"
+
+	// ":End of MOTD command"
+	EndOfMotdReply Command = "376"
+
+	// RPL_YOUREOPER is sent back to a client which has
+	// just successfully issued an OPER message and gained
+	// operator status.
+	//
+	// ":You are now an IRC operator"
+	YoureOperReply Command = "381"
+
+	// If the REHASH option is used and an operator sends
+	// a REHASH message, an RPL_REHASHING is sent back to
+	// the operator.
+	//
+	// "<config file> :Rehashing"
+	RehashingReply Command = "382"
+
+	// Sent by the server to a service upon successful
+	// registration.
+	//
+	// "You are service <servicename>"
+	YoureServiceReply Command = "383"
+
+	// When replying to the TIME message, a server MUST send
+	// the reply using the RPL_TIME format above.  The string
+	// showing the time need only contain the correct day and
+	// time there.  There is no further requirement for the
+	// time string.
+	//
+	// "<server> :<string showing server's local time>"
+	TimeReply Command = "391"
+
+	// If the USERS message is handled by a server, the
+	// replies RPL_USERSTART, RPL_USERS, RPL_ENDOFUSERS and
+	// RPL_NOUSERS are used.  RPL_USERSSTART MUST be sent
+	// first, following by either a sequence of RPL_USERS
+	// or a single RPL_NOUSER.  Following this is
+	// RPL_ENDOFUSERS.
+	//
+	// ":UserID   Terminal  Host"
+	UsersStartReply Command = "392"
+
+	// ":<username> <ttyline> <hostname>"
+	UsersReply Command = "393"
+
+	// ":End of users"
+	EndOfUsersReply Command = "394"
+
+	// ":Nobody logged in"
+	NoUsersReply Command = "395"
+
+	// The RPL_TRACE* are all returned by the server in
+	// response to the TRACE message.  How many are
+	// returned is dependent on the TRACE message and
+	// whether it was sent by an operator or not.  There
+	// is no predefined order for which occurs first.
+	// Replies RPL_TRACEUNKNOWN, RPL_TRACECONNECTING and
+	// RPL_TRACEHANDSHAKE are all used for connections
+	// which have not been fully established and are either
+	// unknown, still attempting to connect or in the
+	// process of completing the 'server handshake'.
+	// RPL_TRACELINK is sent by any server which handles
+	// a TRACE message and has to pass it on to another
+	// server.  The list of RPL_TRACELINKs sent in
+	// response to a TRACE command traversing the IRC
+	// network should reflect the actual connectivity of
+	// the servers themselves along that path.
+	//
+	// RPL_TRACENEWTYPE is to be used for any connection
+	// which does not fit in the other categories but is
+	// being displayed anyway.
+	// RPL_TRACEEND is sent to indicate the end of the list.
+	//
+	// "Link <version & debug level> <destination>
+	// <next server> V<protocol version>
+	// <link uptime in seconds> <backstream sendq>
+	// <upstream sendq>"
+	TraceLinkReply Command = "200"
+
+	// "Try. <class> <server>"
+	TraceConnectingReply Command = "201"
+
+	// "H.S. <class> <server>"
+	TraceHandshakeReply Command = "202"
+
+	// "???? <class> [<client IP address in dot form>]"
+	TraceUnknownReply Command = "203"
+
+	// "Oper <class> <nick>"
+	TraceOperatorReply Command = "204"
+
+	// "User <class> <nick>"
+	TraceUserReply Command = "205"
+
+	// "Serv <class> <int>S <int>C <server>
+	// <nick!user|*!*>@<host|server> V<protocol version>"
+	TraceServerReply Command = "206"
+
+	// "Service <class> <name> <type> <active type>"
+	TraceServiceReply Command = "207"
+
+	// "<newtype> 0 <client name>"
+	TraceNewTypeReply Command = "208"
+
+	// "Class <class> <count>"
+	TraceClassReply Command = "209"
+
+	// Unused.
+	TraceReconnectReply Command = "210"
+
+	// "File <logfile> <debug level>"
+	TraceLogReply Command = "261"
+
+	// "<server name> <version & debug level> :End of TRACE"
+	TraceEndReply Command = "262"
+
+	// reports statistics on a connection.  <linkname>
+	// identifies the particular connection, <sendq> is
+	// the amount of data that is queued and waiting to be
+	// sent <sent messages> the number of messages sent,
+	// and <sent Kbytes> the amount of data sent, in
+	// Kbytes. <received messages> and <received Kbytes>
+	// are the equivalent of <sent messages> and <sent
+	// Kbytes> for received data, respectively.  <time
+	// open> indicates how long ago the connection was
+	// opened, in seconds.
+	//
+	// "<linkname> <sendq> <sent messages>
+	// <sent Kbytes> <received messages>
+	// <received Kbytes> <time open>"
+	StatsLinkInfoReply Command = "211"
+
+	// reports statistics on commands usage.
+	//
+	// "<command> <count> <byte count> <remote count>"
+	StatsCommandsReply Command = "212"
+
+	// reports the server uptime.
+	//
+	// "<stats letter> :End of STATS report"
+	EndOfStatsReply Command = "219"
+
+	// ":Server Up %d days %d:%02d:%02d"
+	StatsUptimeReply Command = "242"
+
+	// reports the allowed hosts from where user may become IRC
+	// operators.
+	//
+	// "O <hostmask> * <name>"
+	StatsOLineReply Command = "243"
+
+	// To answer a query about a client's own mode,
+	// RPL_UMODEIS is sent back.
+	//
+	// "<user mode string>"
+	UModeIsReply Command = "221"
+
+	// When listing services in reply to a SERVLIST message,
+	// a server is required to send the list back using the
+	// RPL_SERVLIST and RPL_SERVLISTEND messages.  A separate
+	// RPL_SERVLIST is sent for each service.  After the
+	// services have been listed (or if none present) a
+	// RPL_SERVLISTEND MUST be sent.
+	//
+	// "<name> <server> <mask> <type> <hopcount> <info>"
+	ServListReply Command = "234"
+
+	// "<mask> <type> :End of service listing"
+	ServListEndReply Command = "235"
+
+	// In processing an LUSERS message, the server
+	// sends a set of replies from RPL_LUSERCLIENT,
+	// RPL_LUSEROP, RPL_USERUNKNOWN,
+	// RPL_LUSERCHANNELS and RPL_LUSERME.  When
+	// replying, a server MUST send back
+	// RPL_LUSERCLIENT and RPL_LUSERME.  The other
+	// replies are only sent back if a non-zero count
+	// is found for them.
+	//
+	// ":There are <integer> users and <integer>
+	// services on <integer> servers"
+	LUserClientReply Command = "251"
+
+	// "<integer> :operator(s) online"
+	LUserOpReply Command = "252"
+
+	// "<integer> :unknown connection(s)"
+	LUserUnknownReply Command = "253"
+
+	// "<integer> :channels formed"
+	LUserChannelsReply Command = "254"
+
+	// ":I have <integer> clients and <integer>
+	// servers"
+	LUserMeReply Command = "255"
+
+	// When replying to an ADMIN message, a server
+	// is expected to use replies RPL_ADMINME
+	// through to RPL_ADMINEMAIL and provide a text
+	// message with each.  For RPL_ADMINLOC1 a
+	// description of what city, state and country
+	// the server is in is expected, followed by
+	// details of the institution (RPL_ADMINLOC2)
+	//
+	// and finally the administrative contact for the
+	// server (an email address here is REQUIRED)
+	// in RPL_ADMINEMAIL.
+	//
+	// "<server> :Administrative info"
+	AdminMeReply Command = "256"
+
+	// ":<admin info>"
+	AdminLoc1Reply Command = "257"
+
+	// ":<admin info>"
+	AdminLoc2Reply Command = "258"
+
+	// ":<admin info>"
+	AdminEmailReply Command = "259"
+
+	// When a server drops a command without processing it,
+	// it MUST use the reply RPL_TRYAGAIN to inform the
+	// originating client.
+	//
+	// "<command> :Please wait a while and try again."
+	TryAgainReply Command = "263"
 )
 
+// Error replies are found in the range from 400 to 599.
+const (
+	// Used to indicate the nickname parameter supplied to a
+	// command is currently unused.
+	//
+	// "<nickname> :No such nick/channel"
+	NoSuchNickError Command = "401"
+
+	// Used to indicate the server name given currently
+	// does not exist.
+	//
+	// "<server name> :No such server"
+	NoSuchServerError Command = "402"
+
+	// Used to indicate the given channel name is invalid.
+	//
+	// "<channel name> :No such channel"
+	NoSuchChannelError Command = "403"
+
+	// Sent to a user who is either (a) not on a channel
+	// which is mode +n or (b) not a chanop (or mode +v) on
+	// a channel which has mode +m set or where the user is
+	// banned and is trying to send a PRIVMSG message to
+	// that channel.
+	//
+	// "<channel name> :Cannot send to channel"
+	CannotSendToChanError Command = "404"
+
+	// Sent to a user when they have joined the maximum
+	// number of allowed channels and they try to join
+	// another channel.
+	//
+	// "<channel name> :You have joined too many channels"
+	TooManyChannelsError Command = "405"
+
+	// Returned by WHOWAS to indicate there is no history
+	// information for that nickname.
+	//
+	// "<nickname> :There was no such nickname"
+	WasNoSuchNickError Command = "406"
+
+	// Returned to a client which is attempting to send a
+	// PRIVMSG/NOTICE using the user@host destination format
+	// and for a user@host which has several occurrences.
+	//
+	// - Returned to a client which trying to send a
+	// PRIVMSG/NOTICE to too many recipients.
+	//
+	// - Returned to a client which is attempting to JOIN a safe
+	// channel using the shortname when there are more than one
+	// such channel.
+	//
+	// "<target> :<error code> recipients. <abort message>"
+	TooManyTargetsError Command = "407"
+
+	// Returned to a client which is attempting to send a SQUERY
+	// to a service which does not exist.
+	//
+	// "<service name> :No such service"
+	NoSuchServiceError Command = "408"
+
+	// PING or PONG message missing the originator parameter.
+	//
+	// ":No origin specified"
+	NoOriginError Command = "409"
+
+	// Indicates that a CAP command was issued with an invalid subcommand.
+	//
+	// "<subcommand> :Invalid CAP command"
+	InvalidCapCommandError Command = "410"
+
+	// 412 - 415 are returned by PRIVMSG to indicate that
+	// the message wasn't delivered for some reason.
+	// ERR_NOTOPLEVEL and ERR_WILDTOPLEVEL are errors that
+	// are returned when an invalid use of
+	// "PRIVMSG $<server>" or "PRIVMSG #<host>" is attempted.
+	//
+	// ":No recipient given (<command>)"
+	NoRecipientError Command = "411"
+
+	// ":No text to send"
+	NoTextToSendError Command = "412"
+
+	// "<mask> :No toplevel domain specified"
+	NoTopLevelError Command = "413"
+
+	// "<mask> :Wildcard in toplevel domain"
+	WildTopLevelError Command = "414"
+
+	// "<mask> :Bad Server/host mask"
+	BadMaskError Command = "415"
+
+	// Returned to a registered client to indicate that the
+	// command sent is unknown by the server.
+	//
+	// "<command> :Unknown command"
+	UnknownCommandError Command = "421"
+
+	// Server's MOTD file could not be opened by the server.
+	//
+	// ":MOTD File is missing"
+	NoMotdError Command = "422"
+
+	// Returned by a server in response to an ADMIN message
+	// when there is an error in finding the appropriate
+	// information.
+	//
+	// "<server> :No administrative info available"
+	NoAdminInfoError Command = "423"
+
+	// Generic error message used to report a failed file
+	// operation during the processing of a message.
+	//
+	// ":File error doing <file op> on <file>"
+	FileError Command = "424"
+
+	// Returned when a nickname parameter expected for a
+	// command and isn't found.
+	//
+	// ":No nickname given"
+	NoNicknameGivenError Command = "431"
+
+	// Returned after receiving a NICK message which contains
+	// characters which do not fall in the defined set.  See
+	// section 2.3.1 for details on valid nicknames.
+	//
+	// "<nick> :Erroneous nickname"
+	ErroneousNicknameError Command = "432"
+
+	// Returned when a NICK message is processed that results
+	// in an attempt to change to a currently existing
+	// nickname.
+	//
+	// "<nick> :Nickname is already in use"
+	NicknameInUseError Command = "433"
+
+	// Returned by a server to a client when it detects a
+	// nickname collision (registered of a NICK that
+	// already exists by another server).
+	//
+	// "<nick> :Nickname collision KILL from <user>@<host>"
+	NickCollisionError Command = "436"
+
+	// Returned by a server to a user trying to join a channel
+	// currently blocked by the channel delay mechanism.
+	//
+	// - Returned by a server to a user trying to change nickname
+	// when the desired nickname is blocked by the nick delay
+	// mechanism.
+	//
+	// "<nick/channel> :Nick/channel is temporarily unavailable"
+	UnavailableResourceError Command = "437"
+
+	// Returned by the server to indicate that the target
+	// user of the command is not on the given channel.
+	//
+	// "<nick> <channel> :They aren't on that channel"
+	UserNotInChannelError Command = "441"
+
+	// Returned by the server whenever a client tries to
+	// perform a channel affecting command for which the
+	// client isn't a member.
+	//
+	// "<channel> :You're not on that channel"
+	NotOnChannelError Command = "442"
+
+	// Returned when a client tries to invite a user to a
+	// channel they are already on.
+	//
+	// "<user> <channel> :is already on channel"
+	UserOnChannelError Command = "443"
+
+	// Returned by the summon after a SUMMON command for a
+	// user was unable to be performed since they were not
+	// logged in.
+	//
+	// "<user> :User not logged in"
+	NoLoginError Command = "444"
+
+	// Returned as a response to the SUMMON command.  MUST be
+	// returned by any server which doesn't implement it.
+	//
+	// ":SUMMON has been disabled"
+	SummonDisabledError Command = "445"
+
+	// Returned as a response to the USERS command.  MUST be
+	// returned by any server which does not implement it.
+	//
+	// ":USERS has been disabled"
+	UsersDisabledError Command = "446"
+
+	// Returned by the server to indicate that the client
+	// MUST be registered before the server will allow it
+	// to be parsed in detail.
+	//
+	// ":You have not registered"
+	NotRegisteredError Command = "451"
+
+	// Returned by the server by numerous commands to
+	// indicate to the client that it didn't supply enough
+	// parameters.
+	//
+	// "<command> :Not enough parameters"
+	NeedMoreParamsError Command = "461"
+
+	// Returned by the server to any link which tries to
+	// change part of the registered details (such as
+	// password or user details from second USER message).
+	//
+	// ":Unauthorized command (already registered)"
+	AlreadyRegisteredError Command = "462"
+
+	// Returned to a client which attempts to register with
+	// a server which does not been setup to allow
+	// connections from the host the attempted connection
+	// is tried.
+	//
+	// ":Your host isn't among the privileged"
+	NoPermForHostError Command = "463"
+
+	// Returned to indicate a failed attempt at registering
+	// a connection for which a password was required and
+	// was either not given or incorrect.
+	//
+	// ":Password incorrect"
+	PasswordMismatchError Command = "464"
+
+	// Returned after an attempt to connect and register
+	// yourself with a server which has been setup to
+	// explicitly deny connections to you.
+	//
+	// ":You are banned from this server"
+	YoureBannedCreepError Command = "465"
+
+	// Sent by a server to a user to inform that access to the
+	// server will soon be denied.
+	//
+	YouWillBeBannedError Command = "466"
+
+	// Any command requiring operator privileges to operate
+	// MUST return this error to indicate the attempt was
+	// unsuccessful.
+	//
+	// "<channel> :Channel key already set"
+	KeySetError Command = "467"
+
+	// "<channel> :Cannot join channel (+l)"
+	ChannelIsFullError Command = "471"
+
+	// "<char> :is unknown mode char to me for <channel>"
+	UnknownModeError Command = "472"
+
+	// "<channel> :Cannot join channel (+i)"
+	InviteOnlyChanError Command = "473"
+
+	// "<channel> :Cannot join channel (+b)"
+	BannedFromChanError Command = "474"
+
+	// "<channel> :Cannot join channel (+k)"
+	BadChannelKeyError Command = "475"
+
+	// "<channel> :Bad Channel Mask"
+	BadChanMaskError Command = "476"
+
+	// "<channel> :Channel doesn't support modes"
+	NoChanModesError Command = "477"
+
+	// "<channel> <char> :Channel list is full"
+	BanListFullError Command = "478"
+
+	// ":Permission Denied- You're not an IRC operator"
+	NoPrivilegesError Command = "481"
+
+	// Any command requiring 'chanop' privileges (such as
+	// MODE messages) MUST return this error if the client
+	// making the attempt is not a chanop on the specified
+	// channel.
+	//
+	// "<channel> :You're not channel operator"
+	ChanOPrivsNeededError Command = "482"
+
+	// Any attempts to use the KILL command on a server
+	// are to be refused and this error returned directly
+	// to the client.
+	//
+	// ":You can't kill a server!"
+	CantKillServerError Command = "483"
+
+	// Sent by the server to a user upon connection to indicate
+	// the restricted nature of the connection (user mode "+r").
+	//
+	// ":Your connection is restricted!"
+	RestrictedError Command = "484"
+
+	// Any MODE requiring "channel creator" privileges MUST
+	// return this error if the client making the attempt is not
+	// a chanop on the specified channel.
+	//
+	// ":You're not the original channel operator"
+	UniqOpPrivsNeededError Command = "485"
+
+	// If a client sends an OPER message and the server has
+	// not been configured to allow connections from the
+	// client's host as an operator, this error MUST be
+	// returned.
+	//
+	// ":No O-lines for your host"
+	NoOperHostError Command = "491"
+
+	// Returned by the server to indicate that a MODE
+	// message was sent with a nickname parameter and that
+	// the a mode flag sent was not recognized.
+	//
+	// ":Unknown MODE flag"
+	UModeUnknownFlagError Command = "501"
+
+	// Error sent to any user trying to view or change the
+	// user mode for a user other than themselves.
+	//
+	// ":Cannot change mode for other users"
+	UsersDontMatchError Command = "502"
+)
+
+// These numerics are not described above since they fall into one of
+// the following categories:
+//
+// 1. no longer in use;
+//
+// 2. reserved for future planned use;
+//
+// 3. in current use but are part of a non-generic 'feature' of
+// the current IRC server.
+//
+// Note that RPL_STATSHLINE and RPL_STATSSLINE share the same numeric (244).
+const (
+	ServiceInfoReply   Command = "231"
+	EndOfServicesReply Command = "232"
+	ServiceReply       Command = "233"
+	NoneReply          Command = "300"
+	WhoisChanOpReply   Command = "316"
+	KillDoneReply      Command = "361"
+	ClosingReply       Command = "362"
+	CloseEndReply      Command = "363"
+	InfoStartReply     Command = "373"
+	MyPortIsReply      Command = "384"
+	StatsCLineReply    Command = "213"
+	StatsNLineReply    Command = "214"
+	StatsILineReply    Command = "215"
+	StatsKLineReply    Command = "216"
+	StatsQLineReply    Command = "217"
+	StatsYLineReply    Command = "218"
+	StatsVLineReply    Command = "240"
+	StatsLLineReply    Command = "241"
+	StatsHLineReply    Command = "244"
+	StatsSLineReply    Command = "244"
+	StatsPingReply     Command = "246"
+	StatsBLineReply    Command = "247"
+	StatsDLineReply    Command = "250"
+	NoServiceHostError Command = "492"
+)
+
+// These numerics are not part of RfC-2812, but are widely used by modern servers.
+const (
+
+	// Sent after the registration to announce the features supported by the server.
+	//
+	// "<client> <1-13 tokens> :are supported by this server"
+	ISupportReply Command = "005"
+
+	// Sent in addition to RPL_CHANNELMODEIS to tell when the channel has been created.
+	//
+	// "<client> <channel> <creationtime>"
+	CreationTimeReply Command = "329"
+
+	// Sent as part of the WHOIS replies if the user is logged into an account.
+	//
+	// "<client> <nick> <account> :is logged in as"
+	WhoisAccountReply Command = "330"
+
+	// Sent after RPL_TOPIC to tell who has set the topic and when it has been set.
+	//
+	// "<client> <channel> <nick> <setat>"
+	TopicWhoTimeReply Command = "333"
+
+	// Sent in reply to a WHOX query (an extended WHO query). The parameters depend on the
+	// fields that have been requested.
+	//
+	// "<client> [token] [channel] [user] [ip] [host] [server] [nick] [flags] [hopcount]
+	// [idle] [account] [oplevel] [:realname]"
+	WhoSpcrplReply Command = "354"
+
+	// Sent as part of the WHOIS replies if the user is connected using TLS.
+	//
+	// "<client> <nick> :is using a secure connection"
+	WhoisSecureReply Command = "671"
+)
+
+// Numerics in the range from 730 to 734 are used by the IRCv3 MONITOR extension.
+const (
+
+	// Sent when monitored users are online.
+	//
+	// "<client> :<nick>!<user>@<host>[,<nick>!<user>@<host>]*"
+	MonOnlineReply Command = "730"
+
+	// Sent when monitored users are offline.
+	//
+	// "<client> :<nick>[,<nick>]*"
+	MonOfflineReply Command = "731"
+
+	// Sent in reply to "MONITOR L" to list the monitored nicknames.
+	//
+	// "<client> :<nick>[,<nick>]*"
+	MonListReply Command = "732"
+
+	// Sent after the list of monitored nicknames.
+	//
+	// "<client> :End of MONITOR list"
+	EndOfMonListReply Command = "733"
+
+	// Sent when the list of monitored nicknames is full.
+	//
+	// "<client> <limit> <nicks> :Monitor list is full."
+	MonListFullError Command = "734"
+)
+
+// Numerics in the range from 900 to 908 are used by the IRCv3 SASL
+// extension to report the outcome of an authentication attempt.
+const (
+
+	// Sent when the user's account name is set (whether by SASL or otherwise).
+	//
+	// "<nick> <nick>!<ident>@<host> <account> :You are now logged in as <user>"
+	LoggedInReply Command = "900"
+
+	// Sent when the user's account name is unset (whether by SASL or otherwise).
+	//
+	// "<nick> <nick>!<ident>@<host> :You are now logged out"
+	LoggedOutReply Command = "901"
+
+	// Sent when the SASL authentication fails because the account is currently locked out,
+	// held, or otherwise administratively made unavailable.
+	//
+	// "<nick> :You must use a nick assigned to you"
+	NickLockedError Command = "902"
+
+	// Sent when the SASL authentication finishes successfully.
+	//
+	// "<nick> :SASL authentication successful"
+	SASLSuccessReply Command = "903"
+
+	// Sent when the SASL authentication fails because of invalid credentials or
+	// other errors not explicitly mentioned by other numerics.
+	//
+	// "<nick> :SASL authentication failed"
+	SASLFailError Command = "904"
+
+	// Sent when the client sends an AUTHENTICATE command with a parameter that is
+	// longer than 400 bytes.
+	//
+	// "<nick> :SASL message too long"
+	SASLTooLongError Command = "905"
+
+	// Sent when the SASL authentication is aborted because the client sent an
+	// AUTHENTICATE command with "*" as parameter.
+	//
+	// "<nick> :SASL authentication aborted"
+	SASLAbortedError Command = "906"
+
+	// Sent when the client attempts to initiate SASL authentication after it has
+	// already authenticated successfully for its current session.
+	//
+	// "<nick> :You have already authenticated using SASL"
+	SASLAlreadyError Command = "907"
+
+	// Sent when the client requests a list of the server's available SASL mechanisms
+	// or when it attempts to use a mechanism that isn't supported.
+	//
+	// "<nick> <mechanisms> :are available SASL mechanisms"
+	SASLMechsReply Command = "908"
+)
+
+// numericReply describes a numeric reply within the catalogue of known numerics.
+type numericReply struct {
+	name    string
+	isError bool
+}
+
+// numericReplies maps all known numeric replies to their symbolic names (as used
+// within the specifications) and tells if they denote an error.
+var numericReplies = map[Command]numericReply{
+	WelcomeReply:             {"RPL_WELCOME", false},
+	YourHostReply:            {"RPL_YOURHOST", false},
+	CreatedReply:             {"RPL_CREATED", false},
+	MyInfoReply:              {"RPL_MYINFO", false},
+	ISupportReply:            {"RPL_ISUPPORT", false},
+	UserHostReply:            {"RPL_USERHOST", false},
+	IsOnReply:                {"RPL_ISON", false},
+	AwayReply:                {"RPL_AWAY", false},
+	UnAwayReply:              {"RPL_UNAWAY", false},
+	NowAwayReply:             {"RPL_NOWAWAY", false},
+	WhoisUserReply:           {"RPL_WHOISUSER", false},
+	WhoisServerReply:         {"RPL_WHOISSERVER", false},
+	WhoisOperatorReply:       {"RPL_WHOISOPERATOR", false},
+	WhoisIdleReply:           {"RPL_WHOISIDLE", false},
+	EndOfWhoisReply:          {"RPL_ENDOFWHOIS", false},
+	WhoisChannelsReply:       {"RPL_WHOISCHANNELS", false},
+	WhowasUserReply:          {"RPL_WHOWASUSER", false},
+	EndOfWhowasReply:         {"RPL_ENDOFWHOWAS", false},
+	ListStartReply:           {"RPL_LISTSTART", false},
+	ListReply:                {"RPL_LIST", false},
+	ListEndReply:             {"RPL_LISTEND", false},
+	UniqOpIsReply:            {"RPL_UNIQOPIS", false},
+	ChannelModeIsReply:       {"RPL_CHANNELMODEIS", false},
+	NoTopicReply:             {"RPL_NOTOPIC", false},
+	TopicReply:               {"RPL_TOPIC", false},
+	InvitingReply:            {"RPL_INVITING", false},
+	SummoningReply:           {"RPL_SUMMONING", false},
+	InviteListReply:          {"RPL_INVITELIST", false},
+	EndOfInviteListReply:     {"RPL_ENDOFINVITELIST", false},
+	ExceptListReply:          {"RPL_EXCEPTLIST", false},
+	EndOfExceptListReply:     {"RPL_ENDOFEXCEPTLIST", false},
+	VersionReply:             {"RPL_VERSION", false},
+	WhoReply:                 {"RPL_WHOREPLY", false},
+	EndOfWhoReply:            {"RPL_ENDOFWHO", false},
+	NamesReply:               {"RPL_NAMREPLY", false},
+	EndOfNamesReply:          {"RPL_ENDOFNAMES", false},
+	LinksReply:               {"RPL_LINKS", false},
+	EndOfLinksReply:          {"RPL_ENDOFLINKS", false},
+	BanListReply:             {"RPL_BANLIST", false},
+	EndOfBanListReply:        {"RPL_ENDOFBANLIST", false},
+	InfoReply:                {"RPL_INFO", false},
+	EndOfInfoReply:           {"RPL_ENDOFINFO", false},
+	MotdStartReply:           {"RPL_MOTDSTART", false},
+	MotdReply:                {"RPL_MOTD", false},
+	EndOfMotdReply:           {"RPL_ENDOFMOTD", false},
+	YoureOperReply:           {"RPL_YOUREOPER", false},
+	RehashingReply:           {"RPL_REHASHING", false},
+	YoureServiceReply:        {"RPL_YOURESERVICE", false},
+	TimeReply:                {"RPL_TIME", false},
+	UsersStartReply:          {"RPL_USERSSTART", false},
+	UsersReply:               {"RPL_USERS", false},
+	EndOfUsersReply:          {"RPL_ENDOFUSERS", false},
+	NoUsersReply:             {"RPL_NOUSERS", false},
+	TraceLinkReply:           {"RPL_TRACELINK", false},
+	TraceConnectingReply:     {"RPL_TRACECONNECTING", false},
+	TraceHandshakeReply:      {"RPL_TRACEHANDSHAKE", false},
+	TraceUnknownReply:        {"RPL_TRACEUNKNOWN", false},
+	TraceOperatorReply:       {"RPL_TRACEOPERATOR", false},
+	TraceUserReply:           {"RPL_TRACEUSER", false},
+	TraceServerReply:         {"RPL_TRACESERVER", false},
+	TraceServiceReply:        {"RPL_TRACESERVICE", false},
+	TraceNewTypeReply:        {"RPL_TRACENEWTYPE", false},
+	TraceClassReply:          {"RPL_TRACECLASS", false},
+	TraceReconnectReply:      {"RPL_TRACERECONNECT", false},
+	TraceLogReply:            {"RPL_TRACELOG", false},
+	TraceEndReply:            {"RPL_TRACEEND", false},
+	StatsLinkInfoReply:       {"RPL_STATSLINKINFO", false},
+	StatsCommandsReply:       {"RPL_STATSCOMMANDS", false},
+	EndOfStatsReply:          {"RPL_ENDOFSTATS", false},
+	StatsUptimeReply:         {"RPL_STATSUPTIME", false},
+	StatsOLineReply:          {"RPL_STATSOLINE", false},
+	UModeIsReply:             {"RPL_UMODEIS", false},
+	ServListReply:            {"RPL_SERVLIST", false},
+	ServListEndReply:         {"RPL_SERVLISTEND", false},
+	LUserClientReply:         {"RPL_LUSERCLIENT", false},
+	LUserOpReply:             {"RPL_LUSEROP", false},
+	LUserUnknownReply:        {"RPL_LUSERUNKNOWN", false},
+	LUserChannelsReply:       {"RPL_LUSERCHANNELS", false},
+	LUserMeReply:             {"RPL_LUSERME", false},
+	AdminMeReply:             {"RPL_ADMINME", false},
+	AdminLoc1Reply:           {"RPL_ADMINLOC1", false},
+	AdminLoc2Reply:           {"RPL_ADMINLOC2", false},
+	AdminEmailReply:          {"RPL_ADMINEMAIL", false},
+	TryAgainReply:            {"RPL_TRYAGAIN", false},
+	NoSuchNickError:          {"ERR_NOSUCHNICK", true},
+	NoSuchServerError:        {"ERR_NOSUCHSERVER", true},
+	NoSuchChannelError:       {"ERR_NOSUCHCHANNEL", true},
+	CannotSendToChanError:    {"ERR_CANNOTSENDTOCHAN", true},
+	TooManyChannelsError:     {"ERR_TOOMANYCHANNELS", true},
+	WasNoSuchNickError:       {"ERR_WASNOSUCHNICK", true},
+	TooManyTargetsError:      {"ERR_TOOMANYTARGETS", true},
+	NoSuchServiceError:       {"ERR_NOSUCHSERVICE", true},
+	NoOriginError:            {"ERR_NOORIGIN", true},
+	InvalidCapCommandError:   {"ERR_INVALIDCAPCMD", true},
+	NoRecipientError:         {"ERR_NORECIPIENT", true},
+	NoTextToSendError:        {"ERR_NOTEXTTOSEND", true},
+	NoTopLevelError:          {"ERR_NOTOPLEVEL", true},
+	WildTopLevelError:        {"ERR_WILDTOPLEVEL", true},
+	BadMaskError:             {"ERR_BADMASK", true},
+	UnknownCommandError:      {"ERR_UNKNOWNCOMMAND", true},
+	NoMotdError:              {"ERR_NOMOTD", true},
+	NoAdminInfoError:         {"ERR_NOADMININFO", true},
+	FileError:                {"ERR_FILEERROR", true},
+	NoNicknameGivenError:     {"ERR_NONICKNAMEGIVEN", true},
+	ErroneousNicknameError:   {"ERR_ERRONEUSNICKNAME", true},
+	NicknameInUseError:       {"ERR_NICKNAMEINUSE", true},
+	NickCollisionError:       {"ERR_NICKCOLLISION", true},
+	UnavailableResourceError: {"ERR_UNAVAILRESOURCE", true},
+	UserNotInChannelError:    {"ERR_USERNOTINCHANNEL", true},
+	NotOnChannelError:        {"ERR_NOTONCHANNEL", true},
+	UserOnChannelError:       {"ERR_USERONCHANNEL", true},
+	NoLoginError:             {"ERR_NOLOGIN", true},
+	SummonDisabledError:      {"ERR_SUMMONDISABLED", true},
+	UsersDisabledError:       {"ERR_USERSDISABLED", true},
+	NotRegisteredError:       {"ERR_NOTREGISTERED", true},
+	NeedMoreParamsError:      {"ERR_NEEDMOREPARAMS", true},
+	AlreadyRegisteredError:   {"ERR_ALREADYREGISTRED", true},
+	NoPermForHostError:       {"ERR_NOPERMFORHOST", true},
+	PasswordMismatchError:    {"ERR_PASSWDMISMATCH", true},
+	YoureBannedCreepError:    {"ERR_YOUREBANNEDCREEP", true},
+	YouWillBeBannedError:     {"ERR_YOUWILLBEBANNED", true},
+	KeySetError:              {"ERR_KEYSET", true},
+	ChannelIsFullError:       {"ERR_CHANNELISFULL", true},
+	UnknownModeError:         {"ERR_UNKNOWNMODE", true},
+	InviteOnlyChanError:      {"ERR_INVITEONLYCHAN", true},
+	BannedFromChanError:      {"ERR_BANNEDFROMCHAN", true},
+	BadChannelKeyError:       {"ERR_BADCHANNELKEY", true},
+	BadChanMaskError:         {"ERR_BADCHANMASK", true},
+	NoChanModesError:         {"ERR_NOCHANMODES", true},
+	BanListFullError:         {"ERR_BANLISTFULL", true},
+	NoPrivilegesError:        {"ERR_NOPRIVILEGES", true},
+	ChanOPrivsNeededError:    {"ERR_CHANOPRIVSNEEDED", true},
+	CantKillServerError:      {"ERR_CANTKILLSERVER", true},
+	RestrictedError:          {"ERR_RESTRICTED", true},
+	UniqOpPrivsNeededError:   {"ERR_UNIQOPPRIVSNEEDED", true},
+	NoOperHostError:          {"ERR_NOOPERHOST", true},
+	UModeUnknownFlagError:    {"ERR_UMODEUNKNOWNFLAG", true},
+	UsersDontMatchError:      {"ERR_USERSDONTMATCH", true},
+	ServiceInfoReply:         {"RPL_SERVICEINFO", false},
+	EndOfServicesReply:       {"RPL_ENDOFSERVICES", false},
+	ServiceReply:             {"RPL_SERVICE", false},
+	NoneReply:                {"RPL_NONE", false},
+	WhoisChanOpReply:         {"RPL_WHOISCHANOP", false},
+	KillDoneReply:            {"RPL_KILLDONE", false},
+	ClosingReply:             {"RPL_CLOSING", false},
+	CloseEndReply:            {"RPL_CLOSEEND", false},
+	InfoStartReply:           {"RPL_INFOSTART", false},
+	MyPortIsReply:            {"RPL_MYPORTIS", false},
+	StatsCLineReply:          {"RPL_STATSCLINE", false},
+	StatsNLineReply:          {"RPL_STATSNLINE", false},
+	StatsILineReply:          {"RPL_STATSILINE", false},
+	StatsKLineReply:          {"RPL_STATSKLINE", false},
+	StatsQLineReply:          {"RPL_STATSQLINE", false},
+	StatsYLineReply:          {"RPL_STATSYLINE", false},
+	StatsVLineReply:          {"RPL_STATSVLINE", false},
+	StatsLLineReply:          {"RPL_STATSLLINE", false},
+	StatsHLineReply:          {"RPL_STATSHLINE", false},
+	StatsPingReply:           {"RPL_STATSPING", false},
+	StatsBLineReply:          {"RPL_STATSBLINE", false},
+	StatsDLineReply:          {"RPL_STATSDLINE", false},
+	NoServiceHostError:       {"ERR_NOSERVICEHOST", true},
+	CreationTimeReply:        {"RPL_CREATIONTIME", false},
+	TopicWhoTimeReply:        {"RPL_TOPICWHOTIME", false},
+	WhoisAccountReply:        {"RPL_WHOISACCOUNT", false},
+	WhoSpcrplReply:           {"RPL_WHOSPCRPL", false},
+	WhoisSecureReply:         {"RPL_WHOISSECURE", false},
+	MonOnlineReply:           {"RPL_MONONLINE", false},
+	MonOfflineReply:          {"RPL_MONOFFLINE", false},
+	MonListReply:             {"RPL_MONLIST", false},
+	EndOfMonListReply:        {"RPL_ENDOFMONLIST", false},
+	MonListFullError:         {"ERR_MONLISTFULL", true},
+	LoggedInReply:            {"RPL_LOGGEDIN", false},
+	LoggedOutReply:           {"RPL_LOGGEDOUT", false},
+	NickLockedError:          {"ERR_NICKLOCKED", true},
+	SASLSuccessReply:         {"RPL_SASLSUCCESS", false},
+	SASLFailError:            {"ERR_SASLFAIL", true},
+	SASLTooLongError:         {"ERR_SASLTOOLONG", true},
+	SASLAbortedError:         {"ERR_SASLABORTED", true},
+	SASLAlreadyError:         {"ERR_SASLALREADY", true},
+	SASLMechsReply:           {"RPL_SASLMECHS", false},
+}
+
 // String returns a string-representation of the command.
 // Mainly used to satisfy the "runtime.stringer" interface and to allow for "%s"-format strings.
 func (c Command) String() string {
@@ -73,3 +1260,32 @@ func (c Command) String() string {
 func (c Command) IsNumericReply() bool {
 	return replyCommandRegexp.MatchString(c.String())
 }
+
+// Name returns the symbolic name of a numeric reply (e.g. "RPL_WELCOME" for "001")
+// as used within the specifications. Commands and unknown numerics are returned as they are.
+func (c Command) Name() string {
+	if r, ok := numericReplies[c]; ok {
+		return r.name
+	}
+	return c.String()
+}
+
+// IsKnownNumericReply checks if the command is a numeric reply that is part of the catalogue.
+func (c Command) IsKnownNumericReply() bool {
+	_, ok := numericReplies[c]
+	return ok
+}
+
+// IsErrorReply checks if the command is a numeric reply that denotes an error.
+// Numerics that are not part of the catalogue are considered to be errors, if they
+// are within the range from 400 to 599.
+func (c Command) IsErrorReply() bool {
+	if r, ok := numericReplies[c]; ok {
+		return r.isError
+	}
+	if !c.IsNumericReply() {
+		return false
+	}
+	n, err := strconv.Atoi(c.String())
+	return err == nil && n >= 400 && n <= 599
+}
@@ -38,3 +38,69 @@ func TestCommand_IsNumericReply(t *testing.T) {
 		}
 	}
 }
+
+func TestCommand_IsNumericReply_Anchored(t *testing.T) {
+	for _, cmd := range []Command{"1234", "12", "A001", "001A"} {
+		if cmd.IsNumericReply() {
+			t.Errorf("%s.IsNumericReply() -> true, expected: false", cmd)
+		}
+	}
+}
+
+func TestCommand_Name(t *testing.T) {
+	var testData = []struct {
+		cmd  Command
+		name string
+	}{
+		{WelcomeReply, "RPL_WELCOME"},
+		{NamesReply, "RPL_NAMREPLY"},
+		{StatsHLineReply, "RPL_STATSHLINE"},
+		{NoSuchNickError, "ERR_NOSUCHNICK"},
+		{AlreadyRegisteredError, "ERR_ALREADYREGISTRED"},
+		{SASLSuccessReply, "RPL_SASLSUCCESS"},
+		{PrivmsgCommand, "PRIVMSG"},
+		{"999", "999"},
+	}
+	for _, td := range testData {
+		if name := td.cmd.Name(); name != td.name {
+			t.Errorf("%s.Name() -> %s, expected: %s", td.cmd, name, td.name)
+		}
+	}
+}
+
+func TestCommand_IsErrorReply(t *testing.T) {
+	var testData = []struct {
+		cmd Command
+		ise bool
+	}{
+		{WelcomeReply, false},
+		{EndOfWhoisReply, false},
+		{NoSuchNickError, true},
+		{UsersDontMatchError, true},
+		{NoServiceHostError, true},
+		{SASLFailError, true},
+		{SASLMechsReply, false},
+		{"499", true},
+		{"600", false},
+		{PrivmsgCommand, false},
+	}
+	for _, td := range testData {
+		if ise := td.cmd.IsErrorReply(); ise != td.ise {
+			t.Errorf("%s.IsErrorReply() -> %v, expected: %v", td.cmd, ise, td.ise)
+		}
+	}
+}
+
+func TestCommand_Catalogue(t *testing.T) {
+	for cmd, r := range numericReplies {
+		if !cmd.IsNumericReply() {
+			t.Errorf("%s is part of the catalogue, but is not a numeric reply", cmd)
+		}
+		if !cmd.IsKnownNumericReply() {
+			t.Errorf("%s.IsKnownNumericReply() -> false, expected: true", cmd)
+		}
+		if prefix := r.name[:4]; (prefix == "ERR_") != r.isError {
+			t.Errorf("%s (%s) has an inconsistent error flag", cmd, r.name)
+		}
+	}
+}
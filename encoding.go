@@ -0,0 +1,148 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// LookupEncoding returns the character encoding with the given name (e.g. "latin1",
+// "windows-1252" or "koi8-r"). Names are resolved as specified by the WHATWG Encoding
+// Standard, which is also being used by web browsers.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding: %s", name)
+	}
+	return enc, nil
+}
+
+// WithEncoding configures the legacy encoding of the network. Incoming lines that are
+// not valid UTF-8 are decoded using this encoding and outgoing messages are encoded using
+// it, unless the server has announced UTF8ONLY or a channel specific encoding applies
+// (see WithChannelEncoding()). Without a legacy encoding, invalid UTF-8 sequences are
+// replaced by U+FFFD and all messages are sent as UTF-8. Messages are always sent as
+// UTF-8 over WebSocket connections using the text subprotocol, which only carries UTF-8.
+func WithEncoding(enc encoding.Encoding) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.encodings.fallback = enc
+	}
+}
+
+// WithChannelEncoding configures the legacy encoding used within the given channel. It
+// takes precedence over the encoding configured using WithEncoding().
+func WithChannelEncoding(channel string, enc encoding.Encoding) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.encodings.channels.Set(channel, enc)
+	}
+}
+
+// withNetworkEncodings applies the encodings configured for the given network.
+func withNetworkEncodings(network Network) ([]ClientConnectionOption, error) {
+	var opts []ClientConnectionOption
+	if network.Encoding != "" {
+		enc, err := LookupEncoding(network.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithEncoding(enc))
+	}
+	for channel, name := range network.ChannelEncodings {
+		enc, err := LookupEncoding(name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithChannelEncoding(channel, enc))
+	}
+	return opts, nil
+}
+
+// connectionEncodings contains the legacy encodings configured for a connection.
+type connectionEncodings struct {
+	mu       sync.Mutex
+	fallback encoding.Encoding
+	channels ChannelMap[encoding.Encoding]
+}
+
+// lookup returns the encoding to use for a message with the given parameters: the
+// encoding of the first channel (among all but the trailing parameter) that has one,
+// the fallback encoding otherwise. Nil is returned if no legacy encoding applies.
+func (e *connectionEncodings) lookup(isupport *ISupport, params []string) encoding.Encoding {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.channels.Len() > 0 {
+		if e.channels.Casemapping() != isupport.Casemapping {
+			e.channels.SetCasemapping(isupport.Casemapping)
+		}
+		for i := 0; i < len(params)-1; i++ {
+			if !isupport.IsChannelName(params[i]) {
+				continue
+			}
+			if enc, ok := e.channels.Get(params[i]); ok {
+				return enc
+			}
+		}
+	}
+	return e.fallback
+}
+
+// isConfigured checks if any legacy encoding has been configured at all.
+func (e *connectionEncodings) isConfigured() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fallback != nil || e.channels.Len() > 0
+}
+
+// decodeLine converts a line received from the server to UTF-8. Lines that are valid
+// UTF-8 are passed through. Other lines are decoded using the applicable legacy encoding
+// or, if there is none, have their invalid sequences replaced by U+FFFD.
+func (conn *clientConnection) decodeLine(raw []byte) string {
+	if utf8.Valid(raw) {
+		return string(raw)
+	}
+	str := string(raw)
+	if !conn.encodings.isConfigured() {
+		return strings.ToValidUTF8(str, "\uFFFD")
+	}
+	var params []string
+	if msg, err := NewMessageFromString(str); err == nil {
+		params = msg.Parameters()
+	}
+	conn.capMu.RLock()
+	enc := conn.encodings.lookup(conn.isupport, params)
+	utf8Only := conn.isupport.UTF8Only
+	conn.capMu.RUnlock()
+	if enc == nil || utf8Only {
+		return strings.ToValidUTF8(str, "\uFFFD")
+	}
+	decoded, err := enc.NewDecoder().String(str)
+	if err != nil {
+		return strings.ToValidUTF8(str, "\uFFFD")
+	}
+	return decoded
+}
+
+// encodeLine converts the given line of the given message to the applicable legacy
+// encoding. Characters that cannot be represented are replaced. Lines are sent as UTF-8
+// if no legacy encoding applies or if the server has announced UTF8ONLY.
+func (conn *clientConnection) encodeLine(msg Message, line string) string {
+	if !conn.encodings.isConfigured() {
+		return line
+	}
+	conn.capMu.RLock()
+	enc := conn.encodings.lookup(conn.isupport, msg.Parameters())
+	utf8Only := conn.isupport.UTF8Only
+	conn.capMu.RUnlock()
+	if enc == nil || utf8Only {
+		return line
+	}
+	encoded, err := encoding.ReplaceUnsupported(enc.NewEncoder()).String(line)
+	if err != nil {
+		return line
+	}
+	return encoded
+}
@@ -0,0 +1,135 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestLookupEncoding(t *testing.T) {
+	for _, name := range []string{"latin1", "ISO-8859-1", "cp1252", "windows-1252", "koi8-r", "utf-8"} {
+		if _, err := LookupEncoding(name); err != nil {
+			t.Errorf("LookupEncoding(%s) -> %v", name, err)
+		}
+	}
+	if _, err := LookupEncoding("klingon"); err == nil {
+		t.Error("LookupEncoding(klingon) should fail")
+	}
+}
+
+func TestClientConnection_DecodeLine(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", 6667,
+		WithEncoding(charmap.Windows1252),
+		WithChannelEncoding("#Russian", charmap.KOI8R),
+	).(*clientConnection)
+	var testdata = []struct {
+		raw      string
+		expected string
+	}{
+		{":alice!a@host PRIVMSG #test :grüße", ":alice!a@host PRIVMSG #test :grüße"},
+		{":alice!a@host PRIVMSG #test :gr\xfc\xdfe \x80", ":alice!a@host PRIVMSG #test :grüße €"},
+		{":alice!a@host PRIVMSG #russian :\xf0\xd2\xc9\xd7\xc5\xd4", ":alice!a@host PRIVMSG #russian :Привет"},
+		{":irc.example.com 332 johndoe #RUSSIAN :\xf0\xd2\xc9\xd7\xc5\xd4", ":irc.example.com 332 johndoe #RUSSIAN :Привет"},
+		{":alice!a@host PRIVMSG johndoe :\xe0 bient\xf4t", ":alice!a@host PRIVMSG johndoe :à bientôt"},
+	}
+	for _, tt := range testdata {
+		if decoded := conn.decodeLine([]byte(tt.raw)); decoded != tt.expected {
+			t.Errorf("decodeLine(%q) -> %q, expected: %q", tt.raw, decoded, tt.expected)
+		}
+	}
+
+	// Servers announcing UTF8ONLY never send anything but UTF-8.
+	conn.isupport.Update(NewMessage(EmptyPrefix, ISupportReply, "johndoe", "UTF8ONLY", "are supported by this server"))
+	raw := ":alice!a@host PRIVMSG #test :gr\xfc\xdfe"
+	if decoded, expected := conn.decodeLine([]byte(raw)), ":alice!a@host PRIVMSG #test :gr�e"; decoded != expected {
+		t.Errorf("decodeLine(%q) with UTF8ONLY -> %q, expected: %q", raw, decoded, expected)
+	}
+}
+
+func TestClientConnection_DecodeLineWithoutEncoding(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", 6667).(*clientConnection)
+	raw := ":alice!a@host PRIVMSG #test :gr\xfc\xdfe"
+	if decoded, expected := conn.decodeLine([]byte(raw)), ":alice!a@host PRIVMSG #test :gr�e"; decoded != expected {
+		t.Errorf("decodeLine(%q) -> %q, expected: %q", raw, decoded, expected)
+	}
+}
+
+func TestClientConnection_EncodeLine(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", 6667,
+		WithChannelEncoding("#legacy", charmap.ISO8859_1),
+	).(*clientConnection)
+	var testdata = []struct {
+		msg      Message
+		expected string
+	}{
+		{NewMessageWithoutPrefix(PrivmsgCommand, "#legacy", "grüße"), "PRIVMSG #legacy gr\xfc\xdfe"},
+		{NewMessageWithoutPrefix(PrivmsgCommand, "#legacy", "€ 5"), "PRIVMSG #legacy :\x1a 5"},
+		{NewMessageWithoutPrefix(PrivmsgCommand, "#modern", "grüße"), "PRIVMSG #modern grüße"},
+		{NewMessageWithoutPrefix(PrivmsgCommand, "alice", "grüße"), "PRIVMSG alice grüße"},
+	}
+	for _, tt := range testdata {
+		if encoded := conn.encodeLine(tt.msg, tt.msg.String()); encoded != tt.expected {
+			t.Errorf("encodeLine(%s) -> %q, expected: %q", tt.msg, encoded, tt.expected)
+		}
+	}
+
+	conn.isupport.Update(NewMessage(EmptyPrefix, ISupportReply, "johndoe", "UTF8ONLY", "are supported by this server"))
+	msg := NewMessageWithoutPrefix(PrivmsgCommand, "#legacy", "grüße")
+	if encoded := conn.encodeLine(msg, msg.String()); encoded != msg.String() {
+		t.Errorf("encodeLine(%s) with UTF8ONLY -> %q, expected: %q", msg, encoded, msg.String())
+	}
+}
+
+func TestClientConnection_LegacyEncoding(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn, err := NewClientConnectionForNetwork(Network{
+		Servers:  []Server{{Hostname: "127.0.0.1", Port: uint(srv.port())}},
+		Encoding: "latin1",
+	}, WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	if err != nil {
+		t.Fatalf("NewClientConnectionForNetwork() -> %v", err)
+	}
+	received := make(chan Message, 16)
+	go func() {
+		for {
+			select {
+			case msg := <-conn.In():
+				received <- msg
+			case <-conn.State():
+			case <-conn.Err():
+			}
+		}
+	}()
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Close()
+	srv.send(":alice!a@host PRIVMSG johndoe :\xe0 bient\xf4t")
+	for msg := range received {
+		if msg.Command() != PrivmsgCommand {
+			continue
+		}
+		if text := msg.Parameters()[1]; text != "à bientôt" {
+			t.Errorf("received %q, expected: %q", text, "à bientôt")
+		}
+		break
+	}
+	conn.Out() <- NewMessageWithoutPrefix(PrivmsgCommand, "alice", "très bien")
+	srv.conn.SetReadDeadline(time.Now().Add(fakeServerTimeout))
+	if !srv.scanner.Scan() || srv.scanner.Text() != "PRIVMSG alice :tr\xe8s bien" {
+		t.Errorf("server received %q, expected: %q", srv.scanner.Text(), "PRIVMSG alice :tr\xe8s bien")
+	}
+}
+
+func TestNewClientConnectionForNetwork_UnknownEncoding(t *testing.T) {
+	if _, err := NewClientConnectionForNetwork(Network{
+		Servers:          []Server{{Hostname: "127.0.0.1", Port: 6667}},
+		ChannelEncodings: map[string]string{"#test": "klingon"},
+	}); err == nil {
+		t.Error("NewClientConnectionForNetwork() should fail for unknown encodings")
+	}
+}
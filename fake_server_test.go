@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServerTimeout limits how long the fake server waits for the client to send something.
+const fakeServerTimeout = 5 * time.Second
+
+// fakeServerQuitTimeout is used as quit timeout by the tests (see WithQuitTimeout()). The
+// fake server only reacts to QUIT messages if told to, so Close() shouldn't wait for it.
+const fakeServerQuitTimeout = 50 * time.Millisecond
+
+// fakeServer is a minimal, scriptable in-process IRC server that can be used to test
+// client connections without having to rely on a real IRC daemon.
+type fakeServer struct {
+	t        *testing.T
+	listener net.Listener
+	conn     net.Conn
+	scanner  *bufio.Scanner
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fake server could not listen: %v", err)
+	}
+	return &fakeServer{t: t, listener: l}
+}
+
+// newFakeTLSServer creates a fake server that only accepts TLS connections.
+func newFakeTLSServer(t *testing.T, config *tls.Config) *fakeServer {
+	srv := newFakeServer(t)
+	srv.listener = tls.NewListener(srv.listener, config)
+	return srv
+}
+
+// port returns the TCP port that the fake server listens on.
+func (s *fakeServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// accept waits for the client to connect.
+func (s *fakeServer) accept() {
+	var err error
+	if s.conn, err = s.listener.Accept(); err != nil {
+		s.t.Fatalf("fake server could not accept connection: %v", err)
+	}
+	s.scanner = bufio.NewScanner(s.conn)
+}
+
+// receive reads the next message sent by the client.
+func (s *fakeServer) receive() Message {
+	s.conn.SetReadDeadline(time.Now().Add(fakeServerTimeout))
+	if !s.scanner.Scan() {
+		s.t.Fatalf("fake server did not receive a message: %v", s.scanner.Err())
+	}
+	msg, err := NewMessageFromString(s.scanner.Text())
+	if err != nil {
+		s.t.Fatalf("fake server received an invalid message: %v", err)
+	}
+	return msg
+}
+
+// expect reads the next message sent by the client and fails the test, if it doesn't
+// start with the given string.
+func (s *fakeServer) expect(str string) Message {
+	msg := s.receive()
+	if !strings.HasPrefix(msg.String(), str) {
+		s.t.Fatalf(`fake server expected "%s", but received "%s"`, str, msg)
+	}
+	return msg
+}
+
+// send transmits the given raw lines to the client.
+func (s *fakeServer) send(lines ...string) {
+	for _, l := range lines {
+		if _, err := s.conn.Write([]byte(l + messageDelimiter)); err != nil {
+			s.t.Fatalf("fake server could not send message: %v", err)
+		}
+	}
+}
+
+func (s *fakeServer) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.listener.Close()
+}
+
+// drainConnection consumes all messages, state changes and errors emitted by a
+// connection, so that the connection never blocks during tests.
+func drainConnection(conn ClientConnection) {
+	go func() {
+		for {
+			select {
+			case <-conn.In():
+			case <-conn.State():
+			case <-conn.Err():
+			}
+		}
+	}()
+}
+
+// openAsync invokes Open() in the background. The returned channel receives the result.
+func openAsync(conn ClientConnection) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.Open()
+	}()
+	return result
+}
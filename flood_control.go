@@ -0,0 +1,323 @@
+package irc
+
+import (
+	"sync"
+	"time"
+)
+
+// Default values used for flood control configurations that leave the respective fields empty.
+// They correspond to the penalty scheme of RfC-1459 (a burst of five messages, one more
+// message every two seconds), which most servers tolerate.
+const (
+	defaultFloodControlBurst    = 5
+	defaultFloodControlInterval = 2 * time.Second
+)
+
+// MessagePriority determines how an outgoing message is being treated by the flood control.
+type MessagePriority int
+
+const (
+	// PriorityNormal messages are queued and sent in order, as soon as the rate limit allows.
+	PriorityNormal MessagePriority = iota
+	// PriorityLow messages are queued like normal messages, but they will be dropped (or
+	// coalesced) first if the queue overflows.
+	PriorityLow
+	// PriorityHigh messages bypass the queue and are sent immediately. They are still being
+	// accounted for, though. PONG and QUIT messages always have a high priority.
+	PriorityHigh
+)
+
+// OverflowPolicy determines what happens if a message is being sent while the queue of
+// the flood control is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropLow drops the oldest queued low priority message. If there is none, the
+	// new message will be queued anyway, unless it has a low priority itself.
+	OverflowDropLow OverflowPolicy = iota
+	// OverflowCoalesce replaces a queued low priority message with the same command and
+	// target (e.g. an earlier AWAY or TOPIC) by the new message. If there is none, the
+	// policy behaves like OverflowDropLow.
+	OverflowCoalesce
+)
+
+// FloodControl limits the rate at which messages are sent to the server, so that the client
+// doesn't get disconnected for flooding ("Excess Flood"). It implements a token bucket:
+// Every message costs a number of tokens (one by default), the bucket holds up to Burst
+// tokens and one token is refilled every Interval. Messages are being queued until enough
+// tokens are available.
+type FloodControl struct {
+	// Burst is the number of tokens that are available initially. Defaults to 5.
+	Burst int
+	// Interval is the time it takes to refill a single token. Defaults to two seconds.
+	Interval time.Duration
+	// Costs maps commands to the number of tokens they cost. Unlisted commands cost one
+	// token. Costs exceeding Burst are capped at Burst.
+	Costs map[Command]int
+	// Priorities maps commands to their priority. Unlisted commands have a normal priority.
+	Priorities map[Command]MessagePriority
+	// MaxQueueLength limits the number of queued messages. Zero means: unlimited.
+	MaxQueueLength int
+	// Overflow determines what happens once the queue is full.
+	Overflow OverflowPolicy
+}
+
+// SendQueueStats contains metrics of the outgoing message queue.
+type SendQueueStats struct {
+	// Length is the number of messages that are currently waiting to be sent.
+	Length int
+	// MaxLength is the highest number of messages that have been waiting at once.
+	MaxLength int
+	// Sent is the number of messages that have been sent.
+	Sent uint64
+	// Delayed is the number of messages that had to wait for the rate limit.
+	Delayed uint64
+	// Dropped is the number of messages that have been dropped due to an overflow or
+	// because the connection has been lost before they could be sent.
+	Dropped uint64
+	// Coalesced is the number of messages that have been replaced by a newer message.
+	Coalesced uint64
+}
+
+// WithFloodControl enables the client-side flood control using the given configuration.
+// Without flood control, messages are sent as soon as they have been written to Out().
+func WithFloodControl(fc FloodControl) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.sendQueue = newSendQueue(fc)
+	}
+}
+
+func (fc FloodControl) burst() int {
+	if fc.Burst <= 0 {
+		return defaultFloodControlBurst
+	}
+	return fc.Burst
+}
+
+func (fc FloodControl) interval() time.Duration {
+	if fc.Interval <= 0 {
+		return defaultFloodControlInterval
+	}
+	return fc.Interval
+}
+
+func (fc FloodControl) cost(msg Message) float64 {
+	cost, ok := fc.Costs[msg.Command()]
+	if !ok {
+		cost = 1
+	}
+	if cost > fc.burst() {
+		cost = fc.burst()
+	}
+	return float64(cost)
+}
+
+func (fc FloodControl) priority(msg Message) MessagePriority {
+	switch msg.Command() {
+	case PongCommand, QuitCommand:
+		return PriorityHigh
+	}
+	return fc.Priorities[msg.Command()]
+}
+
+type queuedMessage struct {
+	msg      Message
+	priority MessagePriority
+	delayed  bool
+}
+
+// sendQueue buffers the outgoing messages according to the flood control.
+type sendQueue struct {
+	mu     sync.Mutex
+	config FloodControl
+	tokens float64
+	filled time.Time // the last time the tokens have been refilled
+	queue  []queuedMessage
+	stats  SendQueueStats
+}
+
+func newSendQueue(fc FloodControl) *sendQueue {
+	return &sendQueue{config: fc}
+}
+
+// reset refills the bucket. This is necessary whenever a new connection to the server
+// is being established. Queued messages are being dropped, since they would otherwise be
+// sent ahead of the registration of the new connection.
+func (q *sendQueue) reset(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tokens = float64(q.config.burst())
+	q.filled = now
+	q.stats.Dropped += uint64(len(q.queue))
+	q.queue = nil
+}
+
+func (q *sendQueue) refill(now time.Time) {
+	if now.After(q.filled) {
+		q.tokens += float64(now.Sub(q.filled)) / float64(q.config.interval())
+		if burst := float64(q.config.burst()); q.tokens > burst {
+			q.tokens = burst
+		}
+		q.filled = now
+	}
+}
+
+// push queues the given message. Messages with a high priority will be returned
+// immediately (ready), so that they can be sent right away.
+func (q *sendQueue) push(msg Message, now time.Time) (ready Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	priority := q.config.priority(msg)
+	if priority == PriorityHigh {
+		q.refill(now)
+		q.tokens -= q.config.cost(msg)
+		q.stats.Sent++
+		return msg
+	}
+	if max := q.config.MaxQueueLength; max > 0 && len(q.queue) >= max {
+		if q.config.Overflow == OverflowCoalesce && priority == PriorityLow && q.coalesce(msg) {
+			q.stats.Coalesced++
+			return nil
+		}
+		if !q.dropLow() {
+			if priority == PriorityLow {
+				q.stats.Dropped++
+				return nil
+			}
+		}
+	}
+	q.queue = append(q.queue, queuedMessage{msg: msg, priority: priority})
+	if len(q.queue) > q.stats.MaxLength {
+		q.stats.MaxLength = len(q.queue)
+	}
+	return nil
+}
+
+// coalesce replaces a queued low priority message with the same command and target.
+// The new message takes over the position of the replaced one.
+func (q *sendQueue) coalesce(msg Message) bool {
+	target := coalescingTarget(msg)
+	for i, qm := range q.queue {
+		if qm.priority == PriorityLow && qm.msg.Command() == msg.Command() && coalescingTarget(qm.msg) == target {
+			q.queue[i].msg = msg
+			return true
+		}
+	}
+	return false
+}
+
+// dropLow drops the oldest queued low priority message.
+func (q *sendQueue) dropLow() bool {
+	for i, qm := range q.queue {
+		if qm.priority == PriorityLow {
+			q.queue = append(q.queue[:i], q.queue[i+1:]...)
+			q.stats.Dropped++
+			return true
+		}
+	}
+	return false
+}
+
+// pop returns the next message, if the rate limit allows to send it. Otherwise, the time
+// to wait until the message may be sent will be returned.
+func (q *sendQueue) pop(now time.Time) (msg Message, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.queue) == 0 {
+		return nil, 0
+	}
+	q.refill(now)
+	next := &q.queue[0]
+	cost := q.config.cost(next.msg)
+	if q.tokens < cost {
+		if !next.delayed {
+			next.delayed = true
+			q.stats.Delayed++
+		}
+		wait = time.Duration((cost - q.tokens) * float64(q.config.interval()))
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+		return nil, wait
+	}
+	q.tokens -= cost
+	q.queue = q.queue[1:]
+	q.stats.Sent++
+	return next.msg, 0
+}
+
+func (q *sendQueue) snapshot() SendQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Length = len(q.queue)
+	return stats
+}
+
+// coalescingTarget returns the target of the given message, which is usually its first
+// parameter. Commands like AWAY and NICK don't have a target.
+func coalescingTarget(msg Message) string {
+	switch msg.Command() {
+	case AwayCommand, NickCommand:
+		return ""
+	}
+	if params := msg.Parameters(); len(params) > 0 {
+		return params[0]
+	}
+	return ""
+}
+
+// writeQueuedLoop transmits the messages that are queued for sending, honouring the
+// flood control, until the session has ended.
+func (conn *clientConnection) writeQueuedLoop(s *session) {
+	conn.sendQueue.reset(time.Now())
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		var wait time.Duration
+		for {
+			msg, w := conn.sendQueue.pop(time.Now())
+			if msg == nil {
+				wait = w
+				break
+			}
+			if !conn.write(s, msg) {
+				return
+			}
+		}
+		var wakeup <-chan time.Time
+		if wait > 0 {
+			timer.Reset(wait)
+			wakeup = timer.C
+		}
+		select {
+		case msg := <-s.urgent:
+			if ready := conn.sendQueue.push(msg, time.Now()); ready != nil && !conn.write(s, ready) {
+				return
+			}
+		case msg := <-conn.out:
+			if ready := conn.sendQueue.push(msg, time.Now()); ready != nil && !conn.write(s, ready) {
+				return
+			}
+		case <-wakeup:
+			continue
+		case <-s.done:
+			return
+		}
+		if wakeup != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// SendQueueStats returns metrics of the outgoing message queue. All values are zero
+// unless flood control has been enabled.
+func (conn *clientConnection) SendQueueStats() SendQueueStats {
+	if conn.sendQueue == nil {
+		return SendQueueStats{}
+	}
+	return conn.sendQueue.snapshot()
+}
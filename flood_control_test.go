@@ -0,0 +1,129 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+func privmsg(target string, text string) Message {
+	return NewMessageWithoutPrefix(PrivmsgCommand, target, text)
+}
+
+func TestSendQueue_RateLimit(t *testing.T) {
+	now := time.Now()
+	q := newSendQueue(FloodControl{Burst: 2, Interval: time.Second, Costs: map[Command]int{JoinCommand: 2}})
+	q.reset(now)
+	for i := 0; i < 3; i++ {
+		q.push(privmsg("#test", "hello"), now)
+	}
+	q.push(NewMessageWithoutPrefix(JoinCommand, "#other"), now)
+	for i := 0; i < 2; i++ {
+		if msg, _ := q.pop(now); msg == nil {
+			t.Fatalf("message #%d should be sent without delay", i+1)
+		}
+	}
+	if msg, wait := q.pop(now); msg != nil || wait != time.Second {
+		t.Fatalf("pop() -> %v, %v; expected to wait a second", msg, wait)
+	}
+	now = now.Add(time.Second)
+	if msg, _ := q.pop(now); msg == nil || msg.Command() != PrivmsgCommand {
+		t.Fatalf("pop() -> %v; expected the third PRIVMSG", msg)
+	}
+	if msg, wait := q.pop(now); msg != nil || wait != 2*time.Second {
+		t.Fatalf("pop() -> %v, %v; JOIN should cost two tokens", msg, wait)
+	}
+	now = now.Add(time.Hour)
+	if msg, _ := q.pop(now); msg == nil || msg.Command() != JoinCommand {
+		t.Fatalf("pop() -> %v; expected JOIN", msg)
+	}
+	if stats := q.snapshot(); stats.Length != 0 || stats.MaxLength != 4 || stats.Sent != 4 || stats.Delayed != 2 {
+		t.Errorf("snapshot() -> %+v", stats)
+	}
+}
+
+func TestSendQueue_HighPriority(t *testing.T) {
+	now := time.Now()
+	q := newSendQueue(FloodControl{Burst: 1})
+	q.reset(now)
+	q.push(privmsg("#test", "one"), now)
+	q.push(privmsg("#test", "two"), now)
+	q.pop(now)
+	if ready := q.push(NewMessageWithoutPrefix(PongCommand, "token"), now); ready == nil {
+		t.Error("PONG should bypass the queue")
+	}
+	if ready := q.push(NewMessageWithoutPrefix(QuitCommand, "bye"), now); ready == nil {
+		t.Error("QUIT should bypass the queue")
+	}
+	if _, wait := q.pop(now); wait != 6*time.Second {
+		t.Errorf("messages bypassing the queue should still be accounted for, but wait -> %v", wait)
+	}
+}
+
+func TestSendQueue_Overflow(t *testing.T) {
+	now := time.Now()
+	low := map[Command]MessagePriority{NoticeCommand: PriorityLow, AwayCommand: PriorityLow}
+	q := newSendQueue(FloodControl{Burst: 1, MaxQueueLength: 2, Priorities: low})
+	q.reset(now)
+	q.push(NewMessageWithoutPrefix(NoticeCommand, "alice", "first"), now)
+	q.push(privmsg("#test", "important"), now)
+	q.push(privmsg("#test", "more important"), now)
+	q.push(NewMessageWithoutPrefix(NoticeCommand, "bob", "dropped"), now)
+	if stats := q.snapshot(); stats.Length != 2 || stats.Dropped != 2 {
+		t.Errorf("low priority messages should be dropped: %+v", stats)
+	}
+	if msg, _ := q.pop(now); msg == nil || msg.Parameters()[1] != "important" {
+		t.Errorf("pop() -> %v", msg)
+	}
+
+	q = newSendQueue(FloodControl{Burst: 1, MaxQueueLength: 2, Priorities: low, Overflow: OverflowCoalesce})
+	q.reset(now)
+	q.push(NewMessageWithoutPrefix(AwayCommand, "lunch"), now)
+	q.push(privmsg("#test", "hello"), now)
+	q.push(NewMessageWithoutPrefix(AwayCommand, "dinner"), now)
+	if stats := q.snapshot(); stats.Length != 2 || stats.Coalesced != 1 || stats.Dropped != 0 {
+		t.Errorf("low priority messages should be coalesced: %+v", stats)
+	}
+	if msg, _ := q.pop(now); msg == nil || msg.Parameters()[0] != "dinner" {
+		t.Errorf("pop() -> %v; expected the newer AWAY message", msg)
+	}
+}
+
+func TestSendQueue_Reset(t *testing.T) {
+	now := time.Now()
+	q := newSendQueue(FloodControl{Burst: 1})
+	q.reset(now)
+	q.push(privmsg("#test", "one"), now)
+	q.push(privmsg("#test", "two"), now)
+	q.pop(now)
+	q.reset(now)
+	if msg, _ := q.pop(now); msg != nil {
+		t.Errorf("pop() -> %v; queued messages should not survive a new connection", msg)
+	}
+	if stats := q.snapshot(); stats.Length != 0 || stats.Dropped != 1 {
+		t.Errorf("snapshot() -> %+v", stats)
+	}
+}
+
+func TestClientConnection_FloodControl(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithFloodControl(FloodControl{Burst: 5, Interval: time.Hour, Costs: map[Command]int{PrivmsgCommand: 5}}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	conn.Out() <- privmsg("#test", "held back")
+	srv.send("PING sync")
+	srv.expect("PONG sync")
+	if stats := conn.SendQueueStats(); stats.Length != 1 || stats.Delayed != 1 {
+		t.Errorf("SendQueueStats() -> %+v", stats)
+	}
+	conn.Close()
+	conn.Wait()
+}
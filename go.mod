@@ -1,9 +1,13 @@
 module github.com/headcr4sh/irc
 
-go 1.13
+go 1.18
 
 require (
 	github.com/jroimartin/gocui v0.3.1-0.20170827195011-4f518eddb04b
-	github.com/mattn/go-runewidth v0.0.2
-	github.com/nsf/termbox-go v0.0.0-20171013182044-10cefba34bc5
+	golang.org/x/text v0.21.0
+)
+
+require (
+	github.com/mattn/go-runewidth v0.0.2 // indirect
+	github.com/nsf/termbox-go v0.0.0-20171013182044-10cefba34bc5 // indirect
 )
@@ -0,0 +1,193 @@
+package irc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// AnyCommand can be used to register a handler that will be invoked for every incoming message.
+const AnyCommand Command = "*"
+
+// HandlerFunc processes an incoming message. The given context will be cancelled once the
+// connection has been closed down.
+type HandlerFunc func(ctx context.Context, msg Message)
+
+// Middleware wraps a handler to add behavior to it (e.g. logging, filtering or recovering
+// from panics). A middleware decides on its own whether the wrapped handler will be invoked.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// HandlerMode determines how a handler is executed.
+type HandlerMode int
+
+const (
+	// HandlerModeSerial executes the handler in the goroutine that reads messages from the
+	// server. Serial handlers see the messages in the order they have been received, but
+	// they block the processing of all further messages until they return, so they must
+	// not perform any lengthy operations. In particular, they cannot wait for the response
+	// to a query: Whois(), Who(), Whowas() and Request() fail right away if they are
+	// called with the context of a serial handler.
+	HandlerModeSerial HandlerMode = iota
+	// HandlerModeConcurrent executes the handler in a goroutine of its own for each message.
+	HandlerModeConcurrent
+)
+
+// HandlerOption can be used to customize a handler upon its registration.
+type HandlerOption func(h *handler)
+
+// WithHandlerMode determines how the handler will be executed. Handlers are executed
+// serially by default.
+func WithHandlerMode(mode HandlerMode) HandlerOption {
+	return func(h *handler) {
+		h.mode = mode
+	}
+}
+
+// WithHandlerMiddleware wraps the handler with the given middleware. The first middleware
+// will be the outermost one. Middleware registered on the connection using Use() wraps
+// around the handler's own middleware.
+func WithHandlerMiddleware(mw ...Middleware) HandlerOption {
+	return func(h *handler) {
+		h.fn = chainMiddleware(h.fn, mw)
+	}
+}
+
+// WithoutInChannel disables the relaying of incoming messages to the In() channel. This is
+// useful if all messages are processed by handlers, so that nobody has to drain In().
+func WithoutInChannel() ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.inDisabled = true
+	}
+}
+
+// serialHandlerKey marks the contexts that are passed to serial handlers.
+type serialHandlerKey struct{}
+
+// isSerialHandler checks if the given context has been passed to a serial handler (or
+// has been derived from such a context).
+func isSerialHandler(ctx context.Context) bool {
+	return ctx.Value(serialHandlerKey{}) != nil
+}
+
+type handler struct {
+	id      int
+	matches func(cmd Command) bool
+	fn      HandlerFunc
+	mode    HandlerMode
+}
+
+// dispatcher keeps track of the registered handlers and invokes them for incoming messages.
+type dispatcher struct {
+	mu         sync.RWMutex
+	handlers   []*handler
+	middleware []Middleware
+	nextID     int
+	wg         sync.WaitGroup // Running concurrent handlers.
+}
+
+// add registers a handler and returns a function that can be used to remove it again.
+func (d *dispatcher) add(matches func(cmd Command) bool, fn HandlerFunc, opts []HandlerOption) (remove func()) {
+	h := &handler{
+		matches: matches,
+		fn:      fn,
+		mode:    HandlerModeSerial,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	d.mu.Lock()
+	d.nextID++
+	h.id = d.nextID
+	d.handlers = append(d.handlers, h)
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for i, other := range d.handlers {
+			if other.id == h.id {
+				d.handlers = append(d.handlers[:i:i], d.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// use adds middleware that applies to all handlers.
+func (d *dispatcher) use(mw []Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.middleware = append(d.middleware, mw...)
+}
+
+// dispatch invokes all the handlers that are interested in the given message.
+func (d *dispatcher) dispatch(ctx context.Context, msg Message) {
+	d.mu.RLock()
+	var matching []*handler
+	for _, h := range d.handlers {
+		if h.matches(msg.Command()) {
+			matching = append(matching, h)
+		}
+	}
+	mw := d.middleware
+	d.mu.RUnlock()
+
+	var serialCtx context.Context
+	for _, h := range matching {
+		fn := chainMiddleware(h.fn, mw)
+		switch h.mode {
+		case HandlerModeConcurrent:
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				fn(ctx, msg)
+			}()
+		default:
+			if serialCtx == nil {
+				serialCtx = context.WithValue(ctx, serialHandlerKey{}, true)
+			}
+			fn(serialCtx, msg)
+		}
+	}
+}
+
+// wait blocks until all concurrently executed handlers have returned.
+func (d *dispatcher) wait() {
+	d.wg.Wait()
+}
+
+// chainMiddleware wraps the given handler with the given middleware, the first middleware
+// being the outermost one.
+func chainMiddleware(fn HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		fn = mw[i](fn)
+	}
+	return fn
+}
+
+// Handle registers a handler for messages with the given command (or numeric reply).
+// Use AnyCommand to register a handler for all messages. The returned function can be
+// used to remove the handler again.
+func (conn *clientConnection) Handle(cmd Command, fn HandlerFunc, opts ...HandlerOption) (remove func()) {
+	return conn.dispatcher.add(func(c Command) bool {
+		return cmd == AnyCommand || c == cmd
+	}, fn, opts)
+}
+
+// HandleNumericRange registers a handler for all numeric replies within the given
+// range (inclusive), e.g. 400 to 599 for all error replies. The returned function can
+// be used to remove the handler again.
+func (conn *clientConnection) HandleNumericRange(from int, to int, fn HandlerFunc, opts ...HandlerOption) (remove func()) {
+	return conn.dispatcher.add(func(c Command) bool {
+		if !c.IsNumericReply() {
+			return false
+		}
+		n, err := strconv.Atoi(c.String())
+		return err == nil && n >= from && n <= to
+	}, fn, opts)
+}
+
+// Use adds middleware that wraps all handlers, including the ones that have been
+// registered before.
+func (conn *clientConnection) Use(mw ...Middleware) {
+	conn.dispatcher.use(mw)
+}
@@ -0,0 +1,134 @@
+package irc
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientConnection_Handle(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", DefaultServerPort).(*clientConnection)
+	var calls []string
+	conn.Handle(JoinCommand, func(ctx context.Context, msg Message) { calls = append(calls, "join") })
+	conn.Handle(AnyCommand, func(ctx context.Context, msg Message) { calls = append(calls, "any") })
+	conn.HandleNumericRange(400, 599, func(ctx context.Context, msg Message) { calls = append(calls, "error") })
+	remove := conn.Handle(QuitCommand, func(ctx context.Context, msg Message) { calls = append(calls, "quit") })
+	remove()
+
+	for _, raw := range []string{
+		":johndoe!jdoe@localhost JOIN #test",
+		":irc.example.com 433 * johndoe :Nickname is already in use",
+		":irc.example.com 001 johndoe :Welcome",
+		":johndoe!jdoe@localhost QUIT :Bye",
+	} {
+		msg, _ := NewMessageFromString(raw)
+		conn.dispatcher.dispatch(context.Background(), msg)
+	}
+	expected := []string{"join", "any", "any", "error", "any", "any"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("handlers invoked: %v, expected: %v", calls, expected)
+	}
+}
+
+func TestClientConnection_Use(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", DefaultServerPort).(*clientConnection)
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, msg Message) {
+				calls = append(calls, name)
+				next(ctx, msg)
+			}
+		}
+	}
+	filter := func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg Message) {
+			if msg.Parameters()[0] != "#ignored" {
+				next(ctx, msg)
+			}
+		}
+	}
+	conn.Handle(JoinCommand, func(ctx context.Context, msg Message) {
+		calls = append(calls, "handler")
+	}, WithHandlerMiddleware(trace("own"), filter))
+	conn.Use(trace("global1"), trace("global2"))
+
+	conn.dispatcher.dispatch(context.Background(), NewMessageWithoutPrefix(JoinCommand, "#test"))
+	conn.dispatcher.dispatch(context.Background(), NewMessageWithoutPrefix(JoinCommand, "#ignored"))
+	expected := []string{"global1", "global2", "own", "handler", "global1", "global2", "own"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("invocation order: %v, expected: %v", calls, expected)
+	}
+}
+
+func TestClientConnection_HandleConcurrently(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", DefaultServerPort).(*clientConnection)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	conn.Handle(PingCommand, func(ctx context.Context, msg Message) {
+		<-release
+		wg.Done()
+	}, WithHandlerMode(HandlerModeConcurrent))
+
+	dispatched := make(chan struct{})
+	go func() {
+		conn.dispatcher.dispatch(context.Background(), NewMessageWithoutPrefix(PingCommand, "1"))
+		conn.dispatcher.dispatch(context.Background(), NewMessageWithoutPrefix(PingCommand, "2"))
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("concurrent handlers must not block the dispatcher")
+	}
+	close(release)
+	wg.Wait()
+	conn.dispatcher.wait()
+}
+
+func TestClientConnection_HandleWithoutInChannel(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithoutInChannel(), WithQuitTimeout(fakeServerQuitTimeout))
+	go func() {
+		for {
+			select {
+			case <-conn.State():
+			case <-conn.Err():
+			}
+		}
+	}()
+	received := make(chan Message, 1)
+	var handlerCtx context.Context
+	conn.Handle(PrivmsgCommand, func(ctx context.Context, msg Message) {
+		handlerCtx = ctx
+		received <- msg
+	})
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+
+	// More messages than the In() channel can buffer must not block the connection.
+	for i := 0; i < connectionMsgBufSize*2; i++ {
+		srv.send(":irc.example.com NOTICE * :spam")
+	}
+	srv.send(":johndoe!jdoe@localhost PRIVMSG #test :Hello there")
+	select {
+	case msg := <-received:
+		if msg.Parameters()[1] != "Hello there" {
+			t.Errorf("unexpected message received: %s", msg)
+		}
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("handler has not been invoked")
+	}
+	conn.Close()
+	conn.Wait()
+	if handlerCtx.Err() == nil {
+		t.Error("handler context should be cancelled once the connection has been closed")
+	}
+}
@@ -0,0 +1,264 @@
+package irc
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Well-known RPL_ISUPPORT tokens.
+const (
+	AwayLenISupportToken     = "AWAYLEN"
+	CasemappingISupportToken = "CASEMAPPING"
+	ChanModesISupportToken   = "CHANMODES"
+	ChannelLenISupportToken  = "CHANNELLEN"
+	ChanTypesISupportToken   = "CHANTYPES"
+	ExceptsISupportToken     = "EXCEPTS"
+	InvexISupportToken       = "INVEX"
+	KickLenISupportToken     = "KICKLEN"
+	ModesISupportToken       = "MODES"
+	MonitorISupportToken     = "MONITOR"
+	NetworkISupportToken     = "NETWORK"
+	NickLenISupportToken     = "NICKLEN"
+	PrefixISupportToken      = "PREFIX"
+	StatusMsgISupportToken   = "STATUSMSG"
+	TargMaxISupportToken     = "TARGMAX"
+	TopicLenISupportToken    = "TOPICLEN"
+	UserLenISupportToken     = "USERLEN"
+	UTF8OnlyISupportToken    = "UTF8ONLY"
+	WhoXISupportToken        = "WHOX"
+)
+
+// Defaults that apply as long as the server doesn't announce anything else. They follow
+// RfC-2811 and RfC-2812.
+const (
+	defaultChanTypes     = "#&+!"
+	defaultChannelLen    = 50
+	defaultNickLen       = 9
+	defaultModes         = 3
+	defaultPrefixModes   = "ov"
+	defaultPrefixSymbols = "@+"
+)
+
+// defaultChanModes is the channel mode configuration as specified by RfC-2811.
+var defaultChanModes = ChanModes{
+	A: "beI",
+	B: "k",
+	C: "l",
+	D: "aimnqpsrt",
+}
+
+// ChanModes classifies the channel modes by their parameters (CHANMODES token).
+// Membership modes (e.g. 'o' and 'v') are not part of it, see ISupport.PrefixModes.
+type ChanModes struct {
+	// A contains the modes that add or remove an address to or from a list (e.g. bans).
+	// They always take a parameter.
+	A string
+	// B contains the modes that change a setting and always take a parameter (e.g. keys).
+	B string
+	// C contains the modes that change a setting and only take a parameter when being set
+	// (e.g. limits).
+	C string
+	// D contains the modes that change a setting and never take a parameter.
+	D string
+}
+
+// ISupport contains the features that the server announced using RPL_ISUPPORT (005).
+// Unless announced otherwise, the defaults from RfC-2811 and RfC-2812 apply.
+type ISupport struct {
+	// Tokens contains all the tokens that have been announced. Tokens without a value
+	// map to an empty string.
+	Tokens map[string]string
+
+	Casemapping Casemapping
+	ChanTypes   string
+	ChanModes   ChanModes
+	// PrefixModes contains the membership modes (e.g. "ov") and PrefixSymbols the
+	// corresponding prefixes (e.g. "@+"), both ordered by their rank.
+	PrefixModes   string
+	PrefixSymbols string
+	// StatusMsg contains the prefixes that can be used to address messages to the
+	// members of a channel that have been granted the prefix (e.g. "@#channel").
+	StatusMsg string
+	// Excepts and Invex contain the modes used for ban and invite exceptions (zero if
+	// not supported).
+	Excepts rune
+	Invex   rune
+
+	NickLen    int
+	ChannelLen int
+	// The following limits are zero if there is no limit.
+	TopicLen int
+	KickLen  int
+	AwayLen  int
+	// Modes is the maximum number of modes with parameter per MODE message (zero if unlimited).
+	Modes int
+	// TargMax contains the maximum number of targets per command (zero if unlimited).
+	TargMax map[Command]int
+	// Monitor is the maximum number of targets of the MONITOR list (zero if unlimited).
+	// Use Has(MonitorISupportToken) to check if MONITOR is supported at all.
+	Monitor  int
+	Network  string
+	UTF8Only bool
+}
+
+// NewISupport creates the features that apply before the server has announced anything.
+func NewISupport() *ISupport {
+	is := &ISupport{Tokens: make(map[string]string)}
+	is.derive()
+	return is
+}
+
+// Has checks if the server has announced the given token.
+func (is *ISupport) Has(token string) bool {
+	_, ok := is.Tokens[token]
+	return ok
+}
+
+// Get returns the value of the given token and whether it has been announced.
+func (is *ISupport) Get(token string) (value string, ok bool) {
+	value, ok = is.Tokens[token]
+	return
+}
+
+// Update applies the tokens of a RPL_ISUPPORT reply ("<client> <tokens>... :are supported").
+// Tokens prefixed with '-' revert a previously announced token.
+func (is *ISupport) Update(msg Message) {
+	params := msg.Parameters()
+	if msg.Command() != ISupportReply || len(params) < 3 {
+		return
+	}
+	for _, token := range params[1 : len(params)-1] {
+		if strings.HasPrefix(token, "-") {
+			delete(is.Tokens, token[1:])
+			continue
+		}
+		keyAndValue := strings.SplitN(token, "=", 2)
+		value := ""
+		if len(keyAndValue) == 2 {
+			value = unescapeISupportValue(keyAndValue[1])
+		}
+		is.Tokens[keyAndValue[0]] = value
+	}
+	is.derive()
+}
+
+// Clone creates a deep copy of the features.
+func (is *ISupport) Clone() *ISupport {
+	c := *is
+	c.Tokens = make(map[string]string, len(is.Tokens))
+	for k, v := range is.Tokens {
+		c.Tokens[k] = v
+	}
+	c.TargMax = make(map[Command]int, len(is.TargMax))
+	for k, v := range is.TargMax {
+		c.TargMax[k] = v
+	}
+	return &c
+}
+
+// IsChannelName checks if the given name is a valid channel name on this server,
+// i.e. if it begins with one of the CHANTYPES and doesn't exceed the CHANNELLEN, which
+// servers enforce in bytes.
+func (is *ISupport) IsChannelName(name string) bool {
+	if name == "" || !strings.ContainsRune(is.ChanTypes, rune(name[0])) {
+		return false
+	}
+	if len(name) < 2 || (is.ChannelLen > 0 && len(name) > is.ChannelLen) {
+		return false
+	}
+	return !strings.ContainsAny(name, "\x00\x07\r\n ,:")
+}
+
+// derive updates the typed fields according to the announced tokens.
+func (is *ISupport) derive() {
+	is.Casemapping = DefaultCasemapping
+	if v, ok := is.Tokens[CasemappingISupportToken]; ok && v != "" {
+		is.Casemapping = Casemapping(v)
+	}
+	is.ChanTypes = defaultChanTypes
+	if v, ok := is.Tokens[ChanTypesISupportToken]; ok {
+		is.ChanTypes = v
+	}
+	is.ChanModes = defaultChanModes
+	if v, ok := is.Tokens[ChanModesISupportToken]; ok {
+		types := strings.Split(v, ",")
+		for len(types) < 4 {
+			types = append(types, "")
+		}
+		is.ChanModes = ChanModes{A: types[0], B: types[1], C: types[2], D: types[3]}
+	}
+	is.PrefixModes, is.PrefixSymbols = defaultPrefixModes, defaultPrefixSymbols
+	if v, ok := is.Tokens[PrefixISupportToken]; ok {
+		is.PrefixModes, is.PrefixSymbols = "", ""
+		if end := strings.IndexRune(v, ')'); strings.HasPrefix(v, "(") && end != -1 && len(v)-end-1 == end-1 {
+			is.PrefixModes, is.PrefixSymbols = v[1:end], v[end+1:]
+		}
+	}
+	is.StatusMsg = is.Tokens[StatusMsgISupportToken]
+	is.Excepts = isupportModeValue(is.Tokens, ExceptsISupportToken, 'e')
+	is.Invex = isupportModeValue(is.Tokens, InvexISupportToken, 'I')
+	is.NickLen = isupportIntValue(is.Tokens, NickLenISupportToken, defaultNickLen)
+	is.ChannelLen = isupportIntValue(is.Tokens, ChannelLenISupportToken, defaultChannelLen)
+	is.TopicLen = isupportIntValue(is.Tokens, TopicLenISupportToken, 0)
+	is.KickLen = isupportIntValue(is.Tokens, KickLenISupportToken, 0)
+	is.AwayLen = isupportIntValue(is.Tokens, AwayLenISupportToken, 0)
+	is.Modes = isupportIntValue(is.Tokens, ModesISupportToken, defaultModes)
+	if v, ok := is.Tokens[ModesISupportToken]; ok && v == "" {
+		is.Modes = 0
+	}
+	is.TargMax = make(map[Command]int)
+	for _, t := range strings.Split(is.Tokens[TargMaxISupportToken], ",") {
+		if cmdAndMax := strings.SplitN(t, ":", 2); len(cmdAndMax) == 2 && cmdAndMax[0] != "" {
+			max, _ := strconv.Atoi(cmdAndMax[1])
+			is.TargMax[Command(strings.ToUpper(cmdAndMax[0]))] = max
+		}
+	}
+	is.Monitor = isupportIntValue(is.Tokens, MonitorISupportToken, 0)
+	is.Network = is.Tokens[NetworkISupportToken]
+	is.UTF8Only = is.Has(UTF8OnlyISupportToken)
+}
+
+// isupportIntValue returns the numeric value of the given token, or def if the token
+// has not been announced or if its value isn't a number.
+func isupportIntValue(tokens map[string]string, token string, def int) int {
+	if v, ok := tokens[token]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// isupportModeValue returns the mode announced by the given token, def if the token has
+// been announced without a value or zero if it hasn't been announced at all.
+func isupportModeValue(tokens map[string]string, token string, def rune) rune {
+	v, ok := tokens[token]
+	if !ok {
+		return 0
+	}
+	if v == "" {
+		return def
+	}
+	r, _ := utf8.DecodeRuneInString(v)
+	return r
+}
+
+// unescapeISupportValue reverts the "\xHH" escaping of token values.
+func unescapeISupportValue(value string) string {
+	if !strings.Contains(value, "\\x") {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if b, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
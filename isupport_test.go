@@ -0,0 +1,108 @@
+package irc
+
+import "testing"
+
+// isupportFromRaw creates the features announced by the given RPL_ISUPPORT replies.
+func isupportFromRaw(t *testing.T, lines ...string) *ISupport {
+	is := NewISupport()
+	for _, line := range lines {
+		msg, err := NewMessageFromString(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", line, err)
+		}
+		is.Update(msg)
+	}
+	return is
+}
+
+func TestISupport_Defaults(t *testing.T) {
+	is := NewISupport()
+	if is.Casemapping != CasemappingRFC1459 || is.ChanTypes != "#&+!" || is.PrefixModes != "ov" || is.PrefixSymbols != "@+" {
+		t.Errorf("unexpected defaults: %+v", is)
+	}
+	if is.NickLen != 9 || is.ChannelLen != 50 || is.Modes != 3 || is.ChanModes != defaultChanModes {
+		t.Errorf("unexpected default limits: %+v", is)
+	}
+	if is.Has(MonitorISupportToken) || is.UTF8Only || is.Excepts != 0 {
+		t.Errorf("no optional features should be supported by default: %+v", is)
+	}
+}
+
+func TestISupport_Update(t *testing.T) {
+	is := isupportFromRaw(t,
+		":irc.example.com 005 me CASEMAPPING=ascii CHANTYPES=# CHANMODES=eIbq,k,flj,CFLMPQScgimnprstuz PREFIX=(qaohv)~&@%+ :are supported by this server",
+		":irc.example.com 005 me NICKLEN=30 CHANNELLEN=64 TOPICLEN=390 KICKLEN=255 AWAYLEN=200 MODES=4 :are supported by this server",
+		":irc.example.com 005 me TARGMAX=NAMES:1,PRIVMSG:4,JOIN: NETWORK=Example\\x20Net MONITOR=100 EXCEPTS INVEX UTF8ONLY STATUSMSG=@+ :are supported by this server",
+	)
+	if is.Casemapping != CasemappingASCII || is.ChanTypes != "#" {
+		t.Errorf("Casemapping -> %q, ChanTypes -> %q", is.Casemapping, is.ChanTypes)
+	}
+	if is.ChanModes != (ChanModes{A: "eIbq", B: "k", C: "flj", D: "CFLMPQScgimnprstuz"}) {
+		t.Errorf("ChanModes -> %+v", is.ChanModes)
+	}
+	if is.PrefixModes != "qaohv" || is.PrefixSymbols != "~&@%+" {
+		t.Errorf("PrefixModes -> %q, PrefixSymbols -> %q", is.PrefixModes, is.PrefixSymbols)
+	}
+	if is.NickLen != 30 || is.ChannelLen != 64 || is.TopicLen != 390 || is.KickLen != 255 || is.AwayLen != 200 || is.Modes != 4 {
+		t.Errorf("unexpected limits: %+v", is)
+	}
+	if len(is.TargMax) != 3 || is.TargMax[NamesCommand] != 1 || is.TargMax[PrivmsgCommand] != 4 || is.TargMax[JoinCommand] != 0 {
+		t.Errorf("TargMax -> %v", is.TargMax)
+	}
+	if is.Network != "Example Net" || is.Monitor != 100 || !is.Has(MonitorISupportToken) {
+		t.Errorf("Network -> %q, Monitor -> %d", is.Network, is.Monitor)
+	}
+	if is.Excepts != 'e' || is.Invex != 'I' || !is.UTF8Only || is.StatusMsg != "@+" {
+		t.Errorf("Excepts -> %q, Invex -> %q, UTF8Only -> %v, StatusMsg -> %q", is.Excepts, is.Invex, is.UTF8Only, is.StatusMsg)
+	}
+}
+
+func TestISupport_Negation(t *testing.T) {
+	is := isupportFromRaw(t,
+		":irc.example.com 005 me CHANTYPES=# NICKLEN=30 MONITOR :are supported by this server",
+		":irc.example.com 005 me -CHANTYPES -MONITOR :are supported by this server",
+	)
+	if is.ChanTypes != defaultChanTypes || is.NickLen != 30 || is.Has(MonitorISupportToken) {
+		t.Errorf("unexpected features after negation: %+v", is)
+	}
+}
+
+func TestISupport_IgnoresBounce(t *testing.T) {
+	is := isupportFromRaw(t, ":irc.example.com 005 me :Try server irc2.example.com, port 6667")
+	if len(is.Tokens) != 0 {
+		t.Errorf("Tokens -> %v", is.Tokens)
+	}
+}
+
+func TestISupport_IsChannelName(t *testing.T) {
+	is := isupportFromRaw(t, ":irc.example.com 005 me CHANTYPES=#& CHANNELLEN=10 :are supported by this server")
+	testData := []struct {
+		name  string
+		valid bool
+	}{
+		{"#test", true},
+		{"&local", true},
+		{"+modeless", false},
+		{"!12345chan", false},
+		{"#123456789", true},
+		{"#1234567890", false},
+		{"#grüße", true},
+		{"#grüßeüß", false}, // 8 characters, but 12 bytes
+		{"#", false},
+		{"#a b", false},
+	}
+	for _, td := range testData {
+		if valid := is.IsChannelName(td.name); valid != td.valid {
+			t.Errorf("IsChannelName(%q) -> %v, expected: %v", td.name, valid, td.valid)
+		}
+	}
+}
+
+func TestISupport_Clone(t *testing.T) {
+	is := isupportFromRaw(t, ":irc.example.com 005 me TARGMAX=PRIVMSG:4 :are supported by this server")
+	c := is.Clone()
+	is.Update(NewMessage(EmptyPrefix, ISupportReply, "me", "TARGMAX=PRIVMSG:1", "are supported by this server"))
+	if c.TargMax[PrivmsgCommand] != 4 || c.Tokens[TargMaxISupportToken] != "PRIVMSG:4" {
+		t.Errorf("clones should not be affected by later changes: %+v", c)
+	}
+}
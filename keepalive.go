@@ -0,0 +1,164 @@
+package irc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values used for keepalive configurations that leave the respective fields empty.
+const (
+	defaultKeepaliveInterval = 60 * time.Second
+	defaultKeepaliveTimeout  = 30 * time.Second
+)
+
+// keepaliveTokenPrefix is used to recognize the PONG replies to our own PING messages.
+const keepaliveTokenPrefix = "keepalive-"
+
+// Keepalive determines how the client detects connections that have silently been lost
+// (e.g. due to NAT timeouts or half-open TCP connections): If nothing has been received
+// from the server for Interval, a PING is sent. If the server doesn't answer it with a
+// PONG within Timeout, the connection is being closed (and re-established, if a reconnect
+// policy has been configured). The round-trip time of the PING is reported as lag.
+type Keepalive struct {
+	// Interval is the idle period after which a PING is sent. Defaults to one minute.
+	Interval time.Duration
+	// Timeout is the time the server has to answer a PING. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// WithKeepalive enables client-initiated PING messages using the given configuration.
+func WithKeepalive(ka Keepalive) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.keepalive = &keepalive{config: ka}
+	}
+}
+
+func (ka Keepalive) interval() time.Duration {
+	if ka.Interval <= 0 {
+		return defaultKeepaliveInterval
+	}
+	return ka.Interval
+}
+
+func (ka Keepalive) timeout() time.Duration {
+	if ka.Timeout <= 0 {
+		return defaultKeepaliveTimeout
+	}
+	return ka.Timeout
+}
+
+// keepalive contains the state of the keepalive mechanism of a connection.
+type keepalive struct {
+	config Keepalive
+	mu     sync.Mutex
+	lag    time.Duration
+	pings  uint64 // number of PING messages sent so far, used to create unique tokens.
+}
+
+func (ka *keepalive) nextToken() string {
+	ka.mu.Lock()
+	defer ka.mu.Unlock()
+	ka.pings++
+	return keepaliveTokenPrefix + strconv.FormatUint(ka.pings, 10)
+}
+
+func (ka *keepalive) setLag(lag time.Duration) {
+	ka.mu.Lock()
+	defer ka.mu.Unlock()
+	ka.lag = lag
+}
+
+// Lag returns the round-trip time of the latest keepalive PING. Zero is returned if no
+// PING has been answered yet or if keepalive has not been enabled.
+func (conn *clientConnection) Lag() time.Duration {
+	if conn.keepalive == nil {
+		return 0
+	}
+	conn.keepalive.mu.Lock()
+	defer conn.keepalive.mu.Unlock()
+	return conn.keepalive.lag
+}
+
+// handlePongMessage passes the tokens of PONG replies to our own PING messages on to the
+// keepalive of the session. PONG replies to PING messages sent by the user are ignored.
+func (conn *clientConnection) handlePongMessage(s *session, msg Message) {
+	params := msg.Parameters()
+	if conn.keepalive == nil || len(params) == 0 {
+		return
+	}
+	token := params[len(params)-1]
+	if !strings.HasPrefix(token, keepaliveTokenPrefix) {
+		return
+	}
+	select {
+	case s.pongs <- token:
+	default:
+	}
+}
+
+// keepaliveLoop sends PING messages whenever the session has been idle for too long and
+// closes the session, if the server doesn't answer them in time.
+func (conn *clientConnection) keepaliveLoop(s *session) {
+	defer conn.wg.Done()
+	interval, timeout := conn.keepalive.config.interval(), conn.keepalive.config.timeout()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-s.done:
+			return
+		}
+		if idle := time.Since(s.lastActivity()); idle < interval {
+			timer.Reset(interval - idle)
+			continue
+		}
+		// Discard late replies to earlier PING messages.
+		select {
+		case <-s.pongs:
+		default:
+		}
+		token := conn.keepalive.nextToken()
+		ping, _ := NewPingMessage(EmptyPrefix, token)
+		select {
+		case conn.out <- ping:
+		case <-s.done:
+			return
+		}
+		sent := time.Now()
+		timer.Reset(timeout)
+		if !conn.awaitPong(s, token, timer.C) {
+			s.close()
+			conn.reportError(fmt.Errorf("no PONG received within %v, connection has been closed", timeout))
+			return
+		}
+		conn.keepalive.setLag(time.Since(sent))
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(interval)
+	}
+}
+
+// awaitPong waits for the PONG reply that carries the given token. It returns false, if
+// the timeout expires or the session ends before.
+func (conn *clientConnection) awaitPong(s *session, token string, timeout <-chan time.Time) bool {
+	for {
+		select {
+		case t := <-s.pongs:
+			if t == token {
+				return true
+			}
+		case <-timeout:
+			return false
+		case <-s.done:
+			return false
+		}
+	}
+}
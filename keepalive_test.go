@@ -0,0 +1,126 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientConnection_KeepaliveLag(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithKeepalive(Keepalive{Interval: 50 * time.Millisecond, Timeout: fakeServerTimeout}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Close()
+	if lag := conn.Lag(); lag != 0 {
+		t.Errorf("Lag() -> %v before the first PING, expected: 0", lag)
+	}
+
+	ping := srv.expect("PING")
+	params := ping.Parameters()
+	token := params[len(params)-1]
+	// Replies to PING messages that haven't been sent by the keepalive are ignored.
+	srv.send(":irc.example.com PONG irc.example.com :something-else")
+	time.Sleep(20 * time.Millisecond)
+	srv.send(":irc.example.com PONG irc.example.com :" + token)
+	deadline := time.Now().Add(fakeServerTimeout)
+	for conn.Lag() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Lag() should be known once the PING has been answered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if lag := conn.Lag(); lag < 20*time.Millisecond {
+		t.Errorf("Lag() -> %v, expected at least 20ms", lag)
+	}
+
+	// The next PING uses a new token.
+	next := srv.expect("PING").Parameters()
+	if next[len(next)-1] == token {
+		t.Errorf("token %q has been used twice", token)
+	}
+}
+
+func TestClientConnection_KeepaliveTimeout(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithKeepalive(Keepalive{Interval: 50 * time.Millisecond, Timeout: 50 * time.Millisecond}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	errs := make(chan error, 8)
+	go func() {
+		for err := range conn.Err() {
+			errs <- err
+		}
+	}()
+	go func() {
+		for range conn.In() {
+		}
+	}()
+	go func() {
+		for range conn.State() {
+		}
+	}()
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	// The server never answers the PING, so the connection is considered lost.
+	srv.expect("PING")
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("an error should be reported once the PING times out")
+		}
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("the connection should be closed once the PING times out")
+	}
+	waited := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("the connection should shut down once the PING times out")
+	}
+}
+
+func TestClientConnection_KeepaliveIdle(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithKeepalive(Keepalive{Interval: 100 * time.Millisecond}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Close()
+	// As long as the server keeps sending messages, no PING is necessary.
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		srv.send(":alice!alice@localhost PRIVMSG johndoe :hello")
+		time.Sleep(40 * time.Millisecond)
+	}
+	srv.expect("PING")
+	if elapsed := time.Since(start); elapsed < 240*time.Millisecond {
+		t.Errorf("PING has been sent after %v, although the connection wasn't idle", elapsed)
+	}
+}
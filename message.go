@@ -3,15 +3,26 @@ package irc
 import (
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
 // Maximum message length for any IRC message according to RfC-2812
 const MsgMaxLen = 512
 
+// MsgTagsMaxLen is the maximum length of the message tags section (including the
+// leading '@' and the trailing space) as specified by the IRCv3 message-tags
+// specification. The tags are not counted against MsgMaxLen.
+const MsgTagsMaxLen = 8191
+
 // Regular expression used to validate nicknames.
-var nickNameRegexp = regexp.MustCompile("\\A[a-z_\\-\\[\\]\\\\^{}|`][a-z0-9_\\-\\[\\]\\\\^{}|`]*\\z")
+var nickNameRegexp = regexp.MustCompile("\\A[a-zA-Z_\\-\\[\\]\\\\^{}|`][a-zA-Z0-9_\\-\\[\\]\\\\^{}|`]*\\z")
+
+// Regular expression used to validate commands (either letters or a 3-digit numeric reply).
+var commandRegexp = regexp.MustCompile("\\A(?:[a-zA-Z]+|\\d{3})\\z")
+
+// invalidMessageCharacters contains the characters that must not appear anywhere within
+// a message (NUL, CR and LF).
+const invalidMessageCharacters = "\x00\r\n"
 
 // MessageDelimiter is the message delimiter that is sent after each
 // message (Carriage-return + line-feed)
@@ -21,14 +32,14 @@ const messageDelimiter string = "\r\n"
 // the Command and the Parameters from each other. (Space, thus ASCII: 0x20)
 const messagePartSeparator string = " "
 
+// messageTagsPresenceIndicator is used in the beginning of raw messages
+// to indicate the presence of IRCv3 message tags. (At sign, thus ASCII: 0x40)
+const messageTagsPresenceIndicator = "@"
+
 // messagePrefixPresenceIndicator is used in the beginning of raw messages
 // to indicate the presence of a message Prefix. (Colon, thus ASCII: 0x3b)
 var messagePrefixPresenceIndicator = ":"
 
-// TrailingMessageParameterPresenceIndicator is used in message to indicate
-// that the last message parameter may contain whitespace characters.
-const trailingMessagePartPresenceIndicator = " :"
-
 // MaxMessageParameterCount defines the maximum amount of Parameters that
 // can be contained within a message.
 const MaxMessageParameterCount = 15
@@ -122,30 +133,51 @@ func (p *prefix) String() string {
 	}
 }
 
+// NewPrefixFromString creates a prefix from its string representation (without the
+// leading colon). Prefixes that can not be parsed are treated as hostnames.
 func NewPrefixFromString(str string) Prefix {
+	pfx, err := parsePrefix(str)
+	if err != nil {
+		return &prefix{
+			ptype:    PrefixHostname,
+			hostname: str,
+		}
+	}
+	return pfx
+}
+
+// parsePrefix parses the string representation of a prefix (without the leading colon).
+// An error is returned, if the prefix is empty or if any of its parts is empty.
+func parsePrefix(str string) (Prefix, error) {
+	if str == "" {
+		return nil, fmt.Errorf("empty prefix")
+	}
 	pfx := &prefix{}
-	if strings.IndexRune(str, '@') != -1 {
-		restAndHost := strings.Split(str, "@")
-		pfx.host = restAndHost[1]
-		if strings.IndexRune(restAndHost[0], '!') != -1 {
+	if at := strings.IndexRune(str, '@'); at != -1 {
+		pfx.host = str[at+1:]
+		if excl := strings.IndexRune(str[:at], '!'); excl != -1 {
 			pfx.ptype = PrefixNicknameUserHost
-			nicknameAndUser := strings.Split(restAndHost[0], "!")
-			pfx.nickname = nicknameAndUser[0]
-			pfx.user = nicknameAndUser[1]
-
+			pfx.nickname = str[:excl]
+			pfx.user = str[excl+1 : at]
+			if pfx.user == "" {
+				return nil, fmt.Errorf("empty user in prefix: \"%s\"", str)
+			}
 		} else {
 			pfx.ptype = PrefixNicknameHost
-			pfx.nickname = restAndHost[0]
+			pfx.nickname = str[:at]
+		}
+		if pfx.nickname == "" || pfx.host == "" {
+			return nil, fmt.Errorf("empty nickname or host in prefix: \"%s\"", str)
 		}
-
 	} else {
 		pfx.ptype = PrefixHostname
 		pfx.hostname = str
 	}
-	return pfx
+	return pfx, nil
 }
 
 type Message interface {
+	Tags() Tags
 	Prefix() Prefix
 	Command() Command
 	Parameters() []string
@@ -161,6 +193,13 @@ type message struct {
 	prefix     Prefix
 	command    Command
 	parameters []string
+	tags       Tags
+}
+
+// Tags returns the IRCv3 message tags that have been attached to the message.
+// If the message carries no tags, the returned map is nil.
+func (msg *message) Tags() Tags {
+	return msg.tags
 }
 
 func (msg *message) Prefix() Prefix {
@@ -175,6 +214,15 @@ func (msg *message) Parameters() []string {
 	return msg.parameters
 }
 
+// param returns the parameter at the given index or an empty string, if the message
+// doesn't carry that many parameters.
+func (msg *message) param(i int) string {
+	if i < len(msg.parameters) {
+		return msg.parameters[i]
+	}
+	return ""
+}
+
 func NewMessage(prefix Prefix, command Command, parameters ...string) Message {
 	return &message{
 		prefix:     prefix,
@@ -187,56 +235,154 @@ func NewMessageWithoutPrefix(command Command, parameters ...string) Message {
 	return NewMessage(EmptyPrefix, command, parameters...)
 }
 
+// NewMessageWithTags creates a new message that carries the given IRCv3 message tags.
+func NewMessageWithTags(tags Tags, prefix Prefix, command Command, parameters ...string) Message {
+	return &message{
+		prefix:     prefix,
+		command:    command,
+		parameters: parameters,
+		tags:       tags,
+	}
+}
+
+// ParseErrorReason describes why a raw message could not be parsed.
+type ParseErrorReason int
+
+const (
+	// ParseErrorEmptyMessage is reported for empty lines.
+	ParseErrorEmptyMessage ParseErrorReason = iota
+	// ParseErrorInvalidCharacter is reported if the message contains NUL, CR or LF characters.
+	ParseErrorInvalidCharacter
+	// ParseErrorInvalidTags is reported if the tags indicator is not followed by any tags.
+	ParseErrorInvalidTags
+	// ParseErrorInvalidPrefix is reported if the prefix (or any of its parts) is empty.
+	ParseErrorInvalidPrefix
+	// ParseErrorMissingCommand is reported if the message doesn't contain a command.
+	ParseErrorMissingCommand
+	// ParseErrorInvalidCommand is reported if the command is neither a word nor a numeric reply.
+	ParseErrorInvalidCommand
+	// ParseErrorTooManyParameters is reported if the message contains more than
+	// MaxMessageParameterCount parameters.
+	ParseErrorTooManyParameters
+)
+
+func (r ParseErrorReason) String() string {
+	switch r {
+	case ParseErrorEmptyMessage:
+		return "empty message"
+	case ParseErrorInvalidCharacter:
+		return "message contains NUL, CR or LF characters"
+	case ParseErrorInvalidTags:
+		return "invalid message tags"
+	case ParseErrorInvalidPrefix:
+		return "invalid prefix"
+	case ParseErrorMissingCommand:
+		return "missing command"
+	case ParseErrorInvalidCommand:
+		return "invalid command"
+	case ParseErrorTooManyParameters:
+		return fmt.Sprintf("more than %d parameters", MaxMessageParameterCount)
+	default:
+		return fmt.Sprintf("unknown reason (%d)", int(r))
+	}
+}
+
+// ParseError is returned by NewMessageFromString, if a raw message could not be parsed.
+type ParseError struct {
+	// Reason tells why the message could not be parsed.
+	Reason ParseErrorReason
+	// Raw contains the raw message.
+	Raw string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("could not parse message %q: %s", e.Raw, e.Reason)
+}
+
 // NewMessageFromString create a new message by parsing a raw CR-LF-terminated
-// raw string as received from a connection.
+// raw string as received from a connection. If the message can not be parsed, a
+// *ParseError is returned. Messages without prefix carry the EmptyPrefix.
 func NewMessageFromString(rawStr string) (msg Message, err error) {
 
-	var prefix Prefix
+	var tags Tags
+	var prefix Prefix = EmptyPrefix
 	var command Command
 	var parameters []string
 
+	fail := func(reason ParseErrorReason) (Message, error) {
+		return nil, &ParseError{Reason: reason, Raw: rawStr}
+	}
+
 	// Let's first cut of the CR-LF message separator
-	rawStr = strings.TrimRight(rawStr, messageDelimiter)
+	str := strings.TrimRight(rawStr, messageDelimiter)
+	if strings.ContainsAny(str, invalidMessageCharacters) {
+		return fail(ParseErrorInvalidCharacter)
+	}
+	str = strings.TrimLeft(str, messagePartSeparator)
+	if str == "" {
+		return fail(ParseErrorEmptyMessage)
+	}
+
+	// Checks, if the message contains IRCv3 message tags and processes them if they are present.
+	if strings.HasPrefix(str, messageTagsPresenceIndicator) {
+		var rawTags string
+		rawTags, str = nextMessagePart(str[len(messageTagsPresenceIndicator):])
+		if rawTags == "" {
+			return fail(ParseErrorInvalidTags)
+		}
+		if tags = NewTagsFromString(rawTags); len(tags) == 0 {
+			tags = nil
+		}
+	}
 
 	// Checks, if the message contains a Prefix and processes it if it is present.
-	if strings.HasPrefix(rawStr, messagePrefixPresenceIndicator) {
-		rawStr = strings.TrimLeft(rawStr, messagePrefixPresenceIndicator)
-		pfxAndRest := strings.SplitN(rawStr, messagePartSeparator, 2)
-		prefix = NewPrefixFromString(pfxAndRest[0])
-		rawStr = pfxAndRest[1]
+	if strings.HasPrefix(str, messagePrefixPresenceIndicator) {
+		var rawPrefix string
+		rawPrefix, str = nextMessagePart(str[len(messagePrefixPresenceIndicator):])
+		if prefix, err = parsePrefix(rawPrefix); err != nil {
+			return fail(ParseErrorInvalidPrefix)
+		}
 	}
 
 	// Extracts the Command / reply code from the message and processes it.
-	cmdAndParams := strings.SplitN(rawStr, messagePartSeparator, 2)
-	command = Command(cmdAndParams[0])
-	rawStr = cmdAndParams[1]
-
-	// Now let's check the Parameters
-	var trailingParam *string = nil
-	if strings.Contains(rawStr, trailingMessagePartPresenceIndicator) {
-		paramsMiddleAndTrailing := strings.SplitN(rawStr, trailingMessagePartPresenceIndicator, 2)
-		trailingParam = &paramsMiddleAndTrailing[1]
-		rawStr = paramsMiddleAndTrailing[0]
+	var rawCommand string
+	rawCommand, str = nextMessagePart(str)
+	if rawCommand == "" {
+		return fail(ParseErrorMissingCommand)
 	}
-
-	// And now all the non-trailing (middle) Parameters...
-	middleParams := strings.Split(rawStr, messagePartSeparator)
-	pCount := len(middleParams)
-	if trailingParam != nil {
-		pCount++
+	if !commandRegexp.MatchString(rawCommand) {
+		return fail(ParseErrorInvalidCommand)
 	}
-	parameters = make([]string, pCount)
-	for i := 0; i < len(middleParams); i++ {
-		parameters[i] = middleParams[i]
+	command = Command(rawCommand)
+
+	// Now let's check the Parameters. The trailing parameter is introduced by a colon
+	// and may contain spaces (and colons) or even be empty.
+	for str != "" {
+		if strings.HasPrefix(str, messagePrefixPresenceIndicator) {
+			parameters = append(parameters, str[len(messagePrefixPresenceIndicator):])
+			break
+		}
+		var param string
+		param, str = nextMessagePart(str)
+		parameters = append(parameters, param)
 	}
-	if trailingParam != nil {
-		parameters[pCount-1] = *trailingParam
+	if len(parameters) > MaxMessageParameterCount {
+		return fail(ParseErrorTooManyParameters)
 	}
 
-	msg = NewMessage(prefix, command, parameters...)
+	msg = NewMessageWithTags(tags, prefix, command, parameters...)
 	return
 }
 
+// nextMessagePart splits off the next space-separated part of a raw message. Consecutive
+// spaces are treated like a single one.
+func nextMessagePart(str string) (part string, rest string) {
+	if i := strings.Index(str, messagePartSeparator); i != -1 {
+		return str[:i], strings.TrimLeft(str[i:], messagePartSeparator)
+	}
+	return str, ""
+}
+
 // IsValid checks a message for validity. Several checks will be performed to
 // check whether the contents of the message complies with the various RfCs and
 // specifications. The returned value valid will be set to false, if any check
@@ -244,13 +390,30 @@ func NewMessageFromString(rawStr string) (msg Message, err error) {
 // will contain at least one issue with the message.
 func (msg *message) IsValid() (valid bool, errs []error) {
 	pc := len(msg.parameters)
-	msgLen := len(msg.String()) + len(messageDelimiter)
+	tagsLen := 0
+	if len(msg.tags) > 0 {
+		tagsLen = len(messageTagsPresenceIndicator) + len(msg.tags.String()) + len(messagePartSeparator)
+	}
+	if tagsLen > MsgTagsMaxLen {
+		errs = append(errs, fmt.Errorf("message tags length is %d bytes, which exceeds the allowed maximum of %d bytes", tagsLen, MsgTagsMaxLen))
+	}
+	msgLen := len(msg.String()) - tagsLen + len(messageDelimiter)
 	if msgLen > MsgMaxLen {
 		errs = append(errs, fmt.Errorf("message length is %d bytes, which exceeds the allowed maximum of %d bytes", msgLen, MsgMaxLen))
 	}
 	if pc > MaxMessageParameterCount {
 		errs = append(errs, fmt.Errorf("message has %d parameters, which exceeds the allowed maximum of %d parameters", pc, MaxMessageParameterCount))
 	}
+	if !commandRegexp.MatchString(msg.command.String()) {
+		errs = append(errs, fmt.Errorf("invalid command: \"%s\"", msg.command))
+	}
+	for i, p := range msg.parameters {
+		if strings.ContainsAny(p, invalidMessageCharacters) {
+			errs = append(errs, fmt.Errorf("parameter %d contains NUL, CR or LF characters", i+1))
+		} else if i < pc-1 && isTrailingParameter(p) {
+			errs = append(errs, fmt.Errorf("parameter %d is empty, contains spaces or begins with a colon, but is not the last parameter", i+1))
+		}
+	}
 	valid = len(errs) == 0
 	return
 }
@@ -259,136 +422,25 @@ func (msg *message) IsValid() (valid bool, errs []error) {
 // This operation achieves the exact opposite of the NewMessageFromString
 // function.
 func (msg *message) String() (str string) {
-	str = fmt.Sprintf(":%v %s", msg.prefix, msg.command)
-	if msg.parameters != nil {
-		for _, p := range msg.parameters {
-			if strings.ContainsRune(p, ' ') {
-				str += fmt.Sprintf(" :%s", p)
-			} else {
-				str += fmt.Sprintf(" %s", p)
-			}
-		}
-	}
-	return
-}
-
-type PassMessage interface {
-	Message
-	Password() string
-}
-
-type passMessage struct {
-	message
-}
-
-func (msg *passMessage) Password() string {
-	return msg.Parameters()[0]
-}
-
-func NewPassMessage(prefix Prefix, password string) (msg PassMessage) {
-	return &passMessage{
-		message{
-			prefix,
-			PassCommand,
-			[]string{password},
-		},
-	}
-}
-
-type PongMessage interface {
-	Message
-	Server1() string
-}
-
-type pongMessage struct {
-	message
-}
-
-func (msg *pongMessage) Server1() string {
-	return msg.parameters[0]
-}
-
-func NewPongMessage(prefix Prefix, server1 string) PongMessage {
-	return &pongMessage{
-		message{
-			prefix,
-			PongCommand,
-			[]string{server1},
-		},
-	}
-}
-
-func NickMessage(nickname string) (msg Message) {
-	msg = NewMessageWithoutPrefix(NickCommand, nickname)
-	if !isValidNickname(nickname) {
-		panic(fmt.Errorf("invalid nickname: %s", nickname))
+	if len(msg.tags) > 0 {
+		str = messageTagsPresenceIndicator + msg.tags.String() + messagePartSeparator
 	}
-	return
-}
-
-type UserMessage interface {
-	Message
-	Username() string
-	Realname() string
-	Mode() UserModes
-}
-
-type userMessage struct {
-	message
-}
-
-func (msg *userMessage) Username() string {
-	return msg.parameters[0]
-}
-
-func (msg *userMessage) Mode() UserModes {
-	if mode, err := strconv.Atoi(msg.parameters[1]); err != nil {
-		panic(err)
-	} else {
-		return UserModesFromBitmask(mode)
+	if msg.prefix != nil && msg.prefix.Type() != PrefixEmpty {
+		str += fmt.Sprintf(":%v ", msg.prefix)
 	}
-}
-
-func (msg *userMessage) Realname() string {
-	return msg.parameters[3]
-}
-
-func NewUserMessage(prefix Prefix, username string, realname string, mode ...UserMode) (msg Message) {
-	// TODO(headcr4sh): Validate username
-	return &userMessage{
-		message{
-			prefix,
-			UserCommand,
-			[]string{username, strconv.Itoa(UserModes(mode).Bitmask()), "*", realname},
-		},
+	str += msg.command.String()
+	for i, p := range msg.parameters {
+		if i == len(msg.parameters)-1 && isTrailingParameter(p) {
+			str += fmt.Sprintf(" :%s", p)
+		} else {
+			str += fmt.Sprintf(" %s", p)
+		}
 	}
-}
-
-func NewJoinMessage(channel string) (msg Message) {
-	// TODO(headcr4sh): Validate channel name
-	msg = NewMessageWithoutPrefix(JoinCommand, channel)
 	return
 }
 
-type QuitMessage interface {
-	Message
-	Reason() string
-}
-
-type quitMessage struct {
-	message
-}
-
-func (msg *quitMessage) Reason() string {
-	return msg.Parameters()[0]
-}
-
-func NewQuitMessage(prefix Prefix, reason string) (msg QuitMessage) {
-	return &quitMessage{
-		message{
-			prefix,
-			QuitCommand,
-			[]string{reason},
-		},
-	}
+// isTrailingParameter checks if the given (last) parameter has to be sent as trailing
+// parameter, because it is empty, contains spaces or begins with a colon.
+func isTrailingParameter(p string) bool {
+	return p == "" || strings.ContainsRune(p, ' ') || strings.HasPrefix(p, messagePrefixPresenceIndicator)
 }
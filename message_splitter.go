@@ -0,0 +1,411 @@
+package irc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Limits that are assumed if the client's own prefix ("nick!user@host") is unknown.
+const (
+	defaultUserLen = 10
+	maxHostLen     = 63
+)
+
+// minSplitTextLen is the minimum number of bytes that must be available for the text of
+// a message, otherwise splitting is considered to be futile.
+const minSplitTextLen = 32
+
+// IRC formatting codes.
+const (
+	formatBold          = '\x02'
+	formatColor         = '\x03'
+	formatHexColor      = '\x04'
+	formatReset         = '\x0f'
+	formatMonospace     = '\x11'
+	formatReverse       = '\x16'
+	formatItalic        = '\x1d'
+	formatStrikethrough = '\x1e'
+	formatUnderline     = '\x1f'
+)
+
+// formatToggles contains the formatting codes that switch a style on or off.
+var formatToggles = []byte{formatBold, formatItalic, formatUnderline, formatStrikethrough, formatMonospace, formatReverse}
+
+// lineBreakNormalizer converts all kinds of line breaks into LF.
+var lineBreakNormalizer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// batchReferences generates unique batch reference tags.
+var batchReferences uint64
+
+// MessageSplitter breaks texts that are too long to be sent within a single PRIVMSG or
+// NOTICE message into multiple messages. Texts are split on word boundaries, if possible,
+// and never within UTF-8 characters or formatting codes. Formatting (bold, colors, ...)
+// that is active at the end of one message is restored at the beginning of the next one.
+type MessageSplitter struct {
+	// PrefixLen is the length of the client's own prefix ("nick!user@host"), which the
+	// server prepends when relaying the messages to others. If zero, a conservative
+	// estimate based on the maximum user and host lengths is being used.
+	PrefixLen int
+	// MultilineMaxBytes and MultilineMaxLines are the limits announced by the server
+	// along with the draft/multiline capability. If MultilineMaxBytes is zero, no
+	// multiline batches will be used and each line of the text will be sent as a
+	// separate message.
+	MultilineMaxBytes int
+	MultilineMaxLines int
+}
+
+// ParseMultilineLimits parses the value of the draft/multiline capability
+// (e.g. "max-bytes=4096,max-lines=24").
+func ParseMultilineLimits(value string) (maxBytes int, maxLines int) {
+	for _, kv := range strings.Split(value, ",") {
+		keyAndValue := strings.SplitN(kv, "=", 2)
+		if len(keyAndValue) != 2 {
+			continue
+		}
+		n, _ := strconv.Atoi(keyAndValue[1])
+		switch keyAndValue[0] {
+		case "max-bytes":
+			maxBytes = n
+		case "max-lines":
+			maxLines = n
+		}
+	}
+	return
+}
+
+// Split creates the PRIVMSG or NOTICE messages needed to send the given text to the
+// target. Line breaks within the text start a new message (or a new line within a
+// multiline batch).
+func (s MessageSplitter) Split(command Command, target string, text string) ([]Message, error) {
+	if command != PrivmsgCommand && command != NoticeCommand {
+		return nil, fmt.Errorf("only PRIVMSG and NOTICE messages can be split, but got: %s", command)
+	}
+	if err := validateTarget(target); err != nil {
+		return nil, err
+	}
+	if strings.ContainsRune(text, '\x00') {
+		return nil, fmt.Errorf("text must not contain NUL characters")
+	}
+	prefixLen := s.PrefixLen
+	if prefixLen <= 0 {
+		prefixLen = defaultNickLen + 1 + defaultUserLen + 1 + maxHostLen
+	}
+	// ":<prefix> <command> <target> :<text>\r\n"
+	maxLen := MsgMaxLen - (1 + prefixLen + 1 + len(command) + 1 + len(target) + 2 + len(messageDelimiter))
+	if maxLen < minSplitTextLen {
+		return nil, fmt.Errorf("target is too long to send any text: \"%s\"", target)
+	}
+
+	lines := strings.Split(lineBreakNormalizer.Replace(text), "\n")
+	if s.MultilineMaxBytes > 0 && (len(lines) > 1 || len(text) > maxLen) {
+		if msgs := s.splitMultiline(command, target, lines, maxLen); len(msgs) > 0 {
+			return msgs, nil
+		}
+		return nil, fmt.Errorf("no text to send")
+	}
+	var msgs []Message
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		for _, chunk := range splitText(line, maxLen, true) {
+			msgs = append(msgs, NewMessageWithoutPrefix(command, target, chunk))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no text to send")
+	}
+	return msgs, nil
+}
+
+// multilineChunk is a part of a line within a multiline batch.
+type multilineChunk struct {
+	text   string
+	concat bool // true, if the chunk continues the previous line.
+}
+
+func (s MessageSplitter) splitMultiline(command Command, target string, lines []string, maxLen int) []Message {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var chunks []multilineChunk
+	for _, line := range lines {
+		if line == "" {
+			chunks = append(chunks, multilineChunk{})
+			continue
+		}
+		for i, chunk := range splitText(line, maxLen, false) {
+			chunks = append(chunks, multilineChunk{text: chunk, concat: i > 0})
+		}
+	}
+
+	var msgs []Message
+	for len(chunks) > 0 {
+		n, size := 0, 0
+		for ; n < len(chunks); n++ {
+			add := len(chunks[n].text)
+			if n > 0 && !chunks[n].concat {
+				add++ // line break
+			}
+			if n > 0 && (size+add > s.MultilineMaxBytes || (s.MultilineMaxLines > 0 && n >= s.MultilineMaxLines)) {
+				break
+			}
+			size += add
+		}
+		ref := strconv.FormatUint(atomic.AddUint64(&batchReferences, 1), 36)
+		msgs = append(msgs, NewMessageWithoutPrefix(BatchCommand, "+"+ref, BatchTypeMultiline, target))
+		for i, chunk := range chunks[:n] {
+			tags := Tags{BatchMessageTag: ref}
+			// The first line of a batch must never be concatenated.
+			if chunk.concat && i > 0 {
+				tags[MultilineConcatMessageTag] = ""
+			}
+			msgs = append(msgs, NewMessageWithTags(tags, EmptyPrefix, command, target, chunk.text))
+		}
+		msgs = append(msgs, NewMessageWithoutPrefix(BatchCommand, "-"+ref))
+		chunks = chunks[n:]
+	}
+	return msgs
+}
+
+// formatting keeps track of the formatting codes that are active at some point of a text.
+type formatting struct {
+	toggles map[byte]bool
+	fg, bg  string // colors, both empty if the default colors apply.
+	hex     string // hex color code (including the leading '\x04'), empty if not active.
+}
+
+func (f *formatting) apply(code string) {
+	switch code[0] {
+	case formatReset:
+		*f = formatting{}
+	case formatColor:
+		colors := strings.SplitN(code[1:], ",", 2)
+		switch {
+		case colors[0] == "":
+			f.fg, f.bg = "", ""
+		case len(colors) == 2:
+			f.fg, f.bg = colors[0], colors[1]
+		default:
+			f.fg = colors[0]
+		}
+	case formatHexColor:
+		f.hex = ""
+		if len(code) > 1 {
+			f.hex = code
+		}
+	default:
+		if f.toggles == nil {
+			f.toggles = make(map[byte]bool)
+		}
+		f.toggles[code[0]] = !f.toggles[code[0]]
+	}
+}
+
+// String returns the formatting codes that restore the formatting.
+func (f formatting) String() string {
+	var sb strings.Builder
+	for _, c := range formatToggles {
+		if f.toggles[c] {
+			sb.WriteByte(c)
+		}
+	}
+	if f.fg != "" {
+		// Colors are padded, so that digits following the code are not mistaken as
+		// being a part of it.
+		sb.WriteByte(formatColor)
+		sb.WriteString(padColor(f.fg))
+		if f.bg != "" {
+			sb.WriteString(",")
+			sb.WriteString(padColor(f.bg))
+		}
+	}
+	sb.WriteString(f.hex)
+	return sb.String()
+}
+
+func padColor(color string) string {
+	if len(color) == 1 {
+		return "0" + color
+	}
+	return color
+}
+
+// formattingCodeLen returns the length of the formatting code at the beginning of the
+// given string, or zero if the string doesn't begin with a formatting code.
+func formattingCodeLen(str string) int {
+	switch str[0] {
+	case formatBold, formatItalic, formatUnderline, formatStrikethrough, formatMonospace, formatReverse, formatReset:
+		return 1
+	case formatColor:
+		n := 1 + countPrefix(str[1:], 2, isDigit)
+		if n > 1 && n+1 < len(str) && str[n] == ',' && isDigit(str[n+1]) {
+			n += 1 + countPrefix(str[n+1:], 2, isDigit)
+		}
+		return n
+	case formatHexColor:
+		n := 1
+		if countPrefix(str[1:], 6, isHexDigit) == 6 {
+			n += 6
+			if n+7 <= len(str) && str[n] == ',' && countPrefix(str[n+1:], 6, isHexDigit) == 6 {
+				n += 7
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+func countPrefix(str string, max int, fn func(c byte) bool) int {
+	n := 0
+	for n < max && n < len(str) && fn(str[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// splitAtoms breaks the text into parts that must not be split any further: single
+// characters (UTF-8 sequences) and formatting codes.
+func splitAtoms(text string) []string {
+	var atoms []string
+	for len(text) > 0 {
+		n := formattingCodeLen(text)
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(text)
+		}
+		atoms = append(atoms, text[:n])
+		text = text[n:]
+	}
+	return atoms
+}
+
+// splitText breaks the given line into chunks of at most maxLen bytes. If
+// restoreFormatting is true, every chunk begins with the formatting codes that have
+// been active at the end of the previous chunk and spaces at which the line has been
+// split are dropped. Otherwise, the chunks can be concatenated to get the line back.
+func splitText(line string, maxLen int, restoreFormatting bool) []string {
+	if len(line) <= maxLen {
+		return []string{line}
+	}
+	atoms := splitAtoms(line)
+	var chunks []string
+	var state formatting
+	for i := 0; i < len(atoms); {
+		prefix := ""
+		if restoreFormatting {
+			prefix = state.String()
+		}
+		size, end, lastSpace := len(prefix), i, -1
+		for end < len(atoms) && size+len(atoms[end]) <= maxLen {
+			if atoms[end] == " " {
+				lastSpace = end
+			}
+			size += len(atoms[end])
+			end++
+		}
+		next := end
+		switch {
+		case end == i:
+			// Not even a single character fits, which can only be caused by excessive formatting.
+			prefix, end, next = "", i+1, i+1
+		case end < len(atoms) && atoms[end] == " ":
+			next = end + 1
+			if !restoreFormatting {
+				// Keep the space, so that the chunks can be concatenated. If it doesn't
+				// fit, it moves to the next chunk.
+				if size < maxLen {
+					end = next
+				} else {
+					next = end
+				}
+			}
+		case end < len(atoms) && lastSpace > i:
+			end, next = lastSpace, lastSpace+1
+			if !restoreFormatting {
+				end = next
+			}
+		}
+		chunks = append(chunks, prefix+strings.Join(atoms[i:end], ""))
+		for _, atom := range atoms[i:next] {
+			if formattingCodeLen(atom) > 0 {
+				state.apply(atom)
+			}
+		}
+		i = next
+	}
+	return chunks
+}
+
+// prefixLen returns the length of the client's own prefix ("nick!user@host"). If the
+// user and host are unknown, the maximum lengths are assumed.
+func (conn *clientConnection) prefixLen() int {
+	conn.nickMu.RLock()
+	nick, userhost := conn.nickname, conn.userhost
+	conn.nickMu.RUnlock()
+	if userhost != "" {
+		return len(nick) + 1 + len(userhost)
+	}
+	conn.capMu.RLock()
+	userLen := isupportIntValue(conn.isupport.Tokens, UserLenISupportToken, defaultUserLen)
+	conn.capMu.RUnlock()
+	// The server may prefix the user with a '~', if it could not be verified.
+	return len(nick) + 1 + 1 + userLen + 1 + maxHostLen
+}
+
+// trackUserhost keeps track of our own user and host, which the server announces within
+// RPL_WELCOME and as prefix of our own JOIN messages.
+func (conn *clientConnection) trackUserhost(msg Message) {
+	var pfx Prefix
+	nick := conn.Nickname()
+	switch msg.Command() {
+	case WelcomeReply:
+		if params := msg.Parameters(); len(params) > 1 {
+			nick = params[0]
+			words := strings.Fields(params[len(params)-1])
+			if len(words) > 0 {
+				pfx, _ = parsePrefix(words[len(words)-1])
+			}
+		}
+	case JoinCommand:
+		pfx = msg.Prefix()
+	}
+	if pfx == nil || pfx.User() == "" || pfx.Host() == "" || !conn.Casemapping().Equal(pfx.Nickname(), nick) {
+		return
+	}
+	conn.nickMu.Lock()
+	defer conn.nickMu.Unlock()
+	conn.userhost = pfx.User() + "@" + pfx.Host()
+}
+
+// SendText sends the given text to the target using PRIVMSG or NOTICE messages. Texts
+// that are too long for a single message are split (see MessageSplitter). If the
+// draft/multiline capability has been enabled, the messages are sent as a batch.
+func (conn *clientConnection) SendText(command Command, target string, text string) error {
+	splitter := MessageSplitter{PrefixLen: conn.prefixLen()}
+	if conn.HasCapability(Multiline) && conn.HasCapability(Batch) {
+		value, _ := conn.CapabilityValue(Multiline)
+		splitter.MultilineMaxBytes, splitter.MultilineMaxLines = ParseMultilineLimits(value)
+	}
+	msgs, err := splitter.Split(command, target, text)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		conn.out <- msg
+	}
+	return nil
+}
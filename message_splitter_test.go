@@ -0,0 +1,179 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// relayedLen returns the length of the given PRIVMSG or NOTICE message as relayed by
+// the server using a prefix of the given length.
+func relayedLen(msg Message, prefixLen int) int {
+	params := msg.Parameters()
+	return len(fmt.Sprintf(":%*s %s %s :%s\r\n", prefixLen, "", msg.Command(), params[0], params[1]))
+}
+
+func TestMessageSplitter_Short(t *testing.T) {
+	msgs, err := MessageSplitter{}.Split(PrivmsgCommand, "#test", "Hello, World!")
+	if err != nil || len(msgs) != 1 || msgs[0].String() != "PRIVMSG #test :Hello, World!" {
+		t.Errorf("Split() -> %v, %v", msgs, err)
+	}
+}
+
+func TestMessageSplitter_WordBoundaries(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet ", 50)
+	splitter := MessageSplitter{PrefixLen: 30}
+	msgs, err := splitter.Split(NoticeCommand, "#test", text)
+	if err != nil {
+		t.Fatalf("Split() -> %v", err)
+	}
+	var words []string
+	for _, msg := range msgs {
+		if l := relayedLen(msg, 30); l > MsgMaxLen {
+			t.Errorf("message exceeds MsgMaxLen (%d bytes): %s", l, msg)
+		}
+		if valid, errs := msg.IsValid(); !valid {
+			t.Errorf("invalid message %s: %v", msg, errs)
+		}
+		words = append(words, strings.Fields(msg.Parameters()[1])...)
+	}
+	if len(msgs) != 3 || strings.Join(words, " ") != strings.TrimSpace(text) {
+		t.Errorf("words must not be split: %v", msgs)
+	}
+}
+
+func TestMessageSplitter_UTF8(t *testing.T) {
+	text := strings.Repeat("ä", 600)
+	msgs, err := MessageSplitter{PrefixLen: 30}.Split(PrivmsgCommand, "alice", text)
+	if err != nil {
+		t.Fatalf("Split() -> %v", err)
+	}
+	joined := ""
+	for _, msg := range msgs {
+		chunk := msg.Parameters()[1]
+		if !utf8.ValidString(chunk) || relayedLen(msg, 30) > MsgMaxLen {
+			t.Errorf("invalid chunk: %q", chunk)
+		}
+		joined += chunk
+	}
+	if joined != text {
+		t.Error("no characters should be lost")
+	}
+}
+
+func TestMessageSplitter_Formatting(t *testing.T) {
+	text := "\x02bold \x034,12" + strings.Repeat("colored ", 80) + "\x0f" + strings.Repeat("plain ", 80)
+	msgs, err := MessageSplitter{PrefixLen: 30}.Split(PrivmsgCommand, "#test", text)
+	if err != nil || len(msgs) < 3 {
+		t.Fatalf("Split() -> %v, %v", msgs, err)
+	}
+	if second := msgs[1].Parameters()[1]; !strings.HasPrefix(second, "\x02\x0304,12colored") {
+		t.Errorf("formatting should be restored: %q", second)
+	}
+	if last := msgs[len(msgs)-1].Parameters()[1]; !strings.HasPrefix(last, "plain") {
+		t.Errorf("reset formatting should not be restored: %q", last)
+	}
+}
+
+func TestMessageSplitter_Lines(t *testing.T) {
+	msgs, err := MessageSplitter{}.Split(PrivmsgCommand, "#test", "one\r\ntwo\n\nthree")
+	if err != nil || len(msgs) != 3 || msgs[2].Parameters()[1] != "three" {
+		t.Errorf("Split() -> %v, %v", msgs, err)
+	}
+}
+
+func TestMessageSplitter_Multiline(t *testing.T) {
+	splitter := MessageSplitter{PrefixLen: 30, MultilineMaxBytes: 1000, MultilineMaxLines: 5}
+	long := strings.Repeat("word ", 120)
+	msgs, err := splitter.Split(PrivmsgCommand, "#test", "first\n"+long+"\n\nlast")
+	if err != nil {
+		t.Fatalf("Split() -> %v", err)
+	}
+	var raw []string
+	for _, msg := range msgs {
+		raw = append(raw, msg.String())
+	}
+	if len(msgs) != 7 || msgs[0].Command() != BatchCommand || msgs[6].Command() != BatchCommand {
+		t.Fatalf("expected a single batch: %q", raw)
+	}
+	ref := strings.TrimPrefix(msgs[0].Parameters()[0], "+")
+	if msgs[0].Parameters()[1] != "draft/multiline" || msgs[6].Parameters()[0] != "-"+ref {
+		t.Errorf("unexpected batch: %q", raw)
+	}
+	concat := 0
+	text := ""
+	for _, msg := range msgs[1:6] {
+		if v, _ := msg.Tags().Get(BatchMessageTag); v != ref {
+			t.Errorf("message is not part of the batch: %s", msg)
+		}
+		if _, ok := msg.Tags().Get(MultilineConcatMessageTag); ok {
+			concat++
+		} else if text != "" {
+			text += "\n"
+		}
+		text += msg.Parameters()[1]
+	}
+	if concat != 1 || text != "first\n"+long+"\n\nlast" {
+		t.Errorf("the text should be preserved: %q", raw)
+	}
+
+	splitter.MultilineMaxLines = 2
+	msgs, _ = splitter.Split(PrivmsgCommand, "#test", "1\n2\n3")
+	if len(msgs) != 7 || msgs[4].Command() != BatchCommand {
+		t.Errorf("max-lines should be honoured: %v", msgs)
+	}
+}
+
+func TestMessageSplitter_Errors(t *testing.T) {
+	testData := []struct {
+		command Command
+		target  string
+		text    string
+	}{
+		{JoinCommand, "#test", "text"},
+		{PrivmsgCommand, "", "text"},
+		{PrivmsgCommand, "#test", ""},
+		{PrivmsgCommand, "#test", "\n\n"},
+		{PrivmsgCommand, "#test", "nul\x00"},
+	}
+	for _, td := range testData {
+		if msgs, err := (MessageSplitter{}).Split(td.command, td.target, td.text); err == nil {
+			t.Errorf("Split(%s, %q, %q) -> %v; expected an error", td.command, td.target, td.text, msgs)
+		}
+	}
+}
+
+func TestParseMultilineLimits(t *testing.T) {
+	if maxBytes, maxLines := ParseMultilineLimits("max-bytes=4096,max-lines=24"); maxBytes != 4096 || maxLines != 24 {
+		t.Errorf("ParseMultilineLimits() -> %d, %d", maxBytes, maxLines)
+	}
+}
+
+func TestClientConnection_SendText(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	srv.expect("NICK johndoe")
+	srv.expect("USER")
+	srv.send(":irc.example.com 001 johndoe :Welcome to the network johndoe!john@example.com")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	prefixLen := len("johndoe!john@example.com")
+	if conn.(*clientConnection).prefixLen() != prefixLen {
+		t.Errorf("prefixLen() -> %d", conn.(*clientConnection).prefixLen())
+	}
+	if err := conn.SendText(PrivmsgCommand, "#test", strings.Repeat("x", 800)); err != nil {
+		t.Fatalf("SendText() -> %v", err)
+	}
+	first, second := srv.expect("PRIVMSG #test"), srv.expect("PRIVMSG #test")
+	if relayedLen(first, prefixLen) != MsgMaxLen || len(first.Parameters()[1])+len(second.Parameters()[1]) != 800 {
+		t.Errorf("unexpected split: %d + %d bytes", len(first.Parameters()[1]), len(second.Parameters()[1]))
+	}
+	conn.Close()
+	conn.Wait()
+}
@@ -0,0 +1,130 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+)
+
+// Well-known IRCv3 message tags.
+const (
+	// AccountMessageTag contains the account name of the user that sent the message.
+	// See the account-tag capability for further details.
+	AccountMessageTag = "account"
+
+	// BatchMessageTag contains the reference tag of the batch the message belongs to.
+	BatchMessageTag = "batch"
+
+	// LabelMessageTag is used to correlate a server response with the client request
+	// that caused it.
+	LabelMessageTag = "label"
+
+	// MultilineConcatMessageTag marks a line of a draft/multiline batch that has to be
+	// concatenated with the previous line (without a line break in between).
+	MultilineConcatMessageTag = "draft/multiline-concat"
+
+	// MsgIDMessageTag contains a unique identifier for the message.
+	MsgIDMessageTag = "msgid"
+
+	// TimeMessageTag contains the time the message was sent (ISO 8601 format, UTC).
+	// See the server-time capability for further details.
+	TimeMessageTag = "time"
+)
+
+// messageTagSeparator separates the tags of a message from each other. (Semicolon, thus ASCII: 0x3b)
+const messageTagSeparator = ";"
+
+// messageTagValueSeparator separates the key of a tag from its value. (Equals sign, thus ASCII: 0x3d)
+const messageTagValueSeparator = "="
+
+// messageTagValueEscaper escapes the characters that must not appear verbatim inside of a tag value.
+var messageTagValueEscaper = strings.NewReplacer("\\", "\\\\", ";", "\\:", " ", "\\s", "\r", "\\r", "\n", "\\n")
+
+// Tags holds the IRCv3 message tags of a message. Tags without a value are
+// stored with an empty string as value.
+type Tags map[string]string
+
+// NewTagsFromString parses the tags section of a raw message. The leading '@'
+// must already have been removed from the given string str.
+func NewTagsFromString(str string) Tags {
+	tags := make(Tags)
+	for _, tag := range strings.Split(str, messageTagSeparator) {
+		keyAndValue := strings.SplitN(tag, messageTagValueSeparator, 2)
+		if keyAndValue[0] == "" {
+			continue
+		}
+		value := ""
+		if len(keyAndValue) == 2 {
+			value = unescapeTagValue(keyAndValue[1])
+		}
+		// If a key appears more than once, the last occurrence wins.
+		tags[keyAndValue[0]] = value
+	}
+	return tags
+}
+
+// Get returns the value of the tag with the given key and whether the tag is present at all.
+func (tags Tags) Get(key string) (value string, ok bool) {
+	value, ok = tags[key]
+	return
+}
+
+// String converts the tags into their escaped wire representation (without the leading '@').
+// The tags are sorted by key to guarantee a stable output.
+func (tags Tags) String() string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(messageTagSeparator)
+		}
+		sb.WriteString(k)
+		if v := tags[k]; v != "" {
+			sb.WriteString(messageTagValueSeparator)
+			sb.WriteString(escapeTagValue(v))
+		}
+	}
+	return sb.String()
+}
+
+// escapeTagValue escapes a tag value according to the IRCv3 message-tags specification.
+func escapeTagValue(value string) string {
+	return messageTagValueEscaper.Replace(value)
+}
+
+// unescapeTagValue reverts the escaping of a tag value according to the IRCv3 message-tags
+// specification. Invalid escape sequences drop the backslash, a trailing lone backslash
+// is removed entirely.
+func unescapeTagValue(value string) string {
+	if !strings.ContainsRune(value, '\\') {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(value) {
+			break
+		}
+		switch value[i] {
+		case ':':
+			sb.WriteByte(';')
+		case 's':
+			sb.WriteByte(' ')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'n':
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(value[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTagsFromString(t *testing.T) {
+	var testdata = []struct {
+		str  string
+		tags Tags
+	}{
+		{"id=123AB;rose", Tags{"id": "123AB", "rose": ""}},
+		{"url=;netsplit=tur,ty", Tags{"url": "", "netsplit": "tur,ty"}},
+		{"a=b;a=c", Tags{"a": "c"}},
+		{"+example.com/foo=bar", Tags{"+example.com/foo": "bar"}},
+		{"a=\\:\\s\\\\\\r\\n", Tags{"a": "; \\\r\n"}},
+		{"a=b\\", Tags{"a": "b"}},
+		{"a=b\\x", Tags{"a": "bx"}},
+		{";;a=1;", Tags{"a": "1"}},
+	}
+	for _, tt := range testdata {
+		if tags := NewTagsFromString(tt.str); !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf(`NewTagsFromString("%s") -> %#v, expected: %#v`, tt.str, tags, tt.tags)
+		}
+	}
+}
+
+func TestTags_String(t *testing.T) {
+	var testdata = []struct {
+		tags Tags
+		str  string
+	}{
+		{Tags{}, ""},
+		{Tags{"rose": ""}, "rose"},
+		{Tags{"id": "123AB", "rose": ""}, "id=123AB;rose"},
+		{Tags{"a": "; \\\r\n"}, "a=\\:\\s\\\\\\r\\n"},
+	}
+	for _, tt := range testdata {
+		if str := tt.tags.String(); str != tt.str {
+			t.Errorf(`Tags.String() -> "%s", expected: "%s"`, str, tt.str)
+		}
+	}
+}
+
+func TestTags_Get(t *testing.T) {
+	tags := Tags{MsgIDMessageTag: "abc", "flag": ""}
+	if v, ok := tags.Get(MsgIDMessageTag); !ok || v != "abc" {
+		t.Errorf(`Tags.Get("%s") -> ("%s", %v), expected: ("abc", true)`, MsgIDMessageTag, v, ok)
+	}
+	if _, ok := tags.Get("flag"); !ok {
+		t.Error(`Tags.Get("flag") should report a tag without value as present`)
+	}
+	if _, ok := tags.Get(AccountMessageTag); ok {
+		t.Errorf(`Tags.Get("%s") should report a missing tag as absent`, AccountMessageTag)
+	}
+}
@@ -1,6 +1,7 @@
 package irc
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -121,6 +122,50 @@ func TestParseMessage(t *testing.T) {
 	}
 }
 
+func TestParseMessage_Tags(t *testing.T) {
+	raw := "@account=hax0r;msgid=63E1033A051D4B41B1AB1FA3CF4B243E;time=2019-02-28T19:30:01.727Z :nick!ident@host.com PRIVMSG me :Hello there"
+	msg, err := NewMessageFromString(raw)
+	if err != nil {
+		t.Fatalf("could not parse tagged message: %v", err)
+	}
+	expected := Tags{
+		AccountMessageTag: "hax0r",
+		MsgIDMessageTag:   "63E1033A051D4B41B1AB1FA3CF4B243E",
+		TimeMessageTag:    "2019-02-28T19:30:01.727Z",
+	}
+	if !reflect.DeepEqual(msg.Tags(), expected) {
+		t.Errorf("unexpected tags: %#v, expected: %#v", msg.Tags(), expected)
+	}
+	if msg.Prefix().Nickname() != "nick" || msg.Command() != "PRIVMSG" {
+		t.Errorf("unexpected prefix or command: %s %s", msg.Prefix(), msg.Command())
+	}
+	if str := msg.String(); str != raw {
+		t.Errorf("expected '%s', got '%s'", raw, str)
+	}
+}
+
+func TestMessage_String_WithoutPrefix(t *testing.T) {
+	msg := NewMessageWithTags(Tags{"+typing": "active"}, EmptyPrefix, "TAGMSG", "#channel")
+	if str := msg.String(); str != "@+typing=active TAGMSG #channel" {
+		t.Errorf("unexpected serialization: '%s'", str)
+	}
+}
+
+func TestMessage_IsValid_Tags(t *testing.T) {
+	long := make([]byte, MsgTagsMaxLen)
+	for i := range long {
+		long[i] = 'x'
+	}
+	m := NewMessageWithTags(Tags{"a": string(long[:MsgMaxLen*2])}, EmptyPrefix, PingCommand, "x")
+	if valid, errs := m.IsValid(); !valid {
+		t.Errorf("tags should not count against the message length limit: %v", errs)
+	}
+	m = NewMessageWithTags(Tags{"a": string(long)}, EmptyPrefix, PingCommand, "x")
+	if valid, _ := m.IsValid(); valid {
+		t.Error("message with oversized tags should not be valid")
+	}
+}
+
 func TestQuitMessage_Reason(t *testing.T) {
 	r1 := "Bye, folks!"
 	m := NewQuitMessage(EmptyPrefix, r1)
@@ -128,3 +173,158 @@ func TestQuitMessage_Reason(t *testing.T) {
 		t.Errorf(`QuitMessage.Reaon() -> "%s", expected: "%s"`, r2, r1)
 	}
 }
+
+// parserConformanceTests have been derived from the "msg-split" tests of the
+// irc-parser-tests suite (https://github.com/ircdocs/parser-tests).
+var parserConformanceTests = []struct {
+	raw    string
+	tags   Tags
+	source string
+	verb   Command
+	params []string
+}{
+	{"foo bar baz asdf", nil, "", "foo", []string{"bar", "baz", "asdf"}},
+	{":coolguy foo bar baz asdf", nil, "coolguy", "foo", []string{"bar", "baz", "asdf"}},
+	{"foo bar baz :asdf quux", nil, "", "foo", []string{"bar", "baz", "asdf quux"}},
+	{"foo bar baz :", nil, "", "foo", []string{"bar", "baz", ""}},
+	{"foo bar baz ::asdf", nil, "", "foo", []string{"bar", "baz", ":asdf"}},
+	{":coolguy foo bar baz :asdf quux", nil, "coolguy", "foo", []string{"bar", "baz", "asdf quux"}},
+	{":coolguy foo bar baz :  asdf quux ", nil, "coolguy", "foo", []string{"bar", "baz", "  asdf quux "}},
+	{":coolguy PRIVMSG bar :lol :) ", nil, "coolguy", "PRIVMSG", []string{"bar", "lol :) "}},
+	{":coolguy foo bar baz :", nil, "coolguy", "foo", []string{"bar", "baz", ""}},
+	{":coolguy foo bar baz :  ", nil, "coolguy", "foo", []string{"bar", "baz", "  "}},
+	{"@a=b;c=32;k;rt=ql7 foo", Tags{"a": "b", "c": "32", "k": "", "rt": "ql7"}, "", "foo", nil},
+	{"@a=b\\\\and\\nk;c=72\\s45;d=gh\\:764 foo", Tags{"a": "b\\and\nk", "c": "72 45", "d": "gh;764"}, "", "foo", nil},
+	{"@c;h=;a=b :quux ab cd", Tags{"c": "", "h": "", "a": "b"}, "quux", "ab", []string{"cd"}},
+	{":src JOIN #chan", nil, "src", "JOIN", []string{"#chan"}},
+	{":src JOIN :#chan", nil, "src", "JOIN", []string{"#chan"}},
+	{":src AWAY", nil, "src", "AWAY", nil},
+	{":src AWAY ", nil, "src", "AWAY", nil},
+	{":cool\tguy foo bar baz", nil, "cool\tguy", "foo", []string{"bar", "baz"}},
+	{":coolguy!ag@net\x035w\x03ork.admin PRIVMSG foo :bar baz", nil, "coolguy!ag@net\x035w\x03ork.admin", "PRIVMSG", []string{"foo", "bar baz"}},
+	{":coolguy!~ag@n\x02et\x0305w\x0fork.admin PRIVMSG foo :bar baz", nil, "coolguy!~ag@n\x02et\x0305w\x0fork.admin", "PRIVMSG", []string{"foo", "bar baz"}},
+	{"@tag1=value1;tag2;vendor1/tag3=value2;vendor2/tag4= :irc.example.com COMMAND param1 param2 :param3 param3",
+		Tags{"tag1": "value1", "tag2": "", "vendor1/tag3": "value2", "vendor2/tag4": ""}, "irc.example.com", "COMMAND", []string{"param1", "param2", "param3 param3"}},
+	{":irc.example.com COMMAND param1 param2 :param3 param3", nil, "irc.example.com", "COMMAND", []string{"param1", "param2", "param3 param3"}},
+	{"@tag1=value1;tag2;vendor1/tag3=value2;vendor2/tag4 COMMAND param1 param2 :param3 param3",
+		Tags{"tag1": "value1", "tag2": "", "vendor1/tag3": "value2", "vendor2/tag4": ""}, "", "COMMAND", []string{"param1", "param2", "param3 param3"}},
+	{"COMMAND", nil, "", "COMMAND", nil},
+	{"@foo=\\\\\\\\\\:\\\\s\\s\\r\\n COMMAND", Tags{"foo": "\\\\;\\s \r\n"}, "", "COMMAND", nil},
+	{":gravel.mozilla.org 432  #momo :Erroneous Nickname: Illegal characters", nil, "gravel.mozilla.org", "432", []string{"#momo", "Erroneous Nickname: Illegal characters"}},
+	{":gravel.mozilla.org MODE #tckk +n ", nil, "gravel.mozilla.org", "MODE", []string{"#tckk", "+n"}},
+	{":services.esper.net MODE #foo-bar +o foobar  ", nil, "services.esper.net", "MODE", []string{"#foo-bar", "+o", "foobar"}},
+	{"@tag1=value\\\\ntest COMMAND", Tags{"tag1": "value\\ntest"}, "", "COMMAND", nil},
+	{"@tag1=value\\1 COMMAND", Tags{"tag1": "value1"}, "", "COMMAND", nil},
+	{"@tag1=value1\\ COMMAND", Tags{"tag1": "value1"}, "", "COMMAND", nil},
+	{"@tag1=1;tag2=3;tag3=4;tag1=5 COMMAND", Tags{"tag1": "5", "tag2": "3", "tag3": "4"}, "", "COMMAND", nil},
+	{"@tag1=1;tag2=3;tag3=4;tag1=5;vendor/tag2=8 COMMAND", Tags{"tag1": "5", "tag2": "3", "tag3": "4", "vendor/tag2": "8"}, "", "COMMAND", nil},
+	{":SomeOp MODE #channel :+i", nil, "SomeOp", "MODE", []string{"#channel", "+i"}},
+	{":SomeOp MODE #channel +oo SomeUser :AnotherUser", nil, "SomeOp", "MODE", []string{"#channel", "+oo", "SomeUser", "AnotherUser"}},
+}
+
+func TestParseMessage_Conformance(t *testing.T) {
+	for _, tc := range parserConformanceTests {
+		msg, err := NewMessageFromString(tc.raw)
+		if err != nil {
+			t.Errorf("could not parse %q: %v", tc.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg.Tags(), tc.tags) {
+			t.Errorf("%q: tags -> %#v, expected: %#v", tc.raw, msg.Tags(), tc.tags)
+		}
+		if src := msg.Prefix().String(); src != tc.source {
+			t.Errorf("%q: source -> %q, expected: %q", tc.raw, src, tc.source)
+		}
+		if msg.Command() != tc.verb {
+			t.Errorf("%q: command -> %q, expected: %q", tc.raw, msg.Command(), tc.verb)
+		}
+		if !reflect.DeepEqual(msg.Parameters(), tc.params) {
+			t.Errorf("%q: parameters -> %#v, expected: %#v", tc.raw, msg.Parameters(), tc.params)
+		}
+	}
+}
+
+func TestParseMessage_Errors(t *testing.T) {
+	var testData = []struct {
+		raw    string
+		reason ParseErrorReason
+	}{
+		{"", ParseErrorEmptyMessage},
+		{"\r\n", ParseErrorEmptyMessage},
+		{"   ", ParseErrorEmptyMessage},
+		{":server", ParseErrorMissingCommand},
+		{":server   ", ParseErrorMissingCommand},
+		{"@a=b", ParseErrorMissingCommand},
+		{"@a=b :server", ParseErrorMissingCommand},
+		{"@ PING", ParseErrorInvalidTags},
+		{": PING", ParseErrorInvalidPrefix},
+		{":@host PING", ParseErrorInvalidPrefix},
+		{":nick!@host PING", ParseErrorInvalidPrefix},
+		{":nick@ PING", ParseErrorInvalidPrefix},
+		{"PI-NG", ParseErrorInvalidCommand},
+		{"1234 x", ParseErrorInvalidCommand},
+		{"PRIVMSG #chan :a\x00b", ParseErrorInvalidCharacter},
+		{"PRIVMSG #chan :a\rb", ParseErrorInvalidCharacter},
+		{"PRIVMSG #chan :a\nb\r\n", ParseErrorInvalidCharacter},
+		{"CMD 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 :16", ParseErrorTooManyParameters},
+	}
+	for _, td := range testData {
+		msg, err := NewMessageFromString(td.raw)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("NewMessageFromString(%q) -> %v, %v; expected a parse error", td.raw, msg, err)
+			continue
+		}
+		if pe.Reason != td.reason {
+			t.Errorf("NewMessageFromString(%q) -> %v, expected: %v", td.raw, pe.Reason, td.reason)
+		}
+	}
+}
+
+func TestParseMessage_WithoutParameters(t *testing.T) {
+	msg, err := NewMessageFromString("PING\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Prefix() != EmptyPrefix || msg.Command() != PingCommand || len(msg.Parameters()) != 0 {
+		t.Errorf("unexpected message: %#v", msg)
+	}
+}
+
+func TestMessage_IsValid_Parameters(t *testing.T) {
+	for _, params := range [][]string{{"a b", "c"}, {"", "c"}, {":a", "c"}, {"a\r\nQUIT"}} {
+		if valid, _ := NewMessageWithoutPrefix(PrivmsgCommand, params...).IsValid(); valid {
+			t.Errorf("message with parameters %q should not be valid", params)
+		}
+	}
+}
+
+// FuzzNewMessageFromString checks that parsing never panics and that every message that
+// can be parsed survives the round-trip through String() unchanged. The seed corpus in
+// testdata/fuzz contains the parserConformanceTests.
+func FuzzNewMessageFromString(f *testing.F) {
+	for raw := range exampleServerReplies {
+		f.Add(raw)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		msg, err := NewMessageFromString(raw)
+		if err != nil {
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("NewMessageFromString(%q) returned an untyped error: %v", raw, err)
+			}
+			return
+		}
+		str := msg.String()
+		reparsed, err := NewMessageFromString(str)
+		if err != nil {
+			t.Fatalf("could not parse serialized message %q (from %q): %v", str, raw, err)
+		}
+		if !reflect.DeepEqual(msg, reparsed) {
+			t.Fatalf("round-trip of %q changed the message: %#v -> %#v", raw, msg, reparsed)
+		}
+		if str2 := reparsed.String(); str2 != str {
+			t.Fatalf("serialization is not stable: %q -> %q", str, str2)
+		}
+	})
+}
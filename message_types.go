@@ -0,0 +1,1214 @@
+package irc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// listSeparator separates the elements of list parameters (e.g. the channels of a
+// JOIN message). (Comma, thus ASCII: 0x2c)
+const listSeparator = ","
+
+// MaxUserhostNicknames is the maximum number of nicknames that can be queried using a
+// single USERHOST message.
+const MaxUserhostNicknames = 5
+
+// NewTypedMessage upgrades a generic message (as returned by NewMessageFromString) into
+// the typed message matching its command (e.g. PrivmsgMessage for PRIVMSG), so that it
+// can be processed using a type switch. The message is returned as it is, if there is
+// no typed representation for its command or if it lacks any mandatory parameters.
+func NewTypedMessage(msg Message) Message {
+	if msg == nil {
+		return nil
+	}
+	m, ok := msg.(*message)
+	if !ok {
+		if isTypedMessage(msg) {
+			return msg
+		}
+		m = &message{
+			prefix:     msg.Prefix(),
+			command:    msg.Command(),
+			parameters: msg.Parameters(),
+			tags:       msg.Tags(),
+		}
+	}
+	pc := len(m.parameters)
+	switch m.command {
+	case PassCommand:
+		if pc >= 1 {
+			return &passMessage{*m}
+		}
+	case NickCommand:
+		if pc >= 1 {
+			return &nickMessage{*m}
+		}
+	case UserCommand:
+		if pc >= 4 {
+			return &userMessage{*m}
+		}
+	case OperCommand:
+		if pc >= 2 {
+			return &operMessage{*m}
+		}
+	case QuitCommand:
+		return &quitMessage{*m}
+	case JoinCommand:
+		if pc >= 1 {
+			return &joinMessage{*m}
+		}
+	case PartCommand:
+		if pc >= 1 {
+			return &partMessage{*m}
+		}
+	case TopicCommand:
+		if pc >= 1 {
+			return &topicMessage{*m}
+		}
+	case NamesCommand:
+		return &namesMessage{*m}
+	case ListCommand:
+		return &listMessage{*m}
+	case InviteCommand:
+		if pc >= 2 {
+			return &inviteMessage{*m}
+		}
+	case KickCommand:
+		if pc >= 2 {
+			return &kickMessage{*m}
+		}
+	case ModeCommand:
+		if pc >= 1 {
+			return &modeMessage{*m}
+		}
+	case PrivmsgCommand:
+		if pc >= 2 {
+			return &privmsgMessage{*m}
+		}
+	case NoticeCommand:
+		if pc >= 2 {
+			return &noticeMessage{*m}
+		}
+	case WhoCommand:
+		return &whoMessage{*m}
+	case WhoisCommand:
+		if pc >= 1 {
+			return &whoisMessage{*m}
+		}
+	case WhowasCommand:
+		if pc >= 1 {
+			return &whowasMessage{*m}
+		}
+	case AwayCommand:
+		return &awayMessage{*m}
+	case IsOnCommand:
+		if pc >= 1 {
+			return &isOnMessage{*m}
+		}
+	case UserHostCommand:
+		if pc >= 1 {
+			return &userHostMessage{*m}
+		}
+	case PingCommand:
+		if pc >= 1 {
+			return &pingMessage{*m}
+		}
+	case PongCommand:
+		if pc >= 1 {
+			return &pongMessage{*m}
+		}
+	case ErrorCommand:
+		return &errorMessage{*m}
+	}
+	return msg
+}
+
+// isTypedMessage checks if the given message already is one of the typed messages.
+// Each of the typed message interfaces contains an unexported marker method. Otherwise,
+// messages that share the same accessors (e.g. PRIVMSG and NOTICE) could not be told
+// apart using a type switch.
+func isTypedMessage(msg Message) bool {
+	switch msg.(type) {
+	case PassMessage, NickMessage, UserMessage, OperMessage, QuitMessage, JoinMessage,
+		PartMessage, TopicMessage, NamesMessage, ListMessage, InviteMessage, KickMessage,
+		ModeMessage, PrivmsgMessage, NoticeMessage, WhoMessage, WhoisMessage, WhowasMessage,
+		AwayMessage, IsOnMessage, UserHostMessage, PingMessage, PongMessage, ErrorMessage, BatchMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateNickname returns an error, if the given nickname is invalid.
+func validateNickname(nickname string) error {
+	if !isValidNickname(nickname) {
+		return fmt.Errorf("invalid nickname: \"%s\"", nickname)
+	}
+	return nil
+}
+
+// validateUsername returns an error, if the given username is invalid. According to
+// RfC-2812, usernames must not be empty and must not contain NUL, CR, LF, spaces or "@".
+func validateUsername(username string) error {
+	if username == "" || strings.ContainsAny(username, " @\x00\r\n") {
+		return fmt.Errorf("invalid username: \"%s\"", username)
+	}
+	return nil
+}
+
+// validateChannelName returns an error, if the given channel name is invalid.
+func validateChannelName(channel string) error {
+	if !isValidChannelName(channel) {
+		return fmt.Errorf("invalid channel name: \"%s\"", channel)
+	}
+	return nil
+}
+
+// validateTarget returns an error, if the given message target is invalid. A target
+// is either a channel, a nickname or a mask (e.g. "nick!user@host" or "$*.example.com").
+// Multiple targets can be separated by commas. Which targets are channels and how long
+// their names may be depends on the server (see ISupport), so only the characters that
+// no target may contain are rejected.
+func validateTarget(target string) error {
+	for _, t := range strings.Split(target, listSeparator) {
+		if !isValidTarget(t) {
+			return fmt.Errorf("invalid target: \"%s\"", target)
+		}
+	}
+	return nil
+}
+
+// isValidTarget checks if the given string can be used as a single message target.
+func isValidTarget(target string) bool {
+	return target != "" && !strings.HasPrefix(target, messagePrefixPresenceIndicator) && !strings.ContainsAny(target, " ,\x00\x07\r\n")
+}
+
+// validateParameter returns an error, if the given parameter would break the message
+// apart (i.e. if it contains NUL, CR or LF characters).
+func validateParameter(name string, value string) error {
+	if strings.ContainsAny(value, "\x00\r\n") {
+		return fmt.Errorf("invalid %s: must not contain NUL, CR or LF characters", name)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list parameter into its elements.
+func splitList(str string) []string {
+	if str == "" {
+		return nil
+	}
+	return strings.Split(str, listSeparator)
+}
+
+type PassMessage interface {
+	Message
+	isPass()
+	Password() string
+}
+
+type passMessage struct {
+	message
+}
+
+func (msg *passMessage) isPass() {}
+
+func (msg *passMessage) Password() string {
+	return msg.param(0)
+}
+
+func NewPassMessage(prefix Prefix, password string) (msg PassMessage) {
+	return &passMessage{
+		message{
+			prefix:     prefix,
+			command:    PassCommand,
+			parameters: []string{password},
+		},
+	}
+}
+
+type NickMessage interface {
+	Message
+	isNick()
+	Nickname() string
+}
+
+type nickMessage struct {
+	message
+}
+
+func (msg *nickMessage) isNick() {}
+
+func (msg *nickMessage) Nickname() string {
+	return msg.param(0)
+}
+
+// NewNickMessage creates a message to change the nickname.
+func NewNickMessage(prefix Prefix, nickname string) (NickMessage, error) {
+	if err := validateNickname(nickname); err != nil {
+		return nil, err
+	}
+	return &nickMessage{
+		message{
+			prefix:     prefix,
+			command:    NickCommand,
+			parameters: []string{nickname},
+		},
+	}, nil
+}
+
+type UserMessage interface {
+	Message
+	isUser()
+	Username() string
+	Realname() string
+	Mode() UserModes
+}
+
+type userMessage struct {
+	message
+}
+
+func (msg *userMessage) isUser() {}
+
+func (msg *userMessage) Username() string {
+	return msg.param(0)
+}
+
+// Mode returns the user modes that have been requested using the bitmask.
+// Modes that are not represented as a bitmask (RfC-1459 style) are ignored.
+func (msg *userMessage) Mode() UserModes {
+	mode, err := strconv.Atoi(msg.param(1))
+	if err != nil {
+		return nil
+	}
+	return UserModesFromBitmask(mode)
+}
+
+func (msg *userMessage) Realname() string {
+	return msg.param(3)
+}
+
+func NewUserMessage(prefix Prefix, username string, realname string, mode ...UserMode) (UserMessage, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := validateParameter("realname", realname); err != nil {
+		return nil, err
+	}
+	return &userMessage{
+		message{
+			prefix:     prefix,
+			command:    UserCommand,
+			parameters: []string{username, strconv.Itoa(UserModes(mode).Bitmask()), "*", realname},
+		},
+	}, nil
+}
+
+type OperMessage interface {
+	Message
+	isOper()
+	Name() string
+	Password() string
+}
+
+type operMessage struct {
+	message
+}
+
+func (msg *operMessage) isOper() {}
+
+func (msg *operMessage) Name() string {
+	return msg.param(0)
+}
+
+func (msg *operMessage) Password() string {
+	return msg.param(1)
+}
+
+// NewOperMessage creates a message to obtain operator privileges.
+func NewOperMessage(prefix Prefix, name string, password string) (OperMessage, error) {
+	if name == "" || strings.ContainsAny(name, " \x00\r\n") {
+		return nil, fmt.Errorf("invalid operator name: \"%s\"", name)
+	}
+	if err := validateParameter("password", password); err != nil {
+		return nil, err
+	}
+	return &operMessage{
+		message{
+			prefix:     prefix,
+			command:    OperCommand,
+			parameters: []string{name, password},
+		},
+	}, nil
+}
+
+type QuitMessage interface {
+	Message
+	isQuit()
+	Reason() string
+}
+
+type quitMessage struct {
+	message
+}
+
+func (msg *quitMessage) isQuit() {}
+
+func (msg *quitMessage) Reason() string {
+	return msg.param(0)
+}
+
+func NewQuitMessage(prefix Prefix, reason string) (msg QuitMessage) {
+	return &quitMessage{
+		message{
+			prefix:     prefix,
+			command:    QuitCommand,
+			parameters: []string{reason},
+		},
+	}
+}
+
+type JoinMessage interface {
+	Message
+	isJoin()
+	Channels() []string
+	Keys() []string
+}
+
+type joinMessage struct {
+	message
+}
+
+func (msg *joinMessage) isJoin() {}
+
+func (msg *joinMessage) Channels() []string {
+	return splitList(msg.param(0))
+}
+
+// Keys returns the channel keys in the order of the channels they belong to.
+func (msg *joinMessage) Keys() []string {
+	return splitList(msg.param(1))
+}
+
+// NewJoinMessage creates a message to join the given channels.
+func NewJoinMessage(prefix Prefix, channels ...string) (JoinMessage, error) {
+	return NewJoinMessageWithKeys(prefix, channels, nil)
+}
+
+// NewJoinMessageWithKeys creates a message to join the given channels, of which some
+// are protected with keys. The keys are matched to the channels by their positions.
+func NewJoinMessageWithKeys(prefix Prefix, channels []string, keys []string) (JoinMessage, error) {
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channel given")
+	}
+	if len(keys) > len(channels) {
+		return nil, fmt.Errorf("got %d keys for %d channels", len(keys), len(channels))
+	}
+	for _, ch := range channels {
+		if err := validateChannelName(ch); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, " ,\x00\r\n") {
+			return nil, fmt.Errorf("invalid channel key: \"%s\"", k)
+		}
+	}
+	params := []string{strings.Join(channels, listSeparator)}
+	if len(keys) > 0 {
+		params = append(params, strings.Join(keys, listSeparator))
+	}
+	return &joinMessage{
+		message{
+			prefix:     prefix,
+			command:    JoinCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type PartMessage interface {
+	Message
+	isPart()
+	Channels() []string
+	Reason() string
+}
+
+type partMessage struct {
+	message
+}
+
+func (msg *partMessage) isPart() {}
+
+func (msg *partMessage) Channels() []string {
+	return splitList(msg.param(0))
+}
+
+func (msg *partMessage) Reason() string {
+	return msg.param(1)
+}
+
+// NewPartMessage creates a message to leave the given channel. The reason is optional.
+func NewPartMessage(prefix Prefix, channel string, reason string) (PartMessage, error) {
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	if err := validateParameter("reason", reason); err != nil {
+		return nil, err
+	}
+	params := []string{channel}
+	if reason != "" {
+		params = append(params, reason)
+	}
+	return &partMessage{
+		message{
+			prefix:     prefix,
+			command:    PartCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type TopicMessage interface {
+	Message
+	isTopic()
+	Channel() string
+	Topic() string
+	// HasTopic tells if the message carries a topic at all. A TOPIC message without
+	// topic is used to query the topic of a channel, whereas an empty topic clears it.
+	HasTopic() bool
+}
+
+type topicMessage struct {
+	message
+}
+
+func (msg *topicMessage) isTopic() {}
+
+func (msg *topicMessage) Channel() string {
+	return msg.param(0)
+}
+
+func (msg *topicMessage) Topic() string {
+	return msg.param(1)
+}
+
+func (msg *topicMessage) HasTopic() bool {
+	return len(msg.parameters) > 1
+}
+
+// NewTopicMessage creates a message to change the topic of the given channel.
+// An empty topic removes the channel's topic.
+func NewTopicMessage(prefix Prefix, channel string, topic string) (TopicMessage, error) {
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	if err := validateParameter("topic", topic); err != nil {
+		return nil, err
+	}
+	return &topicMessage{
+		message{
+			prefix:     prefix,
+			command:    TopicCommand,
+			parameters: []string{channel, topic},
+		},
+	}, nil
+}
+
+// NewTopicQueryMessage creates a message to query the topic of the given channel.
+func NewTopicQueryMessage(prefix Prefix, channel string) (TopicMessage, error) {
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	return &topicMessage{
+		message{
+			prefix:     prefix,
+			command:    TopicCommand,
+			parameters: []string{channel},
+		},
+	}, nil
+}
+
+type NamesMessage interface {
+	Message
+	isNames()
+	Channels() []string
+}
+
+type namesMessage struct {
+	message
+}
+
+func (msg *namesMessage) isNames() {}
+
+func (msg *namesMessage) Channels() []string {
+	return splitList(msg.param(0))
+}
+
+// NewNamesMessage creates a message to list the nicknames that are visible on the given
+// channels. If no channel is given, all visible channels and users will be listed.
+func NewNamesMessage(prefix Prefix, channels ...string) (NamesMessage, error) {
+	params, err := channelListParameters(channels)
+	if err != nil {
+		return nil, err
+	}
+	return &namesMessage{
+		message{
+			prefix:     prefix,
+			command:    NamesCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type ListMessage interface {
+	Message
+	isList()
+	Channels() []string
+}
+
+type listMessage struct {
+	message
+}
+
+func (msg *listMessage) isList() {}
+
+func (msg *listMessage) Channels() []string {
+	return splitList(msg.param(0))
+}
+
+// NewListMessage creates a message to list the given channels along with their topics.
+// If no channel is given, all channels will be listed.
+func NewListMessage(prefix Prefix, channels ...string) (ListMessage, error) {
+	params, err := channelListParameters(channels)
+	if err != nil {
+		return nil, err
+	}
+	return &listMessage{
+		message{
+			prefix:     prefix,
+			command:    ListCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+// channelListParameters validates the given channels and joins them into a single
+// (optional) list parameter.
+func channelListParameters(channels []string) ([]string, error) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+	for _, ch := range channels {
+		if err := validateChannelName(ch); err != nil {
+			return nil, err
+		}
+	}
+	return []string{strings.Join(channels, listSeparator)}, nil
+}
+
+type InviteMessage interface {
+	Message
+	isInvite()
+	Nickname() string
+	Channel() string
+}
+
+type inviteMessage struct {
+	message
+}
+
+func (msg *inviteMessage) isInvite() {}
+
+func (msg *inviteMessage) Nickname() string {
+	return msg.param(0)
+}
+
+func (msg *inviteMessage) Channel() string {
+	return msg.param(1)
+}
+
+// NewInviteMessage creates a message to invite a user to the given channel.
+func NewInviteMessage(prefix Prefix, nickname string, channel string) (InviteMessage, error) {
+	if err := validateNickname(nickname); err != nil {
+		return nil, err
+	}
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	return &inviteMessage{
+		message{
+			prefix:     prefix,
+			command:    InviteCommand,
+			parameters: []string{nickname, channel},
+		},
+	}, nil
+}
+
+type KickMessage interface {
+	Message
+	isKick()
+	Channel() string
+	Nickname() string
+	Comment() string
+}
+
+type kickMessage struct {
+	message
+}
+
+func (msg *kickMessage) isKick() {}
+
+func (msg *kickMessage) Channel() string {
+	return msg.param(0)
+}
+
+func (msg *kickMessage) Nickname() string {
+	return msg.param(1)
+}
+
+func (msg *kickMessage) Comment() string {
+	return msg.param(2)
+}
+
+// NewKickMessage creates a message to remove a user from the given channel.
+// The comment is optional.
+func NewKickMessage(prefix Prefix, channel string, nickname string, comment string) (KickMessage, error) {
+	if err := validateChannelName(channel); err != nil {
+		return nil, err
+	}
+	if err := validateNickname(nickname); err != nil {
+		return nil, err
+	}
+	if err := validateParameter("comment", comment); err != nil {
+		return nil, err
+	}
+	params := []string{channel, nickname}
+	if comment != "" {
+		params = append(params, comment)
+	}
+	return &kickMessage{
+		message{
+			prefix:     prefix,
+			command:    KickCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type ModeMessage interface {
+	Message
+	isMode()
+	// Target is either a channel or a nickname.
+	Target() string
+	// ModeString returns the mode changes (e.g. "+o-v"). It is empty, if the message
+	// is used to query the modes of the target.
+	ModeString() string
+	// ModeArgs returns the arguments of the mode changes.
+	ModeArgs() []string
+}
+
+type modeMessage struct {
+	message
+}
+
+func (msg *modeMessage) isMode() {}
+
+func (msg *modeMessage) Target() string {
+	return msg.param(0)
+}
+
+func (msg *modeMessage) ModeString() string {
+	return msg.param(1)
+}
+
+func (msg *modeMessage) ModeArgs() []string {
+	if len(msg.parameters) <= 2 {
+		return nil
+	}
+	return msg.parameters[2:]
+}
+
+// NewModeMessage creates a message to change the modes of a channel or a user. If no
+// modes are given, the message will query the current modes of the target instead.
+// The target is not checked against the channel types and the channel name length,
+// since they depend on the server (see validateTarget()).
+func NewModeMessage(prefix Prefix, target string, modes string, args ...string) (ModeMessage, error) {
+	if !isValidTarget(target) {
+		return nil, fmt.Errorf("invalid mode target: \"%s\"", target)
+	}
+	if modes == "" && len(args) > 0 {
+		return nil, fmt.Errorf("mode arguments given without any modes")
+	}
+	if strings.ContainsAny(modes, " \x00\r\n") {
+		return nil, fmt.Errorf("invalid modes: \"%s\"", modes)
+	}
+	params := []string{target}
+	if modes != "" {
+		params = append(params, modes)
+	}
+	for _, a := range args {
+		if a == "" || strings.HasPrefix(a, messagePrefixPresenceIndicator) || strings.ContainsAny(a, " \x00\r\n") {
+			return nil, fmt.Errorf("invalid mode argument: \"%s\"", a)
+		}
+		params = append(params, a)
+	}
+	return &modeMessage{
+		message{
+			prefix:     prefix,
+			command:    ModeCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type PrivmsgMessage interface {
+	Message
+	isPrivmsg()
+	Target() string
+	Text() string
+}
+
+type privmsgMessage struct {
+	message
+}
+
+func (msg *privmsgMessage) isPrivmsg() {}
+
+func (msg *privmsgMessage) Target() string {
+	return msg.param(0)
+}
+
+func (msg *privmsgMessage) Text() string {
+	return msg.param(1)
+}
+
+// NewPrivmsgMessage creates a message to send the given text to a user or a channel.
+func NewPrivmsgMessage(prefix Prefix, target string, text string) (PrivmsgMessage, error) {
+	params, err := textMessageParameters(target, text)
+	if err != nil {
+		return nil, err
+	}
+	return &privmsgMessage{
+		message{
+			prefix:     prefix,
+			command:    PrivmsgCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type NoticeMessage interface {
+	Message
+	isNotice()
+	Target() string
+	Text() string
+}
+
+type noticeMessage struct {
+	message
+}
+
+func (msg *noticeMessage) isNotice() {}
+
+func (msg *noticeMessage) Target() string {
+	return msg.param(0)
+}
+
+func (msg *noticeMessage) Text() string {
+	return msg.param(1)
+}
+
+// NewNoticeMessage creates a notice to send the given text to a user or a channel.
+// Contrary to PRIVMSG, automatic replies must never be sent in response to a NOTICE.
+func NewNoticeMessage(prefix Prefix, target string, text string) (NoticeMessage, error) {
+	params, err := textMessageParameters(target, text)
+	if err != nil {
+		return nil, err
+	}
+	return &noticeMessage{
+		message{
+			prefix:     prefix,
+			command:    NoticeCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+// textMessageParameters validates the target and the text of PRIVMSG and NOTICE messages.
+func textMessageParameters(target string, text string) ([]string, error) {
+	if err := validateTarget(target); err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("no text to send")
+	}
+	if err := validateParameter("text", text); err != nil {
+		return nil, err
+	}
+	return []string{target, text}, nil
+}
+
+type WhoMessage interface {
+	Message
+	isWho()
+	Mask() string
+	OperatorsOnly() bool
+}
+
+type whoMessage struct {
+	message
+}
+
+func (msg *whoMessage) isWho() {}
+
+func (msg *whoMessage) Mask() string {
+	return msg.param(0)
+}
+
+func (msg *whoMessage) OperatorsOnly() bool {
+	return msg.param(1) == "o"
+}
+
+// NewWhoMessage creates a query for the users matching the given mask (e.g. a channel).
+// If operatorsOnly is set, only operators will be returned.
+func NewWhoMessage(prefix Prefix, mask string, operatorsOnly bool) (WhoMessage, error) {
+	if strings.ContainsAny(mask, " \x00\r\n") || strings.HasPrefix(mask, messagePrefixPresenceIndicator) {
+		return nil, fmt.Errorf("invalid mask: \"%s\"", mask)
+	}
+	if mask == "" && operatorsOnly {
+		mask = "0"
+	}
+	var params []string
+	if mask != "" {
+		params = append(params, mask)
+	}
+	if operatorsOnly {
+		params = append(params, "o")
+	}
+	return &whoMessage{
+		message{
+			prefix:     prefix,
+			command:    WhoCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+// NewWhoxMessage creates an extended query (WHOX) for the users matching the given mask.
+// The server is asked to reply with the given fields (e.g. "cuhnfar") only; the replies
+// (see WhoSpcrplReply) carry the given token, which must consist of up to three digits.
+func NewWhoxMessage(prefix Prefix, mask string, fields string, token string) (WhoMessage, error) {
+	if mask == "" || strings.ContainsAny(mask, " \x00\r\n") || strings.HasPrefix(mask, messagePrefixPresenceIndicator) {
+		return nil, fmt.Errorf("invalid mask: \"%s\"", mask)
+	}
+	if fields == "" || strings.ContainsAny(fields, " ,%\x00\r\n") {
+		return nil, fmt.Errorf("invalid WHOX fields: \"%s\"", fields)
+	}
+	if len(token) > 3 || strings.Trim(token, "0123456789") != "" {
+		return nil, fmt.Errorf("invalid WHOX token: \"%s\"", token)
+	}
+	if token != "" {
+		if !strings.ContainsRune(fields, 't') {
+			fields = "t" + fields
+		}
+		fields += "," + token
+	}
+	return &whoMessage{
+		message{
+			prefix:     prefix,
+			command:    WhoCommand,
+			parameters: []string{mask, "%" + fields},
+		},
+	}, nil
+}
+
+type WhoisMessage interface {
+	Message
+	isWhois()
+	// Server returns the server that the query has been addressed to (if any).
+	Server() string
+	Masks() []string
+}
+
+type whoisMessage struct {
+	message
+}
+
+func (msg *whoisMessage) isWhois() {}
+
+func (msg *whoisMessage) Server() string {
+	if len(msg.parameters) < 2 {
+		return ""
+	}
+	return msg.param(0)
+}
+
+func (msg *whoisMessage) Masks() []string {
+	return splitList(msg.parameters[len(msg.parameters)-1])
+}
+
+// NewWhoisMessage creates a query for information about the given user.
+func NewWhoisMessage(prefix Prefix, nickname string) (WhoisMessage, error) {
+	if err := validateNickname(nickname); err != nil {
+		return nil, err
+	}
+	return &whoisMessage{
+		message{
+			prefix:     prefix,
+			command:    WhoisCommand,
+			parameters: []string{nickname},
+		},
+	}, nil
+}
+
+type WhowasMessage interface {
+	Message
+	isWhowas()
+	Nickname() string
+	// Count returns the maximum number of history entries to be returned (zero if unlimited).
+	Count() int
+}
+
+type whowasMessage struct {
+	message
+}
+
+func (msg *whowasMessage) isWhowas() {}
+
+func (msg *whowasMessage) Nickname() string {
+	return msg.param(0)
+}
+
+func (msg *whowasMessage) Count() int {
+	count, err := strconv.Atoi(msg.param(1))
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// NewWhowasMessage creates a query for information about a nickname which no longer
+// exists. If count is greater than zero, at most count history entries will be returned.
+func NewWhowasMessage(prefix Prefix, nickname string, count int) (WhowasMessage, error) {
+	if err := validateNickname(nickname); err != nil {
+		return nil, err
+	}
+	params := []string{nickname}
+	if count > 0 {
+		params = append(params, strconv.Itoa(count))
+	}
+	return &whowasMessage{
+		message{
+			prefix:     prefix,
+			command:    WhowasCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type AwayMessage interface {
+	Message
+	isAway()
+	Text() string
+	// IsAway tells if the user is marked as being away (true) or as being back (false).
+	IsAway() bool
+}
+
+type awayMessage struct {
+	message
+}
+
+func (msg *awayMessage) isAway() {}
+
+func (msg *awayMessage) Text() string {
+	return msg.param(0)
+}
+
+func (msg *awayMessage) IsAway() bool {
+	return msg.param(0) != ""
+}
+
+// NewAwayMessage creates a message to mark the user as being away using the given text.
+// An empty text marks the user as no longer being away.
+func NewAwayMessage(prefix Prefix, text string) (AwayMessage, error) {
+	if err := validateParameter("text", text); err != nil {
+		return nil, err
+	}
+	var params []string
+	if text != "" {
+		params = append(params, text)
+	}
+	return &awayMessage{
+		message{
+			prefix:     prefix,
+			command:    AwayCommand,
+			parameters: params,
+		},
+	}, nil
+}
+
+type IsOnMessage interface {
+	Message
+	isIsOn()
+	Nicknames() []string
+}
+
+type isOnMessage struct {
+	message
+}
+
+func (msg *isOnMessage) isIsOn() {}
+
+func (msg *isOnMessage) Nicknames() []string {
+	var nicks []string
+	for _, p := range msg.parameters {
+		nicks = append(nicks, strings.Fields(p)...)
+	}
+	return nicks
+}
+
+// NewIsOnMessage creates a query to check which of the given nicknames are currently online.
+func NewIsOnMessage(prefix Prefix, nicknames ...string) (IsOnMessage, error) {
+	if err := validateNicknames(nicknames, 0); err != nil {
+		return nil, err
+	}
+	return &isOnMessage{
+		message{
+			prefix:     prefix,
+			command:    IsOnCommand,
+			parameters: nicknames,
+		},
+	}, nil
+}
+
+type UserHostMessage interface {
+	Message
+	isUserHost()
+	Nicknames() []string
+}
+
+type userHostMessage struct {
+	message
+}
+
+func (msg *userHostMessage) isUserHost() {}
+
+func (msg *userHostMessage) Nicknames() []string {
+	var nicks []string
+	for _, p := range msg.parameters {
+		nicks = append(nicks, strings.Fields(p)...)
+	}
+	return nicks
+}
+
+// NewUserHostMessage creates a query for the hostnames of the given nicknames (up to
+// MaxUserhostNicknames).
+func NewUserHostMessage(prefix Prefix, nicknames ...string) (UserHostMessage, error) {
+	if err := validateNicknames(nicknames, MaxUserhostNicknames); err != nil {
+		return nil, err
+	}
+	return &userHostMessage{
+		message{
+			prefix:     prefix,
+			command:    UserHostCommand,
+			parameters: nicknames,
+		},
+	}, nil
+}
+
+// validateNicknames checks that at least one (and at most max, unless max is zero)
+// valid nicknames have been given.
+func validateNicknames(nicknames []string, max int) error {
+	if len(nicknames) == 0 {
+		return fmt.Errorf("no nickname given")
+	}
+	if max > 0 && len(nicknames) > max {
+		return fmt.Errorf("got %d nicknames, which exceeds the allowed maximum of %d nicknames", len(nicknames), max)
+	}
+	for _, nick := range nicknames {
+		if err := validateNickname(nick); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PingMessage interface {
+	Message
+	isPing()
+	Server1() string
+}
+
+type pingMessage struct {
+	message
+}
+
+func (msg *pingMessage) isPing() {}
+
+func (msg *pingMessage) Server1() string {
+	return msg.param(0)
+}
+
+// NewPingMessage creates a message to test the presence of the other end of the connection.
+// The server will answer using a PONG message that carries the given token.
+func NewPingMessage(prefix Prefix, token string) (PingMessage, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no ping token given")
+	}
+	if err := validateParameter("token", token); err != nil {
+		return nil, err
+	}
+	return &pingMessage{
+		message{
+			prefix:     prefix,
+			command:    PingCommand,
+			parameters: []string{token},
+		},
+	}, nil
+}
+
+type PongMessage interface {
+	Message
+	isPong()
+	Server1() string
+}
+
+type pongMessage struct {
+	message
+}
+
+func (msg *pongMessage) isPong() {}
+
+func (msg *pongMessage) Server1() string {
+	return msg.param(0)
+}
+
+func NewPongMessage(prefix Prefix, server1 string) PongMessage {
+	return &pongMessage{
+		message{
+			prefix:     prefix,
+			command:    PongCommand,
+			parameters: []string{server1},
+		},
+	}
+}
+
+// ErrorMessage is sent by servers to report a serious or fatal error, usually right
+// before the connection gets closed.
+type ErrorMessage interface {
+	Message
+	isError()
+	Text() string
+}
+
+type errorMessage struct {
+	message
+}
+
+func (msg *errorMessage) isError() {}
+
+func (msg *errorMessage) Text() string {
+	return msg.param(0)
+}
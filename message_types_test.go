@@ -0,0 +1,179 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTypedMessage(t *testing.T) {
+	var testData = []struct {
+		raw string
+		typ reflect.Type
+	}{
+		{":john!jdoe@example.com PRIVMSG #test :Hello there", reflect.TypeOf(&privmsgMessage{})},
+		{":john!jdoe@example.com NOTICE jane :Hello there", reflect.TypeOf(&noticeMessage{})},
+		{":john!jdoe@example.com JOIN #test", reflect.TypeOf(&joinMessage{})},
+		{":john!jdoe@example.com PART #test :See you later", reflect.TypeOf(&partMessage{})},
+		{":john!jdoe@example.com KICK #test jane :Go away", reflect.TypeOf(&kickMessage{})},
+		{":john!jdoe@example.com MODE #test +o jane", reflect.TypeOf(&modeMessage{})},
+		{":john!jdoe@example.com TOPIC #test :New topic", reflect.TypeOf(&topicMessage{})},
+		{":john!jdoe@example.com INVITE jane #test", reflect.TypeOf(&inviteMessage{})},
+		{":john!jdoe@example.com NICK johnny", reflect.TypeOf(&nickMessage{})},
+		{"PING irc.example.com", reflect.TypeOf(&pingMessage{})},
+		{"WHOIS jane", reflect.TypeOf(&whoisMessage{})},
+		{"WHO #test o", reflect.TypeOf(&whoMessage{})},
+		{":irc.example.com 001 john :Welcome", reflect.TypeOf(&message{})},
+		{":john!jdoe@example.com PRIVMSG #test", reflect.TypeOf(&message{})},
+	}
+	for _, td := range testData {
+		msg, err := NewMessageFromString(td.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ := reflect.TypeOf(NewTypedMessage(msg)); typ != td.typ {
+			t.Errorf("NewTypedMessage(%q) -> %v, expected: %v", td.raw, typ, td.typ)
+		}
+	}
+}
+
+func TestNewTypedMessage_TypeSwitch(t *testing.T) {
+	msg, _ := NewMessageFromString(":john!jdoe@example.com NOTICE #test :Hello there")
+	switch m := NewTypedMessage(msg).(type) {
+	case PrivmsgMessage:
+		t.Errorf("NOTICE has been mistaken for a PRIVMSG")
+	case NoticeMessage:
+		if m.Target() != "#test" || m.Text() != "Hello there" {
+			t.Errorf("NoticeMessage -> %q, %q", m.Target(), m.Text())
+		}
+	default:
+		t.Errorf("unexpected message type: %T", m)
+	}
+}
+
+func TestNewTypedMessage_Accessors(t *testing.T) {
+	msg, _ := NewMessageFromString(":john!jdoe@example.com KICK #test jane :Go away")
+	kick := NewTypedMessage(msg).(KickMessage)
+	if kick.Channel() != "#test" || kick.Nickname() != "jane" || kick.Comment() != "Go away" {
+		t.Errorf("KickMessage -> %q, %q, %q", kick.Channel(), kick.Nickname(), kick.Comment())
+	}
+	if kick.Prefix().Nickname() != "john" {
+		t.Errorf("KickMessage.Prefix().Nickname() -> %q, expected: %q", kick.Prefix().Nickname(), "john")
+	}
+
+	msg, _ = NewMessageFromString("MODE #test +ov jane john")
+	mode := NewTypedMessage(msg).(ModeMessage)
+	if mode.Target() != "#test" || mode.ModeString() != "+ov" || !reflect.DeepEqual(mode.ModeArgs(), []string{"jane", "john"}) {
+		t.Errorf("ModeMessage -> %q, %q, %v", mode.Target(), mode.ModeString(), mode.ModeArgs())
+	}
+
+	msg, _ = NewMessageFromString("JOIN #a,#b key")
+	join := NewTypedMessage(msg).(JoinMessage)
+	if !reflect.DeepEqual(join.Channels(), []string{"#a", "#b"}) || !reflect.DeepEqual(join.Keys(), []string{"key"}) {
+		t.Errorf("JoinMessage -> %v, %v", join.Channels(), join.Keys())
+	}
+}
+
+func TestNewTypedMessage_AlreadyTyped(t *testing.T) {
+	msg, _ := NewPrivmsgMessage(EmptyPrefix, "#test", "Hi")
+	if upgraded := NewTypedMessage(msg); upgraded != msg {
+		t.Errorf("NewTypedMessage() should return typed messages as they are")
+	}
+}
+
+func TestTypedMessage_String(t *testing.T) {
+	var testData = []struct {
+		msg func() (Message, error)
+		str string
+	}{
+		{func() (Message, error) { return NewPrivmsgMessage(EmptyPrefix, "#test", "Hello there") }, "PRIVMSG #test :Hello there"},
+		{func() (Message, error) { return NewNoticeMessage(EmptyPrefix, "jane", "Hi") }, "NOTICE jane Hi"},
+		{func() (Message, error) { return NewNickMessage(EmptyPrefix, "JohnDoe") }, "NICK JohnDoe"},
+		{func() (Message, error) {
+			return NewUserMessage(EmptyPrefix, "jdoe", "John Doe", UserModeInvisible)
+		}, "USER jdoe 8 * :John Doe"},
+		{func() (Message, error) { return NewJoinMessage(EmptyPrefix, "#a", "#b") }, "JOIN #a,#b"},
+		{func() (Message, error) {
+			return NewJoinMessageWithKeys(EmptyPrefix, []string{"#a", "#b"}, []string{"key"})
+		}, "JOIN #a,#b key"},
+		{func() (Message, error) { return NewPartMessage(EmptyPrefix, "#test", "") }, "PART #test"},
+		{func() (Message, error) { return NewKickMessage(EmptyPrefix, "#test", "jane", "Go away") }, "KICK #test jane :Go away"},
+		{func() (Message, error) { return NewModeMessage(EmptyPrefix, "#test", "+o", "jane") }, "MODE #test +o jane"},
+		{func() (Message, error) { return NewModeMessage(EmptyPrefix, "#test", "") }, "MODE #test"},
+		{func() (Message, error) { return NewModeMessage(EmptyPrefix, "=test", "+n") }, "MODE =test +n"},
+		{func() (Message, error) { return NewTopicMessage(EmptyPrefix, "#test", "") }, "TOPIC #test :"},
+		{func() (Message, error) { return NewTopicQueryMessage(EmptyPrefix, "#test") }, "TOPIC #test"},
+		{func() (Message, error) { return NewInviteMessage(EmptyPrefix, "jane", "#test") }, "INVITE jane #test"},
+		{func() (Message, error) { return NewWhoMessage(EmptyPrefix, "#test", true) }, "WHO #test o"},
+		{func() (Message, error) { return NewWhoxMessage(EmptyPrefix, "#test", "cuhnfar", "42") }, "WHO #test %tcuhnfar,42"},
+		{func() (Message, error) { return NewWhoisMessage(EmptyPrefix, "jane") }, "WHOIS jane"},
+		{func() (Message, error) { return NewWhowasMessage(EmptyPrefix, "jane", 3) }, "WHOWAS jane 3"},
+		{func() (Message, error) { return NewAwayMessage(EmptyPrefix, "") }, "AWAY"},
+		{func() (Message, error) { return NewAwayMessage(EmptyPrefix, "Gone fishing") }, "AWAY :Gone fishing"},
+		{func() (Message, error) { return NewIsOnMessage(EmptyPrefix, "jane", "john") }, "ISON jane john"},
+		{func() (Message, error) { return NewUserHostMessage(EmptyPrefix, "jane") }, "USERHOST jane"},
+		{func() (Message, error) { return NewNamesMessage(EmptyPrefix) }, "NAMES"},
+		{func() (Message, error) { return NewListMessage(EmptyPrefix, "#a", "#b") }, "LIST #a,#b"},
+		{func() (Message, error) { return NewOperMessage(EmptyPrefix, "admin", "secret") }, "OPER admin secret"},
+		{func() (Message, error) { return NewPingMessage(EmptyPrefix, "token") }, "PING token"},
+	}
+	for _, td := range testData {
+		msg, err := td.msg()
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", td.str, err)
+			continue
+		}
+		if str := msg.String(); str != td.str {
+			t.Errorf("String() -> %q, expected: %q", str, td.str)
+		}
+	}
+}
+
+func TestTypedMessage_Validation(t *testing.T) {
+	var testData = []struct {
+		name string
+		fn   func() error
+	}{
+		{"nick", func() error { _, err := NewNickMessage(EmptyPrefix, "1nvalid"); return err }},
+		{"user", func() error { _, err := NewUserMessage(EmptyPrefix, "j@doe", "John Doe"); return err }},
+		{"user empty", func() error { _, err := NewUserMessage(EmptyPrefix, "", "John Doe"); return err }},
+		{"user realname", func() error { _, err := NewUserMessage(EmptyPrefix, "jdoe", "John\r\nQUIT"); return err }},
+		{"privmsg target", func() error { _, err := NewPrivmsgMessage(EmptyPrefix, "#with space", "Hi"); return err }},
+		{"privmsg empty target", func() error { _, err := NewPrivmsgMessage(EmptyPrefix, "", "Hi"); return err }},
+		{"privmsg empty text", func() error { _, err := NewPrivmsgMessage(EmptyPrefix, "jane", ""); return err }},
+		{"privmsg injection", func() error { _, err := NewPrivmsgMessage(EmptyPrefix, "jane", "Hi\r\nQUIT"); return err }},
+		{"notice target", func() error { _, err := NewNoticeMessage(EmptyPrefix, ":jane", "Hi"); return err }},
+		{"join", func() error { _, err := NewJoinMessage(EmptyPrefix, "test"); return err }},
+		{"join without channel", func() error { _, err := NewJoinMessage(EmptyPrefix); return err }},
+		{"join keys", func() error {
+			_, err := NewJoinMessageWithKeys(EmptyPrefix, []string{"#a"}, []string{"k1", "k2"})
+			return err
+		}},
+		{"part", func() error { _, err := NewPartMessage(EmptyPrefix, "#a,#b", ""); return err }},
+		{"kick", func() error { _, err := NewKickMessage(EmptyPrefix, "#test", "", ""); return err }},
+		{"mode target", func() error { _, err := NewModeMessage(EmptyPrefix, "#a b", "+o"); return err }},
+		{"mode args", func() error { _, err := NewModeMessage(EmptyPrefix, "#test", "", "jane"); return err }},
+		{"topic", func() error { _, err := NewTopicMessage(EmptyPrefix, "#test", "a\nb"); return err }},
+		{"invite", func() error { _, err := NewInviteMessage(EmptyPrefix, "jane", "test"); return err }},
+		{"whox token", func() error { _, err := NewWhoxMessage(EmptyPrefix, "#test", "cuhn", "1234"); return err }},
+		{"whox fields", func() error { _, err := NewWhoxMessage(EmptyPrefix, "#test", "cu,hn", "1"); return err }},
+		{"whois", func() error { _, err := NewWhoisMessage(EmptyPrefix, "ja ne"); return err }},
+		{"ison", func() error { _, err := NewIsOnMessage(EmptyPrefix); return err }},
+		{"userhost", func() error {
+			_, err := NewUserHostMessage(EmptyPrefix, "a", "b", "c", "d", "e", "f")
+			return err
+		}},
+		{"ping", func() error { _, err := NewPingMessage(EmptyPrefix, ""); return err }},
+	}
+	for _, td := range testData {
+		if err := td.fn(); err == nil {
+			t.Errorf("%s: expected an error", td.name)
+		}
+	}
+}
+
+func TestTypedMessage_MissingParameters(t *testing.T) {
+	msg := NewMessageWithoutPrefix(QuitCommand)
+	if quit := NewTypedMessage(msg).(QuitMessage); quit.Reason() != "" {
+		t.Errorf("QuitMessage.Reason() -> %q, expected: %q", quit.Reason(), "")
+	}
+}
@@ -0,0 +1,266 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModeType classifies modes by the way they take arguments (see ChanModes).
+type ModeType int
+
+const (
+	// ModeTypeFlag modes never take an argument (CHANMODES type D, all user modes).
+	ModeTypeFlag ModeType = iota
+	// ModeTypeList modes manage a list (e.g. bans) and always take an argument, unless the
+	// list is being queried (CHANMODES type A).
+	ModeTypeList
+	// ModeTypeSetting modes always take an argument (CHANMODES type B, e.g. the key).
+	ModeTypeSetting
+	// ModeTypeParameter modes only take an argument when being set (CHANMODES type C,
+	// e.g. the user limit).
+	ModeTypeParameter
+	// ModeTypePrefix modes grant membership prefixes and take a nickname as argument
+	// (PREFIX, e.g. 'o' and 'v').
+	ModeTypePrefix
+)
+
+// takesArg checks if modes of this type take an argument when being added or removed.
+func (t ModeType) takesArg(adding bool) bool {
+	switch t {
+	case ModeTypeList, ModeTypeSetting, ModeTypePrefix:
+		return true
+	case ModeTypeParameter:
+		return adding
+	}
+	return false
+}
+
+// ModeType returns the type of the given channel mode. Modes that the server hasn't
+// announced are considered to be flags.
+func (is *ISupport) ModeType(mode rune) ModeType {
+	switch {
+	case strings.ContainsRune(is.PrefixModes, mode):
+		return ModeTypePrefix
+	case strings.ContainsRune(is.ChanModes.A, mode):
+		return ModeTypeList
+	case strings.ContainsRune(is.ChanModes.B, mode):
+		return ModeTypeSetting
+	case strings.ContainsRune(is.ChanModes.C, mode):
+		return ModeTypeParameter
+	}
+	return ModeTypeFlag
+}
+
+// ModeChange is a single mode being added to or removed from a channel or a user.
+type ModeChange struct {
+	Adding bool
+	Mode   rune
+	Type   ModeType
+	// Arg is the argument of the mode, if it takes one (e.g. a nickname or a ban mask).
+	Arg string
+}
+
+// String returns the mode character along with its sign (e.g. "+o").
+func (c ModeChange) String() string {
+	if c.Adding {
+		return "+" + string(c.Mode)
+	}
+	return "-" + string(c.Mode)
+}
+
+// ModeChanges is a list of mode changes, in the order in which they are being applied.
+type ModeChanges []ModeChange
+
+// ModeString returns the mode string (e.g. "+ov-k") and the arguments of the changes.
+func (changes ModeChanges) ModeString() (modes string, args []string) {
+	var sb strings.Builder
+	for i, c := range changes {
+		if i == 0 || c.Adding != changes[i-1].Adding {
+			if c.Adding {
+				sb.WriteByte('+')
+			} else {
+				sb.WriteByte('-')
+			}
+		}
+		sb.WriteRune(c.Mode)
+		if c.Arg != "" {
+			args = append(args, c.Arg)
+		}
+	}
+	return sb.String(), args
+}
+
+// String returns the mode string followed by the arguments (e.g. "+ov-k alice bob key").
+func (changes ModeChanges) String() string {
+	modes, args := changes.ModeString()
+	return strings.Join(append([]string{modes}, args...), " ")
+}
+
+// ParseModeChanges parses the given channel mode string (e.g. "+ov-k") along with its
+// arguments (e.g. "alice", "bob" and "key"), using the channel modes and membership
+// prefixes announced by the server (CHANMODES and PREFIX). If isupport is nil, the
+// defaults of RfC-2811 apply. If arguments are missing, the changes that could be parsed
+// are returned along with an error; the affected changes have an empty argument.
+func ParseModeChanges(isupport *ISupport, modes string, args []string) (changes ModeChanges, err error) {
+	if isupport == nil {
+		isupport = defaultISupport
+	}
+	adding := true
+	for _, mode := range modes {
+		switch mode {
+		case '+':
+			adding = true
+			continue
+		case '-':
+			adding = false
+			continue
+		}
+		c := ModeChange{Adding: adding, Mode: mode, Type: isupport.ModeType(mode)}
+		if c.Type.takesArg(adding) {
+			if len(args) > 0 {
+				c.Arg, args = args[0], args[1:]
+			} else if c.Type != ModeTypeList && err == nil {
+				// Lists may be queried by omitting the argument (e.g. "MODE #channel +b").
+				err = fmt.Errorf("missing argument for mode %s", c)
+			}
+		}
+		changes = append(changes, c)
+	}
+	return
+}
+
+// ParseUserModeChanges parses the given user mode string (e.g. "+iw-o"). User modes
+// never take arguments.
+func ParseUserModeChanges(modes string) (changes ModeChanges) {
+	adding := true
+	for _, mode := range modes {
+		switch mode {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		default:
+			changes = append(changes, ModeChange{Adding: adding, Mode: mode, Type: ModeTypeFlag})
+		}
+	}
+	return
+}
+
+// ParseModeMessage parses the mode changes of the given MODE message. Channel modes are
+// interpreted according to the given RPL_ISUPPORT features (see ParseModeChanges()),
+// all other targets are considered to be users.
+func ParseModeMessage(isupport *ISupport, msg ModeMessage) (ModeChanges, error) {
+	if isupport == nil {
+		isupport = defaultISupport
+	}
+	if isupport.IsChannelName(msg.Target()) {
+		return ParseModeChanges(isupport, msg.ModeString(), msg.ModeArgs())
+	}
+	return ParseUserModeChanges(msg.ModeString()), nil
+}
+
+// ModeBuilder collects mode changes and turns them into as few MODE messages as possible,
+// respecting the maximum number of modes with argument per message (MODES) as well as
+// the maximum message length.
+type ModeBuilder struct {
+	isupport *ISupport
+	changes  ModeChanges
+}
+
+// NewModeBuilder creates a builder for channel mode changes. The types of the modes are
+// determined using the given RPL_ISUPPORT features. If isupport is nil, the defaults of
+// RfC-2811 apply.
+func NewModeBuilder(isupport *ISupport) *ModeBuilder {
+	if isupport == nil {
+		isupport = defaultISupport
+	}
+	return &ModeBuilder{isupport: isupport}
+}
+
+// Add adds the given mode. The argument is ignored for modes that don't take one.
+func (b *ModeBuilder) Add(mode rune, arg string) *ModeBuilder {
+	return b.change(true, mode, arg)
+}
+
+// Remove removes the given mode. The argument is ignored for modes that don't take one.
+func (b *ModeBuilder) Remove(mode rune, arg string) *ModeBuilder {
+	return b.change(false, mode, arg)
+}
+
+func (b *ModeBuilder) change(adding bool, mode rune, arg string) *ModeBuilder {
+	c := ModeChange{Adding: adding, Mode: mode, Type: b.isupport.ModeType(mode)}
+	if c.Type.takesArg(adding) {
+		c.Arg = arg
+	}
+	b.changes = append(b.changes, c)
+	return b
+}
+
+// Changes returns the changes that have been collected so far.
+func (b *ModeBuilder) Changes() ModeChanges {
+	return append(ModeChanges(nil), b.changes...)
+}
+
+// Messages returns the MODE messages that apply the collected changes to the given target.
+func (b *ModeBuilder) Messages(target string) ([]ModeMessage, error) {
+	maxArgs := b.isupport.Modes
+	if limit := MaxMessageParameterCount - 2; maxArgs <= 0 || maxArgs > limit {
+		maxArgs = limit
+	}
+	// "MODE <target> <modes> <args>\r\n" must not exceed the maximum message length.
+	maxLen := MsgMaxLen - len("\r\n") - len(ModeCommand) - len(target) - 2
+
+	var messages []ModeMessage
+	var batch ModeChanges
+	length, argCount := 0, 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		modes, args := batch.ModeString()
+		msg, err := NewModeMessage(EmptyPrefix, target, modes, args...)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, msg)
+		batch, length, argCount = nil, 0, 0
+		return nil
+	}
+	for _, c := range b.changes {
+		if c.Type.takesArg(c.Adding) && c.Arg == "" {
+			if c.Type != ModeTypeList {
+				return nil, fmt.Errorf("missing argument for mode %s", c)
+			}
+			// Queries of lists get a message of their own, so that the arguments of
+			// the other changes cannot be mistaken for the list entry.
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			batch = ModeChanges{c}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Every change costs its mode character and possibly a sign, its argument costs
+		// a separating space as well.
+		cost := 2
+		if c.Arg != "" {
+			cost += 1 + len(c.Arg)
+		}
+		if len(batch) > 0 && ((c.Arg != "" && argCount == maxArgs) || length+cost > maxLen) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		batch = append(batch, c)
+		length += cost
+		if c.Arg != "" {
+			argCount++
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,155 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModeChanges(t *testing.T) {
+	var testdata = []struct {
+		modes    string
+		args     []string
+		expected ModeChanges
+	}{
+		{"+ov-k", []string{"nick1", "nick2", "key"}, ModeChanges{
+			{Adding: true, Mode: 'o', Type: ModeTypePrefix, Arg: "nick1"},
+			{Adding: true, Mode: 'v', Type: ModeTypePrefix, Arg: "nick2"},
+			{Adding: false, Mode: 'k', Type: ModeTypeSetting, Arg: "key"},
+		}},
+		{"+lnt-l", []string{"42"}, ModeChanges{
+			{Adding: true, Mode: 'l', Type: ModeTypeParameter, Arg: "42"},
+			{Adding: true, Mode: 'n', Type: ModeTypeFlag},
+			{Adding: true, Mode: 't', Type: ModeTypeFlag},
+			{Adding: false, Mode: 'l', Type: ModeTypeParameter},
+		}},
+		{"-b+b", []string{"*!*@old", "*!*@new"}, ModeChanges{
+			{Adding: false, Mode: 'b', Type: ModeTypeList, Arg: "*!*@old"},
+			{Adding: true, Mode: 'b', Type: ModeTypeList, Arg: "*!*@new"},
+		}},
+		{"+b", nil, ModeChanges{
+			{Adding: true, Mode: 'b', Type: ModeTypeList},
+		}},
+	}
+	for _, tt := range testdata {
+		changes, err := ParseModeChanges(nil, tt.modes, tt.args)
+		if err != nil {
+			t.Errorf("ParseModeChanges(%s, %v) -> %v", tt.modes, tt.args, err)
+		}
+		if !reflect.DeepEqual(changes, tt.expected) {
+			t.Errorf("ParseModeChanges(%s, %v) -> %v, expected: %v", tt.modes, tt.args, changes, tt.expected)
+		}
+	}
+}
+
+func TestParseModeChanges_ISupport(t *testing.T) {
+	is := NewISupport()
+	is.Update(NewMessage(EmptyPrefix, ISupportReply, "johndoe", "CHANMODES=beI,kf,lj,CMnt", "PREFIX=(qaohv)~&@%+", "are supported"))
+	changes, err := ParseModeChanges(is, "+qfj-h", []string{"alice", "[10j#R10]:5", "3:5", "bob"})
+	if err != nil {
+		t.Fatalf("ParseModeChanges() -> %v", err)
+	}
+	expected := ModeChanges{
+		{Adding: true, Mode: 'q', Type: ModeTypePrefix, Arg: "alice"},
+		{Adding: true, Mode: 'f', Type: ModeTypeSetting, Arg: "[10j#R10]:5"},
+		{Adding: true, Mode: 'j', Type: ModeTypeParameter, Arg: "3:5"},
+		{Adding: false, Mode: 'h', Type: ModeTypePrefix, Arg: "bob"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("ParseModeChanges() -> %v, expected: %v", changes, expected)
+	}
+}
+
+func TestParseModeChanges_MissingArgument(t *testing.T) {
+	changes, err := ParseModeChanges(nil, "+ok", []string{"alice"})
+	if err == nil {
+		t.Error("ParseModeChanges() should fail if arguments are missing")
+	}
+	if len(changes) != 2 || changes[0].Arg != "alice" || changes[1].Arg != "" {
+		t.Errorf("ParseModeChanges() -> %v, expected the changes that could be parsed", changes)
+	}
+}
+
+func TestParseModeMessage(t *testing.T) {
+	msg, _ := NewModeMessage(EmptyPrefix, "johndoe", "+iw-x")
+	changes, err := ParseModeMessage(nil, msg)
+	if err != nil {
+		t.Fatalf("ParseModeMessage() -> %v", err)
+	}
+	if s := changes.String(); s != "+iw-x" {
+		t.Errorf("ParseModeMessage(%s) -> %s, expected: +iw-x", msg, s)
+	}
+	msg, _ = NewModeMessage(EmptyPrefix, "#test", "+o-v", "alice", "bob")
+	if changes, _ = ParseModeMessage(nil, msg); changes.String() != "+o-v alice bob" {
+		t.Errorf("ParseModeMessage(%s) -> %s", msg, changes)
+	}
+}
+
+func TestModeBuilder(t *testing.T) {
+	b := NewModeBuilder(nil).
+		Add('o', "alice").Add('o', "bob").Add('v', "carol").Add('v', "dave").
+		Remove('k', "key").Add('l', "10").Remove('l', "ignored").Add('n', "ignored")
+	messages, err := b.Messages("#test")
+	if err != nil {
+		t.Fatalf("Messages() -> %v", err)
+	}
+	// MODES defaults to three modes with argument per message, modes without argument
+	// don't count.
+	expected := []string{
+		"MODE #test +oov alice bob carol",
+		"MODE #test +v-k+l-l+n dave key 10",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Messages() -> %v, expected: %v", messages, expected)
+	}
+	for i, msg := range messages {
+		if msg.String() != expected[i] {
+			t.Errorf("Messages()[%d] -> %s, expected: %s", i, msg, expected[i])
+		}
+	}
+}
+
+func TestModeBuilder_ListQueries(t *testing.T) {
+	messages, err := NewModeBuilder(nil).Add('o', "alice").Add('b', "").Add('v', "bob").Messages("#test")
+	if err != nil {
+		t.Fatalf("Messages() -> %v", err)
+	}
+	expected := []string{"MODE #test +o alice", "MODE #test +b", "MODE #test +v bob"}
+	if len(messages) != len(expected) {
+		t.Fatalf("Messages() -> %v, expected: %v", messages, expected)
+	}
+	for i, msg := range messages {
+		if msg.String() != expected[i] {
+			t.Errorf("Messages()[%d] -> %s, expected: %s", i, msg, expected[i])
+		}
+	}
+}
+
+func TestModeBuilder_Limits(t *testing.T) {
+	is := NewISupport()
+	is.Update(NewMessage(EmptyPrefix, ISupportReply, "johndoe", "MODES", "are supported"))
+	b := NewModeBuilder(is)
+	for i := 0; i < 40; i++ {
+		b.Add('b', "*!*@very-long-hostname-that-has-been-banned-"+string(rune('a'+i%26))+".example.com")
+	}
+	messages, err := b.Messages("#test")
+	if err != nil {
+		t.Fatalf("Messages() -> %v", err)
+	}
+	count := 0
+	for _, msg := range messages {
+		if l := len(msg.String()) + 2; l > MsgMaxLen {
+			t.Errorf("message exceeds %d bytes: %d", MsgMaxLen, l)
+		}
+		if n := len(msg.ModeArgs()); n > MaxMessageParameterCount-2 {
+			t.Errorf("message has %d mode arguments", n)
+		}
+		count += len(msg.ModeArgs())
+	}
+	if count != 40 {
+		t.Errorf("messages contain %d bans, expected: 40", count)
+	}
+
+	if _, err := NewModeBuilder(nil).Add('k', "").Messages("#test"); err == nil {
+		t.Error("Messages() should fail if an argument is missing")
+	}
+}
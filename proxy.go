@@ -0,0 +1,252 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+)
+
+// Types of proxies that can be used to reach a server (see Proxy.Type).
+const (
+	// ProxySOCKS5 tunnels the connection through a SOCKS5 proxy (RfC-1928). Username and
+	// password authentication (RfC-1929) is used, if a username has been configured.
+	ProxySOCKS5 = "socks5"
+	// ProxyHTTP tunnels the connection through an HTTP proxy using the CONNECT method.
+	// Basic authentication is used, if a username has been configured.
+	ProxyHTTP = "http"
+)
+
+// Proxy describes a proxy server through which the connection to an IRC server is being
+// tunneled. Proxies are only used by the TCP based transports (TCP, TLS and WebSocket).
+type Proxy struct {
+	Type     string `json:"type"`
+	Hostname string `json:"hostname"`
+	Port     uint   `json:"port"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// WithProxy tunnels the connections to all servers through the given proxy, unless a
+// server has a proxy of its own (see Server.Proxy and Network.Proxy).
+func WithProxy(proxy Proxy) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.proxy = &proxy
+	}
+}
+
+// address returns the "host:port" address of the proxy.
+func (p *Proxy) address() string {
+	return net.JoinHostPort(p.Hostname, strconv.Itoa(int(p.Port)))
+}
+
+// dialContext connects to the given address through the proxy. The connection to the
+// proxy itself is established using the given dialer.
+func (p *Proxy) dialContext(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	switch p.Type {
+	case ProxySOCKS5, ProxyHTTP:
+	default:
+		return nil, fmt.Errorf("unknown proxy type: %s", p.Type)
+	}
+	sock, err := dialer.DialContext(ctx, "tcp", p.address())
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to proxy %s: %v", p.address(), err)
+	}
+	// The negotiation with the proxy must not outlive the context either.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sock.Close()
+		case <-stop:
+		}
+	}()
+	var tunnel net.Conn
+	if p.Type == ProxySOCKS5 {
+		tunnel, err = p.socks5Connect(sock, addr)
+	} else {
+		tunnel, err = p.httpConnect(sock, addr)
+	}
+	if err != nil {
+		sock.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("proxy %s: %v", p.address(), err)
+	}
+	return tunnel, nil
+}
+
+// SOCKS5 protocol constants (see RfC-1928 and RfC-1929).
+const (
+	socks5Version             byte = 0x05
+	socks5AuthNone            byte = 0x00
+	socks5AuthPassword        byte = 0x02
+	socks5AuthNoAcceptable    byte = 0xFF
+	socks5AuthPasswordVersion byte = 0x01
+	socks5CmdConnect          byte = 0x01
+	socks5AddrIPv4            byte = 0x01
+	socks5AddrDomain          byte = 0x03
+	socks5AddrIPv6            byte = 0x04
+	socks5ReplySucceeded      byte = 0x00
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+func (p *Proxy) socks5Connect(sock net.Conn, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port: %s", portStr)
+	}
+
+	methods := []byte{socks5AuthNone}
+	if p.Username != "" {
+		methods = []byte{socks5AuthNone, socks5AuthPassword}
+	}
+	if _, err = sock.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(sock, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected SOCKS version: %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if p.Username == "" {
+			return nil, fmt.Errorf("SOCKS5 proxy requires authentication")
+		}
+		if len(p.Username) > 255 || len(p.Password) > 255 {
+			return nil, fmt.Errorf("SOCKS5 username and password must not exceed 255 bytes")
+		}
+		auth := []byte{socks5AuthPasswordVersion, byte(len(p.Username))}
+		auth = append(auth, p.Username...)
+		auth = append(auth, byte(len(p.Password)))
+		auth = append(auth, p.Password...)
+		if _, err = sock.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(sock, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0x00 {
+			return nil, fmt.Errorf("SOCKS5 authentication failed")
+		}
+	case socks5AuthNoAcceptable:
+		return nil, fmt.Errorf("SOCKS5 proxy didn't accept any authentication method")
+	default:
+		return nil, fmt.Errorf("SOCKS5 proxy selected an unsupported authentication method: %d", reply[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("hostname is too long: %s", host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = sock.Write(req); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(sock, header); err != nil {
+		return nil, err
+	}
+	if header[1] != socks5ReplySucceeded {
+		if reason, ok := socks5Replies[header[1]]; ok {
+			return nil, fmt.Errorf("SOCKS5 connect failed: %s", reason)
+		}
+		return nil, fmt.Errorf("SOCKS5 connect failed with code %d", header[1])
+	}
+	// The bound address is of no interest, but it has to be consumed.
+	var skip int
+	switch header[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err = io.ReadFull(sock, length); err != nil {
+			return nil, err
+		}
+		skip = int(length[0])
+	default:
+		return nil, fmt.Errorf("SOCKS5 proxy replied with an unknown address type: %d", header[3])
+	}
+	if _, err = io.ReadFull(sock, make([]byte, skip+2)); err != nil {
+		return nil, err
+	}
+	return sock, nil
+}
+
+func (p *Proxy) httpConnect(sock net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &neturl.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if p.Username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(sock); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(sock)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response to CONNECT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT failed: %s", resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		// The server may already have sent something (e.g. a NOTICE) through the tunnel.
+		return &bufferedConn{Conn: sock, reader: reader}, nil
+	}
+	return sock, nil
+}
+
+// bufferedConn is a connection, some of whose data has already been read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
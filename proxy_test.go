@@ -0,0 +1,212 @@
+package irc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// fakeProxy is a minimal stand-in for a SOCKS5 or HTTP CONNECT proxy. It accepts a single
+// connection, performs the proxy handshake and relays the data to the requested address.
+type fakeProxy struct {
+	t        *testing.T
+	listener net.Listener
+	username string
+	password string
+	targets  chan string // the addresses the clients asked to connect to.
+}
+
+func newFakeProxy(t *testing.T, proxyType string, username string, password string) *fakeProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fake proxy could not listen: %v", err)
+	}
+	p := &fakeProxy{t: t, listener: l, username: username, password: password, targets: make(chan string, 1)}
+	go func() {
+		sock, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer sock.Close()
+		reader := bufio.NewReader(sock)
+		var target net.Conn
+		if proxyType == ProxySOCKS5 {
+			target = p.socks5Handshake(sock, reader)
+		} else {
+			target = p.httpHandshake(sock, reader)
+		}
+		if target == nil {
+			return
+		}
+		defer target.Close()
+		go io.Copy(target, reader)
+		io.Copy(sock, target)
+	}()
+	return p
+}
+
+func (p *fakeProxy) proxy(proxyType string, username string, password string) Proxy {
+	return Proxy{
+		Type:     proxyType,
+		Hostname: "127.0.0.1",
+		Port:     uint(p.listener.Addr().(*net.TCPAddr).Port),
+		Username: username,
+		Password: password,
+	}
+}
+
+func (p *fakeProxy) close() {
+	p.listener.Close()
+}
+
+func (p *fakeProxy) socks5Handshake(sock net.Conn, reader *bufio.Reader) net.Conn {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil
+	}
+	methods := make([]byte, header[1])
+	io.ReadFull(reader, methods)
+	if p.username == "" {
+		sock.Write([]byte{socks5Version, socks5AuthNone})
+	} else {
+		sock.Write([]byte{socks5Version, socks5AuthPassword})
+		version, _ := reader.ReadByte()
+		userLen, _ := reader.ReadByte()
+		user := make([]byte, userLen)
+		io.ReadFull(reader, user)
+		passLen, _ := reader.ReadByte()
+		pass := make([]byte, passLen)
+		io.ReadFull(reader, pass)
+		if version != socks5AuthPasswordVersion || string(user) != p.username || string(pass) != p.password {
+			sock.Write([]byte{socks5AuthPasswordVersion, 0x01})
+			return nil
+		}
+		sock.Write([]byte{socks5AuthPasswordVersion, 0x00})
+	}
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(reader, req); err != nil || req[1] != socks5CmdConnect {
+		return nil
+	}
+	var host string
+	switch req[3] {
+	case socks5AddrIPv4:
+		ip := make([]byte, net.IPv4len)
+		io.ReadFull(reader, ip)
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		length, _ := reader.ReadByte()
+		name := make([]byte, length)
+		io.ReadFull(reader, name)
+		host = string(name)
+	default:
+		sock.Write([]byte{socks5Version, 0x08, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return nil
+	}
+	port := make([]byte, 2)
+	io.ReadFull(reader, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+	p.targets <- addr
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		sock.Write([]byte{socks5Version, 0x05, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return nil
+	}
+	sock.Write([]byte{socks5Version, socks5ReplySucceeded, 0x00, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+	return target
+}
+
+func (p *fakeProxy) httpHandshake(sock net.Conn, reader *bufio.Reader) net.Conn {
+	req, err := http.ReadRequest(reader)
+	if err != nil || req.Method != http.MethodConnect {
+		return nil
+	}
+	if p.username != "" {
+		expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(p.username+":"+p.password))
+		if req.Header.Get("Proxy-Authorization") != expected {
+			sock.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n"))
+			return nil
+		}
+	}
+	p.targets <- req.Host
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		sock.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n"))
+		return nil
+	}
+	sock.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return target
+}
+
+func TestClientConnection_Proxy(t *testing.T) {
+	var testdata = []struct {
+		name      string
+		proxyType string
+		username  string
+		password  string
+	}{
+		{"socks5", ProxySOCKS5, "", ""},
+		{"socks5 with authentication", ProxySOCKS5, "alice", "secret"},
+		{"http", ProxyHTTP, "", ""},
+		{"http with authentication", ProxyHTTP, "alice", "secret"},
+	}
+	for _, tt := range testdata {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			defer srv.close()
+			p := newFakeProxy(t, tt.proxyType, tt.username, tt.password)
+			defer p.close()
+			proxy := p.proxy(tt.proxyType, tt.username, tt.password)
+			network := Network{
+				Servers: []Server{{Hostname: "127.0.0.1", Port: uint(srv.port())}},
+				Proxy:   &proxy,
+			}
+			conn, err := NewClientConnectionForNetwork(network, WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+			if err != nil {
+				t.Fatalf("NewClientConnectionForNetwork() -> %v", err)
+			}
+			drainConnection(conn)
+			result := openAsync(conn)
+			registerWithFakeServer(srv, "johndoe")
+			if err := <-result; err != nil {
+				t.Fatalf("Open() -> %v", err)
+			}
+			if target := <-p.targets; target != net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.port())) {
+				t.Errorf("proxy has been asked to connect to %s", target)
+			}
+			srv.send("PING :irc.example.com")
+			srv.expect("PONG irc.example.com")
+			conn.Close()
+			conn.Wait()
+		})
+	}
+}
+
+func TestClientConnection_ProxyAuthenticationFailed(t *testing.T) {
+	for _, proxyType := range []string{ProxySOCKS5, ProxyHTTP} {
+		p := newFakeProxy(t, proxyType, "alice", "secret")
+		conn := NewClientConnection("127.0.0.1", 6667, WithProxy(p.proxy(proxyType, "alice", "wrong")))
+		drainConnection(conn)
+		if err := conn.Open(); err == nil {
+			t.Errorf("Open() via %s proxy should fail if the credentials are wrong", proxyType)
+		}
+		conn.Wait()
+		p.close()
+	}
+}
+
+func TestServerProxyOverridesNetworkProxy(t *testing.T) {
+	own := &Proxy{Type: ProxyHTTP, Hostname: "proxy.example.com", Port: 3128}
+	network := Network{
+		Servers: []Server{{Hostname: "irc1.example.com", Port: 6667}, {Hostname: "irc2.example.com", Port: 6667, Proxy: own}},
+		Proxy:   &Proxy{Type: ProxySOCKS5, Hostname: "127.0.0.1", Port: 1080},
+	}
+	conn, _ := NewClientConnectionForNetwork(network)
+	servers := conn.(*clientConnection).servers
+	if servers[0].Proxy != network.Proxy || servers[1].Proxy != own {
+		t.Errorf("servers use proxies %v and %v, expected: %v and %v", servers[0].Proxy, servers[1].Proxy, network.Proxy, own)
+	}
+}
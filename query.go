@@ -0,0 +1,455 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNotConnected is returned by queries, if there is no session with a server.
+var errNotConnected = fmt.Errorf("connection to the server has not been established")
+
+// errSerialHandler is returned by queries that have been sent from a serial handler,
+// which would block the reception of the response.
+var errSerialHandler = fmt.Errorf("queries cannot wait for their response within serial handlers (see HandlerModeConcurrent)")
+
+// abandonedQueryTimeout is the time after which queries that have been abandoned (see
+// clientConnection.query()) stop consuming replies, in case the server never responds.
+const abandonedQueryTimeout = time.Minute
+
+// NickNotFoundError is returned by Whois() and Whowas(), if the server reports that the
+// nickname is not in use (ERR_NOSUCHNICK) or has not been in use (ERR_WASNOSUCHNICK).
+type NickNotFoundError struct {
+	// Reply is the numeric reply that has been sent by the server.
+	Reply Command
+	// Nickname is the nickname that has been queried.
+	Nickname string
+	// Message contains the text that has been sent by the server.
+	Message string
+}
+
+func (e *NickNotFoundError) Error() string {
+	return fmt.Sprintf("no such nick (%s): %s", e.Nickname, e.Message)
+}
+
+// WhoisResult contains the information returned by the server in reply to a WHOIS query.
+type WhoisResult struct {
+	Nickname string
+	Username string
+	Hostname string
+	Realname string
+	// Server is the name of the server the user is connected to, ServerInfo describes it.
+	Server     string
+	ServerInfo string
+	Operator   bool
+	// Account is the name of the account the user is logged into (empty if not logged in).
+	Account     string
+	Away        bool
+	AwayMessage string
+	// Secure tells if the user is connected using TLS.
+	Secure bool
+	// Idle and SignOn are only known if the server has sent RPL_WHOISIDLE.
+	Idle   time.Duration
+	SignOn time.Time
+	// Channels contains the channels of the user along with their membership prefixes
+	// (e.g. "@#test").
+	Channels []string
+	// Replies contains all the replies that make up the result, e.g. to look at numerics
+	// that are not part of the result.
+	Replies []Message
+}
+
+// WhoEntry describes a single user within the result of a WHO query.
+type WhoEntry struct {
+	// Channel is the channel the entry refers to ("*" if there is none).
+	Channel  string
+	Nickname string
+	Username string
+	Hostname string
+	Server   string
+	// Account is the name of the account the user is logged into. It is only known if
+	// the server supports WHOX queries.
+	Account  string
+	Realname string
+	Away     bool
+	Operator bool
+	// Prefixes contains the membership prefixes of the user within the channel (e.g. "@").
+	Prefixes string
+}
+
+// WhowasEntry describes a former user of a nickname within the result of a WHOWAS query.
+type WhowasEntry struct {
+	Nickname   string
+	Username   string
+	Hostname   string
+	Realname   string
+	Server     string
+	ServerInfo string
+}
+
+// query collects the replies to a message that has been sent to the server.
+type query struct {
+	// accept checks if the given message is a reply to the query and if it is the last one.
+	accept  func(msg Message) (reply bool, last bool)
+	replies []Message
+	err     error
+	done    chan struct{} // closed once the last reply has been received.
+	// abandoned is the time the query has been abandoned at; zero while it is pending.
+	abandoned time.Time
+}
+
+// queryTracker correlates incoming messages with the pending queries. Each message is
+// handed to the oldest query that accepts it. Since the server replies in the order in
+// which the queries have been received, queries for the same target do not get mixed up.
+type queryTracker struct {
+	mu      sync.Mutex
+	pending []*query
+	token   int
+	label   uint64
+}
+
+func (t *queryTracker) add(q *query) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, q)
+}
+
+func (t *queryTracker) remove(q *query) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, p := range t.pending {
+		if p == q {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// abandon marks the given query as abandoned. It keeps consuming its replies until
+// the last one has been received, a later query has been completed (the server responds
+// in order, so there won't be a response anymore) or abandonedQueryTimeout has elapsed.
+func (t *queryTracker) abandon(q *query) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q.abandoned = time.Now()
+}
+
+// process hands the given message to the first query that accepts it.
+func (t *queryTracker) process(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evict(len(t.pending), time.Now().Add(-abandonedQueryTimeout))
+	for i, q := range t.pending {
+		reply, last := q.accept(msg)
+		if !reply {
+			continue
+		}
+		q.replies = append(q.replies, msg)
+		if last {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			close(q.done)
+			t.evict(i, time.Now())
+		}
+		return
+	}
+}
+
+// evict removes the queries among the first n pending ones that have been abandoned
+// before the given time.
+func (t *queryTracker) evict(n int, before time.Time) {
+	pending := t.pending[:0]
+	for i, q := range t.pending {
+		if i < n && !q.abandoned.IsZero() && q.abandoned.Before(before) {
+			close(q.done)
+			continue
+		}
+		pending = append(pending, q)
+	}
+	t.pending = pending
+}
+
+// abort fails all pending queries with the given error.
+func (t *queryTracker) abort(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, q := range t.pending {
+		q.err = err
+		close(q.done)
+	}
+	t.pending = nil
+}
+
+// nextWhoxToken returns the token for the next WHOX query (see isWhoxToken()).
+func (t *queryTracker) nextWhoxToken() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	token := whoxTokenBase + t.token
+	t.token = (t.token + 1) % whoxTokens
+	return strconv.Itoa(token)
+}
+
+// nextLabel returns the label for the next labeled request.
+func (t *queryTracker) nextLabel() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.label++
+	return "q" + strconv.FormatUint(t.label, 36)
+}
+
+// query sends the given message and collects the replies that are accepted by the given
+// function. If labeled-response has been negotiated, the message is labeled and the
+// replies are correlated using the label instead (see Request()). If the context is done
+// or the request timeout has elapsed before the last reply has been received, the query
+// is abandoned; its replies will still be consumed for a while, so that they are not
+// mistaken for replies to other queries (see queryTracker.abandon()). Queries cannot be
+// sent using the context of a serial handler, an error is returned right away.
+func (conn *clientConnection) query(ctx context.Context, msg Message, accept func(msg Message) (reply bool, last bool)) ([]Message, error) {
+	if isSerialHandler(ctx) {
+		return nil, errSerialHandler
+	}
+	conn.connMu.RLock()
+	connected := conn.session != nil
+	conn.connMu.RUnlock()
+	if !connected {
+		return nil, errNotConnected
+	}
+	if conn.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.requestTimeout)
+		defer cancel()
+	}
+	var labeled *labeledResponse
+	if conn.HasCapability(LabeledResponse) && conn.HasCapability(Batch) {
+		labeled = &labeledResponse{label: conn.queries.nextLabel(), batches: make(map[string]bool)}
+		msg = labeled.labelMessage(msg)
+		accept = labeled.accept
+	}
+	q := &query{accept: accept, done: make(chan struct{})}
+	conn.queries.add(q)
+	select {
+	case conn.out <- msg:
+	case <-ctx.Done():
+		conn.queries.remove(q)
+		return nil, ctx.Err()
+	}
+	select {
+	case <-q.done:
+		if labeled != nil && q.err == nil {
+			return labeled.strip(q.replies), nil
+		}
+		return q.replies, q.err
+	case <-ctx.Done():
+		conn.queries.abandon(q)
+		return nil, ctx.Err()
+	}
+}
+
+// Whois queries information about the user with the given nickname. All the replies of
+// the server are collected until RPL_ENDOFWHOIS has been received. If the nickname is not
+// in use, a NickNotFoundError is returned.
+func (conn *clientConnection) Whois(ctx context.Context, nickname string) (*WhoisResult, error) {
+	msg, err := NewWhoisMessage(EmptyPrefix, nickname)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.query(ctx, msg, whoisResponse(conn.Casemapping(), nickname))
+	if err != nil {
+		return nil, err
+	}
+	result := &WhoisResult{Nickname: nickname, Replies: replies}
+	for _, reply := range replies {
+		params := reply.Parameters()
+		switch reply.Command() {
+		case NoSuchNickError:
+			return nil, &NickNotFoundError{Reply: reply.Command(), Nickname: nickname, Message: params[len(params)-1]}
+		case WhoisUserReply:
+			// "<client> <nick> <username> <host> * :<realname>"
+			if len(params) > 5 {
+				result.Nickname, result.Username, result.Hostname, result.Realname = params[1], params[2], params[3], params[5]
+			}
+		case WhoisServerReply:
+			// "<client> <nick> <server> :<server info>"
+			if len(params) > 3 {
+				result.Server, result.ServerInfo = params[2], params[3]
+			}
+		case WhoisOperatorReply:
+			result.Operator = true
+		case WhoisIdleReply:
+			// "<client> <nick> <secs> [<signon>] :seconds idle[, signon time]"
+			if len(params) > 3 {
+				if secs, err := strconv.ParseInt(params[2], 10, 64); err == nil {
+					result.Idle = time.Duration(secs) * time.Second
+				}
+				if signOn, err := strconv.ParseInt(params[3], 10, 64); err == nil {
+					result.SignOn = time.Unix(signOn, 0)
+				}
+			}
+		case WhoisChannelsReply:
+			// "<client> <nick> :[prefix]<channel>{ [prefix]<channel>}"
+			result.Channels = append(result.Channels, strings.Fields(params[len(params)-1])...)
+		case WhoisAccountReply:
+			if len(params) > 2 {
+				result.Account = params[2]
+			}
+		case WhoisSecureReply:
+			result.Secure = true
+		case AwayReply:
+			result.Away, result.AwayMessage = true, params[len(params)-1]
+		}
+	}
+	return result, nil
+}
+
+// Who queries the users matching the given mask (e.g. a channel or a nickname). All the
+// replies of the server are collected until RPL_ENDOFWHO has been received. If the server
+// supports WHOX, the query is tagged with a token of its own and the accounts of the
+// users are requested as well.
+func (conn *clientConnection) Who(ctx context.Context, mask string) ([]WhoEntry, error) {
+	conn.capMu.RLock()
+	whox := conn.isupport.Has(WhoXISupportToken)
+	casemapping := conn.isupport.Casemapping
+	prefixSymbols := conn.isupport.PrefixSymbols
+	conn.capMu.RUnlock()
+
+	var msg WhoMessage
+	var err error
+	token := ""
+	if whox {
+		token = conn.queries.nextWhoxToken()
+		msg, err = NewWhoxMessage(EmptyPrefix, mask, whoxFields, token)
+	} else if mask == "" {
+		err = fmt.Errorf("invalid mask: \"%s\"", mask)
+	} else {
+		msg, err = NewWhoMessage(EmptyPrefix, mask, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.query(ctx, msg, whoResponse(casemapping, mask, token))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]WhoEntry, 0, len(replies))
+	for _, reply := range replies {
+		params := reply.Parameters()
+		var entry WhoEntry
+		var flags string
+		switch {
+		case reply.Command() == WhoReply && len(params) > 7:
+			// "<client> <channel> <user> <host> <server> <nick> <flags> :<hopcount> <realname>"
+			entry = WhoEntry{Channel: params[1], Username: params[2], Hostname: params[3], Server: params[4], Nickname: params[5]}
+			if i := strings.IndexByte(params[7], ' '); i != -1 {
+				entry.Realname = params[7][i+1:]
+			}
+			flags = params[6]
+		case reply.Command() == WhoSpcrplReply && len(params) > 9:
+			// "<client> <token> <channel> <user> <host> <server> <nick> <flags> <account> :<realname>"
+			entry = WhoEntry{Channel: params[2], Username: params[3], Hostname: params[4], Server: params[5],
+				Nickname: params[6], Account: accountName(params[8], "0"), Realname: params[9]}
+			flags = params[7]
+		default:
+			continue
+		}
+		entry.Away = strings.HasPrefix(flags, "G")
+		entry.Operator = strings.Contains(flags, "*")
+		for _, flag := range flags {
+			if strings.ContainsRune(prefixSymbols, flag) {
+				entry.Prefixes += string(flag)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Whowas queries information about the former users of the given nickname. If count is
+// greater than zero, at most count entries are returned. If the nickname has not been in
+// use, a NickNotFoundError is returned.
+func (conn *clientConnection) Whowas(ctx context.Context, nickname string, count int) ([]WhowasEntry, error) {
+	msg, err := NewWhowasMessage(EmptyPrefix, nickname, count)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.query(ctx, msg, whowasResponse(conn.Casemapping(), nickname))
+	if err != nil {
+		return nil, err
+	}
+	var entries []WhowasEntry
+	for _, reply := range replies {
+		params := reply.Parameters()
+		switch reply.Command() {
+		case WasNoSuchNickError:
+			return nil, &NickNotFoundError{Reply: reply.Command(), Nickname: nickname, Message: params[len(params)-1]}
+		case WhowasUserReply:
+			// "<client> <nick> <username> <host> * :<realname>"
+			if len(params) > 5 {
+				entries = append(entries, WhowasEntry{Nickname: params[1], Username: params[2], Hostname: params[3], Realname: params[5]})
+			}
+		case WhoisServerReply:
+			// Servers follow each RPL_WHOWASUSER with the server the user has been using.
+			if len(params) > 3 && len(entries) > 0 {
+				entries[len(entries)-1].Server, entries[len(entries)-1].ServerInfo = params[2], params[3]
+			}
+		}
+	}
+	return entries, nil
+}
+
+// whoisResponse recognizes the replies to a WHOIS query for the given nickname.
+func whoisResponse(casemapping Casemapping, nickname string) func(msg Message) (bool, bool) {
+	key := casemapping.ToLower(nickname)
+	return func(msg Message) (bool, bool) {
+		params := msg.Parameters()
+		if len(params) < 2 || casemapping.ToLower(params[1]) != key {
+			return false, false
+		}
+		switch msg.Command() {
+		case WhoisUserReply, WhoisServerReply, WhoisOperatorReply, WhoisIdleReply, WhoisChannelsReply,
+			WhoisAccountReply, WhoisSecureReply, AwayReply, NoSuchNickError:
+			return true, false
+		case EndOfWhoisReply:
+			return true, true
+		}
+		return false, false
+	}
+}
+
+// whoResponse recognizes the replies to a WHO query for the given mask. If the query has
+// been tagged with a WHOX token, only the WHOX replies carrying the token are accepted.
+func whoResponse(casemapping Casemapping, mask string, token string) func(msg Message) (bool, bool) {
+	key := casemapping.ToLower(mask)
+	return func(msg Message) (bool, bool) {
+		params := msg.Parameters()
+		switch msg.Command() {
+		case WhoReply:
+			// Plain WHO replies cannot be correlated; they belong to the oldest query.
+			return token == "", false
+		case WhoSpcrplReply:
+			return token != "" && len(params) > 1 && params[1] == token, false
+		case EndOfWhoReply:
+			return len(params) > 1 && casemapping.ToLower(params[1]) == key, true
+		}
+		return false, false
+	}
+}
+
+// whowasResponse recognizes the replies to a WHOWAS query for the given nickname.
+func whowasResponse(casemapping Casemapping, nickname string) func(msg Message) (bool, bool) {
+	key := casemapping.ToLower(nickname)
+	return func(msg Message) (bool, bool) {
+		params := msg.Parameters()
+		if len(params) < 2 || casemapping.ToLower(params[1]) != key {
+			return false, false
+		}
+		switch msg.Command() {
+		case WhowasUserReply, WhoisServerReply, WasNoSuchNickError:
+			return true, false
+		case EndOfWhowasReply:
+			return true, true
+		}
+		return false, false
+	}
+}
@@ -0,0 +1,325 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openRegistered returns a connection that has been registered with the fake server as
+// "johndoe" and that has processed the given lines.
+func openRegistered(t *testing.T, srv *fakeServer, lines ...string) ClientConnection {
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send(append(lines, "PING sync")...)
+	srv.expect("PONG")
+	return conn
+}
+
+func TestClientConnection_Whois(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	type whoisResult struct {
+		result *WhoisResult
+		err    error
+	}
+	results := make(chan whoisResult)
+	go func() {
+		result, err := conn.Whois(context.Background(), "jane")
+		results <- whoisResult{result, err}
+	}()
+	srv.expect("WHOIS jane")
+	srv.send(":irc.example.com 311 johndoe Jane j jane.example.com * :Jane Doe",
+		":irc.example.com 319 johndoe Jane :@#test +#other",
+		":irc.example.com 319 johndoe Jane :#third",
+		":irc.example.com 312 johndoe Jane irc.example.com :Example server",
+		":irc.example.com 301 johndoe Jane :Gone fishing",
+		":irc.example.com 313 johndoe Jane :is an IRC operator",
+		":irc.example.com 330 johndoe Jane janeacc :is logged in as",
+		":irc.example.com 671 johndoe Jane :is using a secure connection",
+		":irc.example.com 317 johndoe Jane 42 1600000000 :seconds idle, signon time",
+		":irc.example.com 318 johndoe Jane :End of /WHOIS list.")
+	r := <-results
+	if r.err != nil {
+		t.Fatalf("Whois() -> %v", r.err)
+	}
+	want := &WhoisResult{
+		Nickname:    "Jane",
+		Username:    "j",
+		Hostname:    "jane.example.com",
+		Realname:    "Jane Doe",
+		Server:      "irc.example.com",
+		ServerInfo:  "Example server",
+		Operator:    true,
+		Account:     "janeacc",
+		Away:        true,
+		AwayMessage: "Gone fishing",
+		Secure:      true,
+		Idle:        42 * time.Second,
+		SignOn:      time.Unix(1600000000, 0),
+		Channels:    []string{"@#test", "+#other", "#third"},
+	}
+	if len(r.result.Replies) != 10 {
+		t.Errorf("Replies -> %d messages, expected 10", len(r.result.Replies))
+	}
+	r.result.Replies = nil
+	if !reflect.DeepEqual(r.result, want) {
+		t.Errorf("Whois() -> %+v, want %+v", r.result, want)
+	}
+}
+
+func TestClientConnection_WhoisNoSuchNick(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	errs := make(chan error)
+	go func() {
+		_, err := conn.Whois(context.Background(), "nobody")
+		errs <- err
+	}()
+	srv.expect("WHOIS nobody")
+	srv.send(":irc.example.com 401 johndoe nobody :No such nick/channel",
+		":irc.example.com 318 johndoe nobody :End of /WHOIS list.")
+	var nickErr *NickNotFoundError
+	if err := <-errs; !errors.As(err, &nickErr) || nickErr.Reply != NoSuchNickError || nickErr.Nickname != "nobody" {
+		t.Errorf("Whois() -> %v", err)
+	}
+}
+
+func TestClientConnection_WhoConcurrent(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv, ":irc.example.com 005 johndoe WHOX PREFIX=(ov)@+ :are supported by this server")
+	defer conn.Wait()
+	defer conn.Close()
+
+	type whoResult struct {
+		entries []WhoEntry
+		err     error
+	}
+	results := map[string]chan whoResult{"#a": make(chan whoResult, 1), "#b": make(chan whoResult, 1)}
+	tokens := map[string]string{}
+	for _, mask := range []string{"#a", "#b"} {
+		mask := mask
+		go func() {
+			entries, err := conn.Who(context.Background(), mask)
+			results[mask] <- whoResult{entries, err}
+		}()
+		msg := srv.expect("WHO " + mask + " %" + whoxFields + ",")
+		tokens[mask] = msg.Parameters()[1][len(whoxFields)+2:]
+	}
+	if tokens["#a"] == tokens["#b"] || !isWhoxToken(tokens["#a"]) || !isWhoxToken(tokens["#b"]) {
+		t.Fatalf("tokens -> %v", tokens)
+	}
+	srv.send(":irc.example.com 354 johndoe "+tokens["#b"]+" #b b b.example.com irc.example.com bob G 0 :Bob",
+		":irc.example.com 354 johndoe "+tokens["#a"]+" #a a a.example.com irc.example.com alice H*@ aliceacc :Alice",
+		":irc.example.com 315 johndoe #a :End of WHO list.",
+		":irc.example.com 315 johndoe #b :End of WHO list.")
+
+	a := <-results["#a"]
+	wantA := []WhoEntry{{Channel: "#a", Nickname: "alice", Username: "a", Hostname: "a.example.com", Server: "irc.example.com",
+		Account: "aliceacc", Realname: "Alice", Operator: true, Prefixes: "@"}}
+	if a.err != nil || !reflect.DeepEqual(a.entries, wantA) {
+		t.Errorf("Who(#a) -> %+v, %v", a.entries, a.err)
+	}
+	b := <-results["#b"]
+	wantB := []WhoEntry{{Channel: "#b", Nickname: "bob", Username: "b", Hostname: "b.example.com", Server: "irc.example.com",
+		Realname: "Bob", Away: true}}
+	if b.err != nil || !reflect.DeepEqual(b.entries, wantB) {
+		t.Errorf("Who(#b) -> %+v, %v", b.entries, b.err)
+	}
+}
+
+func TestClientConnection_Who(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	errs := make(chan error)
+	var entries []WhoEntry
+	go func() {
+		var err error
+		entries, err = conn.Who(context.Background(), "#test")
+		errs <- err
+	}()
+	srv.expect("WHO #test")
+	srv.send(":irc.example.com 352 johndoe #test j jane.example.com irc.example.com jane H+ :3 Jane Doe",
+		":irc.example.com 315 johndoe #TEST :End of WHO list.")
+	if err := <-errs; err != nil {
+		t.Fatalf("Who() -> %v", err)
+	}
+	want := []WhoEntry{{Channel: "#test", Nickname: "jane", Username: "j", Hostname: "jane.example.com",
+		Server: "irc.example.com", Realname: "Jane Doe", Prefixes: "+"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Who() -> %+v", entries)
+	}
+}
+
+func TestClientConnection_Whowas(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	errs := make(chan error)
+	var entries []WhowasEntry
+	go func() {
+		var err error
+		entries, err = conn.Whowas(context.Background(), "jane", 2)
+		errs <- err
+	}()
+	srv.expect("WHOWAS jane 2")
+	srv.send(":irc.example.com 314 johndoe jane j jane.example.com * :Jane Doe",
+		":irc.example.com 312 johndoe jane irc.example.com :Mon Jan 1 00:00:00 2024",
+		":irc.example.com 314 johndoe jane jd other.example.com * :Jane",
+		":irc.example.com 369 johndoe jane :End of WHOWAS")
+	if err := <-errs; err != nil {
+		t.Fatalf("Whowas() -> %v", err)
+	}
+	want := []WhowasEntry{
+		{Nickname: "jane", Username: "j", Hostname: "jane.example.com", Realname: "Jane Doe",
+			Server: "irc.example.com", ServerInfo: "Mon Jan 1 00:00:00 2024"},
+		{Nickname: "jane", Username: "jd", Hostname: "other.example.com", Realname: "Jane"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Whowas() -> %+v", entries)
+	}
+
+	go func() {
+		_, err := conn.Whowas(context.Background(), "nobody", 0)
+		errs <- err
+	}()
+	srv.expect("WHOWAS nobody")
+	srv.send(":irc.example.com 406 johndoe nobody :There was no such nickname",
+		":irc.example.com 369 johndoe nobody :End of WHOWAS")
+	var nickErr *NickNotFoundError
+	if err := <-errs; !errors.As(err, &nickErr) || nickErr.Reply != WasNoSuchNickError {
+		t.Errorf("Whowas() -> %v", err)
+	}
+}
+
+func TestClientConnection_QueryAborted(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.Whois(ctx, "jane"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Whois() -> %v, expected the context's error", err)
+	}
+	srv.expect("WHOIS jane")
+
+	// The replies to the abandoned query must not be mistaken for replies to the next one.
+	errs := make(chan error)
+	go func() {
+		_, err := conn.Whois(context.Background(), "jane")
+		errs <- err
+	}()
+	srv.expect("WHOIS jane")
+	srv.send(":irc.example.com 401 johndoe jane :No such nick/channel",
+		":irc.example.com 318 johndoe jane :End of /WHOIS list.",
+		":irc.example.com 311 johndoe jane j jane.example.com * :Jane Doe",
+		":irc.example.com 318 johndoe jane :End of /WHOIS list.")
+	if err := <-errs; err != nil {
+		t.Errorf("Whois() -> %v", err)
+	}
+
+	go func() {
+		_, err := conn.Whois(context.Background(), "jane")
+		errs <- err
+	}()
+	srv.expect("WHOIS jane")
+	srv.conn.Close()
+	if err := <-errs; err != errConnectionLost {
+		t.Errorf("Whois() -> %v, expected %v", err, errConnectionLost)
+	}
+}
+
+func TestClientConnection_QueryUnanswered(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.Whois(ctx, "jane"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Whois() -> %v, expected the context's error", err)
+	}
+	srv.expect("WHOIS jane")
+
+	// The server never responds to the first query, which is evicted once the next one
+	// has been completed.
+	errs := make(chan error)
+	go func() {
+		_, err := conn.Whois(context.Background(), "john")
+		errs <- err
+	}()
+	srv.expect("WHOIS john")
+	srv.send(":irc.example.com 311 johndoe john j john.example.com * :John Doe",
+		":irc.example.com 318 johndoe john :End of /WHOIS list.")
+	if err := <-errs; err != nil {
+		t.Errorf("Whois() -> %v", err)
+	}
+	queries := &conn.(*clientConnection).queries
+	queries.mu.Lock()
+	defer queries.mu.Unlock()
+	if len(queries.pending) != 0 {
+		t.Errorf("%d queries are still pending", len(queries.pending))
+	}
+}
+
+func TestQueryTracker_AbandonedQueryTimeout(t *testing.T) {
+	var tracker queryTracker
+	q := &query{accept: whoisResponse(CasemappingRFC1459, "jane"), done: make(chan struct{})}
+	tracker.add(q)
+	tracker.abandon(q)
+	tracker.process(NewMessage(EmptyPrefix, PingCommand, "irc.example.com"))
+	if len(tracker.pending) != 1 {
+		t.Fatal("the query should still consume its replies")
+	}
+	q.abandoned = q.abandoned.Add(-abandonedQueryTimeout - time.Second)
+	tracker.process(NewMessage(EmptyPrefix, PingCommand, "irc.example.com"))
+	if len(tracker.pending) != 0 {
+		t.Error("the query should have been evicted")
+	}
+}
+
+func TestClientConnection_QueryFromSerialHandler(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	conn.Handle(PrivmsgCommand, func(ctx context.Context, msg Message) {
+		_, err := conn.Whois(ctx, msg.Prefix().Nickname())
+		errs <- err
+	})
+	srv.send(":jane!j@jane.example.com PRIVMSG johndoe :Hi")
+	if err := <-errs; err != errSerialHandler {
+		t.Errorf("Whois() -> %v, expected %v", err, errSerialHandler)
+	}
+}
@@ -0,0 +1,309 @@
+package irc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default values used for reconnect policies that leave the respective fields empty.
+const (
+	defaultReconnectInitialDelay = 1 * time.Second
+	defaultReconnectMaxDelay     = 5 * time.Minute
+	defaultReconnectMultiplier   = 2.0
+)
+
+// ReconnectPolicy determines how (and if) a connection will be re-established once it has been lost.
+// The delay between two attempts grows exponentially, starting with InitialDelay and being
+// multiplied by Multiplier after each failed attempt, up to MaxDelay.
+type ReconnectPolicy struct {
+	// MaxAttempts limits the number of consecutive attempts. Zero means: try forever.
+	MaxAttempts int
+	// InitialDelay is the delay before the first attempt. Defaults to one second.
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between two attempts. Defaults to five minutes.
+	MaxDelay time.Duration
+	// Multiplier is applied to the delay after each failed attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by the given fraction (0.0 - 1.0) to prevent many
+	// clients from reconnecting at the very same time, e.g. after a netsplit. Values
+	// outside of this range are clamped.
+	Jitter float64
+}
+
+// WithReconnect configures the connection to be re-established automatically, if it gets lost.
+// After reconnecting, the connection will be registered again and all the channels that
+// had previously been joined will be joined again.
+func WithReconnect(policy ReconnectPolicy) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.reconnectPolicy = &policy
+	}
+}
+
+// WithServers configures additional servers, which will be tried in turn whenever
+// the connection needs to be re-established. Each attempt is made with the next server,
+// so the server whose connection has been lost is only tried again after all the others.
+func WithServers(servers ...Server) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.servers = append(conn.servers, servers...)
+	}
+}
+
+// NewClientConnectionForNetwork prepares a new connection to the given network, as configured
+// within the client preferences. The first server of the network will be used to open the
+// connection, the other servers will be used when reconnecting.
+func NewClientConnectionForNetwork(network Network, opts ...ClientConnectionOption) (conn ClientConnection, err error) {
+	if len(network.Servers) == 0 {
+		err = fmt.Errorf("network does not contain any servers")
+		return
+	}
+	primary := network.Servers[0]
+	encodingOpts, err := withNetworkEncodings(network)
+	if err != nil {
+		return
+	}
+	opts = append(append([]ClientConnectionOption{
+		func(conn *clientConnection) {
+			conn.servers = append([]Server{}, network.Servers...)
+			for i := range conn.servers {
+				if conn.servers[i].Proxy == nil {
+					conn.servers[i].Proxy = network.Proxy
+				}
+			}
+		},
+	}, encodingOpts...), opts...)
+	conn = NewClientConnection(primary.Hostname, int(primary.Port), opts...)
+	return
+}
+
+// delay computes the delay before the given (1-based) attempt.
+func (policy *ReconnectPolicy) delay(attempt int) time.Duration {
+	initial := policy.InitialDelay
+	if initial <= 0 {
+		initial = defaultReconnectInitialDelay
+	}
+	max := policy.MaxDelay
+	if max <= 0 {
+		max = defaultReconnectMaxDelay
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultReconnectMultiplier
+	}
+	d := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(max))
+	if jitter := math.Min(policy.Jitter, 1); jitter > 0 {
+		d += d * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// currentServer returns the server the connection is (or will be) established with.
+func (conn *clientConnection) currentServer() Server {
+	conn.connMu.RLock()
+	defer conn.connMu.RUnlock()
+	return conn.servers[conn.serverIndex]
+}
+
+// rotateServer selects the next server from the list of configured servers.
+func (conn *clientConnection) rotateServer() {
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+	conn.serverIndex = (conn.serverIndex + 1) % len(conn.servers)
+	conn.hostname = conn.servers[conn.serverIndex].Hostname
+	conn.port = int(conn.servers[conn.serverIndex].Port)
+}
+
+// reconnect tries to re-establish the connection according to the reconnect policy.
+// If the connection could be re-established, the channel that will be closed once the
+// new session has ended is returned, nil otherwise.
+func (conn *clientConnection) reconnect() <-chan struct{} {
+	policy := conn.reconnectPolicy
+	if policy == nil || conn.isClosing() {
+		return nil
+	}
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		conn.notifyState(ConnectionStateReconnecting)
+		select {
+		case <-time.After(policy.delay(attempt)):
+		case <-conn.closing:
+			return nil
+		}
+		conn.rotateServer()
+		ctx, cancel := conn.closingContext(conn.ctx)
+		done, err := conn.connect(ctx)
+		cancel()
+		if err == nil {
+			conn.capMu.RLock()
+			msgs := rejoinMessages(conn.isupport, conn.rejoinChannels())
+			conn.capMu.RUnlock()
+			for _, msg := range msgs {
+				conn.out <- msg
+			}
+			return done
+		}
+		if conn.isClosing() {
+			return nil
+		}
+		conn.reportError(fmt.Errorf("reconnect attempt %d failed: %v", attempt, err))
+	}
+	return nil
+}
+
+// joinedChannel is a channel that will be joined again after reconnecting.
+type joinedChannel struct {
+	name string
+	key  string // empty, unless the channel is protected by a key.
+	// rejoining is set while the server hasn't confirmed that the channel has been
+	// joined again.
+	rejoining bool
+}
+
+// trackJoinedChannels keeps track of the channels we're in (and their keys), so that they
+// can be joined again after reconnecting. Channels are forgotten once they have been left
+// or once the server has refused to join them again, in which case an error is returned.
+func (conn *clientConnection) trackJoinedChannels(msg Message) error {
+	params := msg.Parameters()
+	pfx := msg.Prefix()
+	if len(params) == 0 || pfx == nil {
+		return nil
+	}
+	conn.capMu.RLock()
+	isupport := conn.isupport
+	conn.capMu.RUnlock()
+	cm := isupport.Casemapping
+	self := cm.ToLower(conn.Nickname())
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+	switch msg.Command() {
+	case JoinCommand:
+		if cm.ToLower(pfx.Nickname()) == self {
+			key := cm.ToLower(params[0])
+			ch := &joinedChannel{name: params[0], key: conn.joinKeys[key]}
+			if prev, ok := conn.joinedChannels[key]; ok && ch.key == "" {
+				ch.key = prev.key
+			}
+			conn.joinedChannels[key] = ch
+			delete(conn.joinKeys, key)
+		}
+	case PartCommand:
+		if cm.ToLower(pfx.Nickname()) == self {
+			delete(conn.joinedChannels, cm.ToLower(params[0]))
+		}
+	case KickCommand:
+		if len(params) > 1 && cm.ToLower(params[1]) == self {
+			delete(conn.joinedChannels, cm.ToLower(params[0]))
+		}
+	case ModeCommand, ChannelModeIsReply:
+		// "MODE <channel> <modes> <args>" or "<client> <channel> <modes> <args>"
+		if msg.Command() == ChannelModeIsReply {
+			params = params[1:]
+		}
+		if len(params) < 2 {
+			return nil
+		}
+		ch, ok := conn.joinedChannels[cm.ToLower(params[0])]
+		if !ok {
+			return nil
+		}
+		changes, _ := ParseModeChanges(isupport, params[1], params[2:])
+		for _, c := range changes {
+			if c.Mode == 'k' {
+				if ch.key = ""; c.Adding {
+					ch.key = c.Arg
+				}
+			}
+		}
+	case NoSuchChannelError, TooManyChannelsError, ChannelIsFullError, InviteOnlyChanError,
+		BannedFromChanError, BadChannelKeyError, BadChanMaskError:
+		if len(params) < 2 {
+			return nil
+		}
+		key := cm.ToLower(params[1])
+		delete(conn.joinKeys, key)
+		if ch, ok := conn.joinedChannels[key]; ok && ch.rejoining {
+			delete(conn.joinedChannels, key)
+			return fmt.Errorf("channel %s could not be joined again (%s): %s", ch.name, msg.Command().Name(), params[len(params)-1])
+		}
+	}
+	return nil
+}
+
+// trackJoinKeys remembers the keys sent along with a JOIN message, so that they can be
+// used to join the channels again after reconnecting.
+func (conn *clientConnection) trackJoinKeys(msg Message) {
+	if msg.Command() != JoinCommand {
+		return
+	}
+	join, ok := NewTypedMessage(msg).(JoinMessage)
+	if !ok || len(join.Keys()) == 0 {
+		return
+	}
+	cm := conn.Casemapping()
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+	channels := join.Channels()
+	for i, key := range join.Keys() {
+		if i < len(channels) {
+			conn.joinKeys[cm.ToLower(channels[i])] = key
+		}
+	}
+}
+
+// rejoinChannels returns the channels that have been joined and marks them as being joined
+// again. They are kept until the channels have been left or the server has refused to join
+// them, so that they don't get lost if the connection is lost again before the server has
+// confirmed the JOINs.
+func (conn *clientConnection) rejoinChannels() (channels []joinedChannel) {
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+	for _, ch := range conn.joinedChannels {
+		ch.rejoining = true
+		channels = append(channels, *ch)
+	}
+	return
+}
+
+// rejoinMessages creates the JOIN messages needed to join the given channels again. The
+// server has accepted the channel names before, so they are not validated again. Keyed
+// channels come first, since the keys are matched to the channels by their positions.
+// The messages respect the maximum number of targets (TARGMAX) and the maximum length.
+func rejoinMessages(isupport *ISupport, channels []joinedChannel) (msgs []Message) {
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].key != "" && channels[j].key == ""
+	})
+	maxTargets := isupport.TargMax[JoinCommand]
+	var names, keys []string
+	length := 0
+	flush := func() {
+		if len(names) == 0 {
+			return
+		}
+		params := []string{strings.Join(names, listSeparator)}
+		if len(keys) > 0 {
+			params = append(params, strings.Join(keys, listSeparator))
+		}
+		msgs = append(msgs, NewMessageWithoutPrefix(JoinCommand, params...))
+		names, keys, length = nil, nil, 0
+	}
+	// "JOIN <channels> <keys>\r\n"
+	maxLen := MsgMaxLen - len(JoinCommand) - 2 - len(messageDelimiter)
+	for _, ch := range channels {
+		cost := len(ch.name) + 1
+		if ch.key != "" {
+			cost += len(ch.key) + 1
+		}
+		if len(names) > 0 && ((maxTargets > 0 && len(names) == maxTargets) || length+cost > maxLen) {
+			flush()
+		}
+		names = append(names, ch.name)
+		if ch.key != "" {
+			keys = append(keys, ch.key)
+		}
+		length += cost
+	}
+	flush()
+	return
+}
@@ -0,0 +1,188 @@
+package irc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registerWithFakeServer lets the fake server accept the client's registration.
+func registerWithFakeServer(srv *fakeServer, nick string) {
+	skipCapNegotiation(srv)
+	srv.expect("NICK " + nick)
+	srv.expect("USER")
+	srv.send(":irc.example.com 001 " + nick + " :Welcome")
+}
+
+func TestClientConnection_Reconnect(t *testing.T) {
+	srv1 := newFakeServer(t)
+	defer srv1.close()
+	srv2 := newFakeServer(t)
+	defer srv2.close()
+
+	conn := NewClientConnection("127.0.0.1", srv1.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithServers(Server{Hostname: "127.0.0.1", Port: uint(srv2.port())}),
+		WithReconnect(ReconnectPolicy{InitialDelay: time.Millisecond, MaxAttempts: 3}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	states := make(chan ConnectionState, 32)
+	errs := make(chan error, 32)
+	go func() {
+		for {
+			select {
+			case <-conn.In():
+			case err := <-conn.Err():
+				errs <- err
+			case s := <-conn.State():
+				states <- s
+			}
+		}
+	}()
+	result := openAsync(conn)
+	registerWithFakeServer(srv1, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	join, _ := NewJoinMessageWithKeys(EmptyPrefix, []string{"#secret"}, []string{"key"})
+	conn.Out() <- join
+	srv1.expect("JOIN #secret key")
+	srv1.send(":johndoe!johndoe@localhost JOIN #test", ":johndoe!johndoe@localhost JOIN #other",
+		":johndoe!johndoe@localhost PART #other", ":johndoe!johndoe@localhost JOIN #secret", "PING sync")
+	srv1.expect("PONG")
+
+	// Simulate a lost connection: the client should reconnect to the second server.
+	srv1.conn.Close()
+	registerWithFakeServer(srv2, "johndoe")
+	srv2.expect("JOIN #secret,#test key")
+
+	// The connection gets lost again before the JOIN has been confirmed: the channel
+	// still has to be joined once the connection has been re-established.
+	srv2.conn.Close()
+	registerWithFakeServer(srv1, "johndoe")
+	srv1.expect("JOIN #secret,#test key")
+	srv1.send(":johndoe!johndoe@localhost JOIN #secret", ":irc.example.com 474 johndoe #test :Cannot join channel (+b)")
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "#test") {
+		t.Errorf("Err() -> %v, expected the channel not to be joined again", err)
+	}
+	if channels := conn.(*clientConnection).rejoinChannels(); len(channels) != 1 || channels[0].name != "#secret" || channels[0].key != "key" {
+		t.Errorf("channels to be joined again -> %+v, expected #secret", channels)
+	}
+
+	reconnecting := false
+	timeout := time.After(fakeServerTimeout)
+	for !reconnecting {
+		select {
+		case s := <-states:
+			reconnecting = s == ConnectionStateReconnecting
+		case <-timeout:
+			t.Fatal("reconnect attempt has not been reported")
+		}
+	}
+	if conn.Port() != srv1.port() {
+		t.Errorf("Port() -> %d, expected: %d", conn.Port(), srv1.port())
+	}
+	conn.Close()
+	conn.Wait()
+}
+
+func TestClientConnection_ReconnectMaxAttempts(t *testing.T) {
+	srv := newFakeServer(t)
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithReconnect(ReconnectPolicy{InitialDelay: time.Millisecond, MaxAttempts: 2}),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	// Nobody is listening anymore, so all attempts will fail.
+	srv.close()
+
+	waited := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("connection has not been given up after exceeding the maximum number of attempts")
+	}
+	if err := conn.Open(); err == ConnectionAlreadyEstablished {
+		t.Error("connection should be able to be opened again after it has been given up")
+	}
+}
+
+func TestReconnectPolicy_Delay(t *testing.T) {
+	policy := &ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 10 * time.Second}
+	var testdata = []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+	}
+	for _, tt := range testdata {
+		if d := policy.delay(tt.attempt); d != tt.delay {
+			t.Errorf("delay(%d) -> %v, expected: %v", tt.attempt, d, tt.delay)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := policy.delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Errorf("delay(1) with jitter -> %v, expected a value between 0.5s and 1.5s", d)
+		}
+	}
+	policy.Jitter = 3
+	for i := 0; i < 100; i++ {
+		if d := policy.delay(1); d < 0 || d > 2*time.Second {
+			t.Errorf("delay(1) with excessive jitter -> %v, expected a value between 0s and 2s", d)
+		}
+	}
+}
+
+func TestNewClientConnectionForNetwork(t *testing.T) {
+	if _, err := NewClientConnectionForNetwork(Network{}); err == nil {
+		t.Error("expected network without servers to be rejected")
+	}
+	conn, err := NewClientConnectionForNetwork(Network{Servers: []Server{
+		{Hostname: "irc1.example.com", Port: 6667},
+		{Hostname: "irc2.example.com", Port: 6697, TLS: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Hostname() != "irc1.example.com" || conn.Port() != 6667 {
+		t.Errorf("unexpected primary server: %s:%d", conn.Hostname(), conn.Port())
+	}
+	c := conn.(*clientConnection)
+	c.rotateServer()
+	if srv := c.currentServer(); srv.Hostname != "irc2.example.com" || !srv.TLS {
+		t.Errorf("unexpected server after rotation: %v", srv)
+	}
+	c.rotateServer()
+	if c.Hostname() != "irc1.example.com" {
+		t.Errorf("unexpected server after second rotation: %s", c.Hostname())
+	}
+}
+
+func TestRejoinMessages(t *testing.T) {
+	long := "#" + strings.Repeat("a", 60)
+	isupport := NewISupport()
+	isupport.Update(NewMessage(nil, ISupportReply, "johndoe", "CHANTYPES=#=", "CHANNELLEN=64", "TARGMAX=JOIN:2", "are supported"))
+	msgs := rejoinMessages(isupport, []joinedChannel{{name: "=chan"}, {name: long}, {name: "#secret", key: "key"}})
+	var lines []string
+	for _, msg := range msgs {
+		lines = append(lines, msg.String())
+	}
+	if want := []string{"JOIN #secret,=chan key", "JOIN " + long}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("rejoinMessages() -> %q, expected: %q", lines, want)
+	}
+}
@@ -0,0 +1,225 @@
+package irc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// registrationTimeout defines how long Open() waits for the server to acknowledge
+// the registration of the connection (RPL_WELCOME).
+const registrationTimeout = 60 * time.Second
+
+// Registration contains the details that are used to register the connection with the
+// server (PASS, NICK and USER messages).
+type Registration struct {
+	// Nickname is the preferred nickname.
+	Nickname string
+	// AlternateNicknames will be tried in the given order, if the preferred nickname
+	// is already in use or if the server refuses to accept it.
+	AlternateNicknames []string
+	// Username is sent within the USER message. Defaults to the nickname if empty.
+	Username string
+	// Realname is sent within the USER message. Defaults to the nickname if empty.
+	Realname string
+	// Password is sent using the PASS message, if it is not empty.
+	Password string
+	// Modes contains the user modes that shall be set once the registration has been completed.
+	// The modes i and w are requested within the USER message, the others using MODE.
+	Modes UserModes
+}
+
+// RegistrationError is returned by Open(), if the server refuses to register the connection.
+type RegistrationError struct {
+	// Reply is the numeric reply (or the ERROR command) that has been sent by the server.
+	Reply Command
+	// Message contains the text that has been sent by the server.
+	Message string
+}
+
+func (e *RegistrationError) Error() string {
+	return fmt.Sprintf("registration failed (%s): %s", e.Reply, e.Message)
+}
+
+// WithRegistration configures the connection to register itself automatically once the
+// connection has been established. Open() will not return before the registration has
+// been completed or has failed.
+func WithRegistration(reg Registration) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.registration = &reg
+	}
+}
+
+// validate checks the configured registration details for obvious mistakes.
+func (reg *Registration) validate() error {
+	for _, nick := range reg.nicknames() {
+		if !isValidNickname(nick) {
+			return fmt.Errorf("invalid nickname: \"%s\"", nick)
+		}
+	}
+	if err := validateUsername(reg.username()); err != nil {
+		return err
+	}
+	return validateParameter("realname", reg.realname())
+}
+
+// nicknames lists the preferred and all alternate nicknames in the order they will be tried.
+func (reg *Registration) nicknames() []string {
+	return append([]string{reg.Nickname}, reg.AlternateNicknames...)
+}
+
+func (reg *Registration) username() string {
+	if reg.Username == "" {
+		return reg.Nickname
+	}
+	return reg.Username
+}
+
+func (reg *Registration) realname() string {
+	if reg.Realname == "" {
+		return reg.Nickname
+	}
+	return reg.Realname
+}
+
+// registrationProgress tracks the registration of a connection.
+type registrationProgress struct {
+	mu        sync.Mutex
+	nickIndex int // index of the nickname that is currently being tried
+	finished  bool
+	err       error
+	done      chan struct{}
+}
+
+func newRegistrationProgress() *registrationProgress {
+	return &registrationProgress{
+		done: make(chan struct{}),
+	}
+}
+
+// finish marks the registration as completed (or failed, if err is non-nil).
+func (p *registrationProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.err = err
+	close(p.done)
+}
+
+// isFinished checks if the registration is over.
+func (p *registrationProgress) isFinished() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.finished
+}
+
+// wait blocks until the registration has been finished or the timeout has elapsed.
+func (p *registrationProgress) wait(timeout time.Duration) error {
+	select {
+	case <-p.done:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.err
+	case <-time.After(timeout):
+		return fmt.Errorf("registration timed out after %v", timeout)
+	}
+}
+
+// register sends the PASS, NICK and USER messages.
+func (conn *clientConnection) register() {
+	reg := conn.registration
+	if reg.Password != "" {
+		conn.out <- NewPassMessage(EmptyPrefix, reg.Password)
+	}
+	conn.setNickname(reg.Nickname)
+	conn.sendNickname(reg.Nickname)
+	var modes UserModes
+	for _, m := range reg.Modes {
+		if m.hasNumeric() {
+			modes = append(modes, m)
+		}
+	}
+	// The username and the realname have already been validated by Open().
+	if msg, err := NewUserMessage(EmptyPrefix, reg.username(), reg.realname(), modes...); err == nil {
+		conn.out <- msg
+	}
+}
+
+// sendNickname sends a NICK message. The nicknames have already been validated by Open().
+func (conn *clientConnection) sendNickname(nickname string) {
+	if msg, err := NewNickMessage(EmptyPrefix, nickname); err == nil {
+		conn.out <- msg
+	}
+}
+
+// handleRegistrationReply processes the replies that the server sends in response to
+// the registration attempt.
+func (conn *clientConnection) handleRegistrationReply(msg Message) {
+	p := conn.registrationProgress
+	if p == nil || p.isFinished() {
+		return
+	}
+	params := msg.Parameters()
+	text := ""
+	if len(params) > 0 {
+		text = params[len(params)-1]
+	}
+	switch msg.Command() {
+	case WelcomeReply:
+		if len(params) > 0 {
+			conn.setNickname(params[0])
+		}
+		// The modes that are part of the USER bitmask have already been requested.
+		var modes UserModes
+		for _, m := range conn.registration.Modes {
+			if !m.hasNumeric() {
+				modes = append(modes, m)
+			}
+		}
+		if len(modes) > 0 {
+			conn.out <- NewMessageWithoutPrefix(ModeCommand, conn.Nickname(), "+"+modes.String())
+		}
+		p.finish(nil)
+	case NicknameInUseError, ErroneousNicknameError, UnavailableResourceError:
+		nicks := conn.registration.nicknames()
+		p.mu.Lock()
+		p.nickIndex++
+		next := p.nickIndex
+		p.mu.Unlock()
+		if next >= len(nicks) {
+			p.finish(&RegistrationError{Reply: msg.Command(), Message: text})
+			return
+		}
+		conn.setNickname(nicks[next])
+		conn.sendNickname(nicks[next])
+	case PasswordMismatchError, YoureBannedCreepError, ErrorCommand:
+		p.finish(&RegistrationError{Reply: msg.Command(), Message: text})
+	}
+}
+
+// Nickname returns the nickname that is currently being used by the client.
+func (conn *clientConnection) Nickname() string {
+	conn.nickMu.RLock()
+	defer conn.nickMu.RUnlock()
+	return conn.nickname
+}
+
+func (conn *clientConnection) setNickname(nickname string) {
+	conn.nickMu.Lock()
+	defer conn.nickMu.Unlock()
+	conn.nickname = nickname
+}
+
+// handleNickMessage keeps track of our own nickname, if it has been changed.
+func (conn *clientConnection) handleNickMessage(msg Message) {
+	pfx := msg.Prefix()
+	if pfx == nil || len(msg.Parameters()) == 0 {
+		return
+	}
+	if nick := conn.Nickname(); nick != "" && conn.Casemapping().Equal(pfx.Nickname(), nick) {
+		conn.setNickname(msg.Parameters()[0])
+	}
+}
@@ -0,0 +1,108 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+)
+
+// skipCapNegotiation lets the fake server pretend that it doesn't support capabilities.
+func skipCapNegotiation(srv *fakeServer) {
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(":irc.example.com 421 * CAP :Unknown command")
+}
+
+func TestClientConnection_Registration(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{
+		Nickname: "JohnDoe",
+		Username: "jdoe",
+		Realname: "John Doe",
+		Password: "secret",
+		Modes:    UserModes{UserModeInvisible, UserModeReceiptForServerNotices},
+	}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	skipCapNegotiation(srv)
+	srv.expect("PASS secret")
+	srv.expect("NICK JohnDoe")
+	srv.expect("USER jdoe 8 * :John Doe")
+	srv.send(":irc.example.com 001 JohnDoe :Welcome to the Internet Relay Network JohnDoe!jdoe@localhost")
+	srv.expect("MODE JohnDoe +s")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	if nick := conn.Nickname(); nick != "JohnDoe" {
+		t.Errorf(`Nickname() -> "%s", expected: "JohnDoe"`, nick)
+	}
+
+	srv.send(":JohnDoe!jdoe@localhost NICK JohnDoe_", "PING sync")
+	srv.expect("PONG")
+	if nick := conn.Nickname(); nick != "JohnDoe_" {
+		t.Errorf(`Nickname() -> "%s", expected: "JohnDoe_"`, nick)
+	}
+}
+
+func TestClientConnection_RegistrationAlternateNickname(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{
+		Nickname:           "johndoe",
+		AlternateNicknames: []string{"johndoe_", "john-doe"},
+	}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	skipCapNegotiation(srv)
+	srv.expect("NICK johndoe")
+	srv.expect("USER johndoe 0 * johndoe")
+	srv.send(":irc.example.com 433 * johndoe :Nickname is already in use")
+	srv.expect("NICK johndoe_")
+	srv.send(":irc.example.com 432 * johndoe_ :Erroneous nickname")
+	srv.expect("NICK john-doe")
+	srv.send(":irc.example.com 001 john-doe :Welcome")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	if nick := conn.Nickname(); nick != "john-doe" {
+		t.Errorf(`Nickname() -> "%s", expected: "john-doe"`, nick)
+	}
+}
+
+func TestClientConnection_RegistrationFailure(t *testing.T) {
+	var testdata = []struct {
+		replies []string
+		reply   Command
+	}{
+		{[]string{":irc.example.com 433 * johndoe :Nickname is already in use"}, NicknameInUseError},
+		{[]string{":irc.example.com 464 * :Password incorrect"}, PasswordMismatchError},
+		{[]string{"ERROR :Closing Link: localhost (K-Lined)"}, ErrorCommand},
+	}
+	for _, tt := range testdata {
+		srv := newFakeServer(t)
+		conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+		drainConnection(conn)
+		result := openAsync(conn)
+
+		skipCapNegotiation(srv)
+		srv.expect("NICK johndoe")
+		srv.expect("USER")
+		srv.send(tt.replies...)
+		var regErr *RegistrationError
+		if err := <-result; !errors.As(err, &regErr) {
+			t.Errorf("Open() -> %v, expected a RegistrationError", err)
+		} else if regErr.Reply != tt.reply {
+			t.Errorf("RegistrationError.Reply -> %s, expected: %s", regErr.Reply, tt.reply)
+		}
+		srv.close()
+	}
+}
+
+func TestClientConnection_RegistrationInvalidNickname(t *testing.T) {
+	conn := NewClientConnection("127.0.0.1", DefaultServerPort, WithRegistration(Registration{Nickname: "John Doe"}))
+	if err := conn.Open(); err == nil {
+		t.Error("expected invalid nickname to be rejected")
+	}
+}
@@ -0,0 +1,238 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultRequestTimeout is the time Request() and the queries wait for the response of
+// the server by default.
+var defaultRequestTimeout = 30 * time.Second
+
+// WithRequestTimeout configures how long Request(), Whois(), Who() and Whowas() wait for
+// the response of the server, unless their context is done earlier. Defaults to thirty
+// seconds; a timeout of zero disables it.
+func WithRequestTimeout(timeout time.Duration) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.requestTimeout = timeout
+	}
+}
+
+// ReplyError is returned by Request(), if the server has responded with an error reply
+// or has asked to try again later (RPL_TRYAGAIN).
+type ReplyError struct {
+	// Reply is the numeric error reply that has been sent by the server.
+	Reply Command
+	// Message contains the text that has been sent by the server.
+	Message string
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("request failed (%s): %s", e.Reply.Name(), e.Message)
+}
+
+// Request sends the given message and waits for the response of the server. If the
+// labeled-response and batch capabilities have been negotiated (see WithCapabilities()),
+// the message is labeled and all the messages that carry the label are returned
+// (without the framing labeled-response batch, if any). Otherwise the response is
+// recognized using the numerics that the server is known to reply with. This works for
+// channel and user MODE queries, list queries (e.g. "MODE #channel +b"), LIST, NAMES and
+// TOPIC queries for a single channel, "MONITOR L", WHOIS, WHO and WHOWAS; an error is
+// returned for all other messages. If the response contains an error reply or
+// RPL_TRYAGAIN, a ReplyError is returned along with the response.
+// The replies are delivered to the handlers and the In() channel as usual.
+func (conn *clientConnection) Request(ctx context.Context, msg Message) ([]Message, error) {
+	var accept func(msg Message) (bool, bool)
+	if !conn.HasCapability(LabeledResponse) || !conn.HasCapability(Batch) {
+		conn.capMu.RLock()
+		accept = responseMatcher(conn.isupport, msg)
+		conn.capMu.RUnlock()
+		if accept == nil {
+			return nil, fmt.Errorf("responses to %s cannot be recognized without labeled-response", msg.Command())
+		}
+	}
+	replies, err := conn.query(ctx, msg, accept)
+	if err != nil {
+		return replies, err
+	}
+	for _, reply := range replies {
+		if reply.Command().IsErrorReply() || reply.Command() == TryAgainReply {
+			params := reply.Parameters()
+			return replies, &ReplyError{Reply: reply.Command(), Message: params[len(params)-1]}
+		}
+	}
+	return replies, nil
+}
+
+// labeledResponse correlates the response to a labeled request. The server either labels
+// a single message (ACK, if there is nothing to respond with) or opens a labeled batch,
+// which may contain further batches.
+type labeledResponse struct {
+	label   string
+	outer   string          // reference tag of the labeled batch (if any).
+	batches map[string]bool // reference tags of the labeled batch and its nested batches.
+}
+
+// labelMessage returns a copy of the given message that carries the label.
+func (r *labeledResponse) labelMessage(msg Message) Message {
+	tags := Tags{LabelMessageTag: r.label}
+	for k, v := range msg.Tags() {
+		if k != LabelMessageTag {
+			tags[k] = v
+		}
+	}
+	return NewMessageWithTags(tags, msg.Prefix(), msg.Command(), msg.Parameters()...)
+}
+
+func (r *labeledResponse) accept(msg Message) (bool, bool) {
+	ref := ""
+	if msg.Command() == BatchCommand && len(msg.Parameters()) > 0 {
+		ref = msg.Parameters()[0]
+	}
+	if label, ok := msg.Tags().Get(LabelMessageTag); ok && label == r.label {
+		if strings.HasPrefix(ref, "+") {
+			r.outer = ref[1:]
+			r.batches[r.outer] = true
+			return true, false
+		}
+		return true, true
+	}
+	if batch, ok := msg.Tags().Get(BatchMessageTag); ok && r.batches[batch] {
+		if strings.HasPrefix(ref, "+") {
+			r.batches[ref[1:]] = true
+		}
+		return true, false
+	}
+	if strings.HasPrefix(ref, "-") && r.batches[ref[1:]] {
+		return true, ref[1:] == r.outer
+	}
+	return false, false
+}
+
+// strip removes the ACK and the framing labeled batch from the given replies.
+func (r *labeledResponse) strip(replies []Message) []Message {
+	stripped := make([]Message, 0, len(replies))
+	for _, reply := range replies {
+		if reply.Command() == AckCommand {
+			continue
+		}
+		if reply.Command() == BatchCommand && r.outer != "" && len(reply.Parameters()) > 0 {
+			if ref := reply.Parameters()[0]; ref == "+"+r.outer || ref == "-"+r.outer {
+				continue
+			}
+		}
+		stripped = append(stripped, reply)
+	}
+	return stripped
+}
+
+// responsePattern recognizes the numerics the server responds with to a command that
+// refers to a single target (e.g. a channel).
+type responsePattern struct {
+	casemapping Casemapping
+	command     Command
+	target      string // lowercase; empty if the replies don't refer to a target.
+	// replies contains the numerics of the response, the ones that end the response are
+	// mapped to true.
+	replies map[Command]bool
+}
+
+// accept accepts the numerics of the response that refer to the target, as well as
+// error replies that refer to the target or to the command and RPL_TRYAGAIN for the
+// command (e.g. if LIST is rate limited). Error replies and RPL_TRYAGAIN end the response.
+func (p *responsePattern) accept(msg Message) (bool, bool) {
+	params := msg.Parameters()
+	if last, ok := p.replies[msg.Command()]; ok {
+		return p.refersToTarget(params), last
+	}
+	if msg.Command() == TryAgainReply && len(params) > 1 && Command(strings.ToUpper(params[1])) == p.command {
+		return true, true
+	}
+	if msg.Command().IsErrorReply() && len(params) > 2 {
+		if Command(strings.ToUpper(params[1])) == p.command || (p.target != "" && p.refersToTarget(params)) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// refersToTarget checks if one of the parameters (other than the client and the trailing
+// parameter) is the target.
+func (p *responsePattern) refersToTarget(params []string) bool {
+	if p.target == "" {
+		return true
+	}
+	for i := 1; i < len(params)-1; i++ {
+		if p.casemapping.ToLower(params[i]) == p.target {
+			return true
+		}
+	}
+	return false
+}
+
+// responseMatcher returns a function that recognizes the response of the server to the
+// given message. Nil is returned if the response cannot be recognized.
+func responseMatcher(isupport *ISupport, msg Message) func(msg Message) (bool, bool) {
+	cm := isupport.Casemapping
+	params := msg.Parameters()
+	pattern := func(target string, replies map[Command]bool) func(msg Message) (bool, bool) {
+		p := &responsePattern{casemapping: cm, command: msg.Command(), target: cm.ToLower(target), replies: replies}
+		return p.accept
+	}
+	switch msg.Command() {
+	case ModeCommand:
+		if len(params) == 1 && isupport.IsChannelName(params[0]) {
+			// Sent along with RPL_CREATIONTIME, which follows RPL_CHANNELMODEIS.
+			return pattern(params[0], map[Command]bool{ChannelModeIsReply: true})
+		}
+		if len(params) == 1 {
+			return pattern("", map[Command]bool{UModeIsReply: true})
+		}
+		if len(params) == 2 && isupport.IsChannelName(params[0]) {
+			switch strings.TrimPrefix(params[1], "+") {
+			case "b":
+				return pattern(params[0], map[Command]bool{BanListReply: false, EndOfBanListReply: true})
+			case "e":
+				return pattern(params[0], map[Command]bool{ExceptListReply: false, EndOfExceptListReply: true})
+			case "I":
+				return pattern(params[0], map[Command]bool{InviteListReply: false, EndOfInviteListReply: true})
+			}
+		}
+	case ListCommand:
+		return pattern("", map[Command]bool{ListStartReply: false, ListReply: false, ListEndReply: true})
+	case NamesCommand:
+		if len(params) == 1 && !strings.Contains(params[0], ",") {
+			return pattern(params[0], map[Command]bool{NamesReply: false, EndOfNamesReply: true})
+		}
+	case TopicCommand:
+		if len(params) == 1 {
+			// RPL_TOPICWHOTIME, which may follow RPL_TOPIC, is not part of the response.
+			return pattern(params[0], map[Command]bool{NoTopicReply: true, TopicReply: true})
+		}
+	case MonitorCommand:
+		if len(params) == 1 && strings.EqualFold(params[0], "L") {
+			return pattern("", map[Command]bool{MonListReply: false, EndOfMonListReply: true})
+		}
+	case WhoisCommand:
+		if len(params) > 0 {
+			return whoisResponse(cm, params[len(params)-1])
+		}
+	case WhoCommand:
+		if len(params) > 0 {
+			token := ""
+			if len(params) > 1 {
+				if i := strings.IndexByte(params[1], ','); i != -1 && strings.HasPrefix(params[1], "%") {
+					token = params[1][i+1:]
+				}
+			}
+			return whoResponse(cm, params[0], token)
+		}
+	case WhowasCommand:
+		if len(params) > 0 {
+			return whowasResponse(cm, params[0])
+		}
+	}
+	return nil
+}
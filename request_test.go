@@ -0,0 +1,218 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type requestResult struct {
+	replies []Message
+	err     error
+}
+
+// requestAsync sends the request from another goroutine.
+func requestAsync(conn ClientConnection, msg Message) <-chan requestResult {
+	result := make(chan requestResult, 1)
+	go func() {
+		replies, err := conn.Request(context.Background(), msg)
+		result <- requestResult{replies, err}
+	}()
+	return result
+}
+
+// expectLabeled reads the next message sent by the client, which must carry a label and
+// start with the given string (apart from the tags), and returns the label.
+func expectLabeled(t *testing.T, srv *fakeServer, str string) string {
+	msg := srv.receive()
+	label, ok := msg.Tags().Get(LabelMessageTag)
+	if !ok {
+		t.Fatalf("the request %q should carry a label", msg)
+	}
+	if untagged := NewMessage(msg.Prefix(), msg.Command(), msg.Parameters()...); !strings.HasPrefix(untagged.String(), str) {
+		t.Fatalf(`fake server expected "%s", but received "%s"`, str, msg)
+	}
+	return label
+}
+
+// commands returns the commands of the given messages.
+func commands(msgs []Message) (cmds []Command) {
+	for _, msg := range msgs {
+		cmds = append(cmds, msg.Command())
+	}
+	return
+}
+
+func TestClientConnection_RequestLabeled(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithCapabilities(LabeledResponse, Batch), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(":irc.example.com CAP * LS :batch labeled-response")
+	req := srv.expect("CAP REQ")
+	srv.send(":irc.example.com CAP * ACK :" + req.Parameters()[1])
+	srv.expect("CAP END")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Wait()
+	defer conn.Close()
+
+	// A labeled batch, containing a nested batch.
+	names := requestAsync(conn, NewMessageWithoutPrefix(NamesCommand, "#test"))
+	label := expectLabeled(t, srv, "NAMES #test")
+	srv.send("@label="+label+" :irc.example.com BATCH +outer labeled-response",
+		":jane!j@localhost PRIVMSG #test :unrelated",
+		"@batch=outer :irc.example.com BATCH +inner example",
+		"@batch=inner :irc.example.com 353 johndoe = #test :jane johndoe",
+		":irc.example.com BATCH -inner",
+		"@batch=outer :irc.example.com 366 johndoe #test :End of /NAMES list.",
+		":irc.example.com BATCH -outer")
+	r := <-names
+	want := []Command{BatchCommand, NamesReply, BatchCommand, EndOfNamesReply}
+	if r.err != nil || len(r.replies) != len(want) {
+		t.Fatalf("Request() -> %v, %v", commands(r.replies), r.err)
+	}
+	for i, cmd := range commands(r.replies) {
+		if cmd != want[i] {
+			t.Errorf("Request() -> %v, expected %v", commands(r.replies), want)
+			break
+		}
+	}
+
+	// A single labeled reply, which happens to be an error.
+	mode := requestAsync(conn, NewMessageWithoutPrefix(ModeCommand, "#nope"))
+	label = expectLabeled(t, srv, "MODE #nope")
+	srv.send("@label=" + label + " :irc.example.com 403 johndoe #nope :No such channel")
+	r = <-mode
+	var replyErr *ReplyError
+	if !errors.As(r.err, &replyErr) || replyErr.Reply != NoSuchChannelError || len(r.replies) != 1 {
+		t.Errorf("Request() -> %v, %v", r.replies, r.err)
+	}
+
+	// No response at all.
+	away := requestAsync(conn, NewMessageWithoutPrefix(AwayCommand))
+	label = expectLabeled(t, srv, "AWAY")
+	srv.send("@label=" + label + " :irc.example.com ACK")
+	if r = <-away; r.err != nil || len(r.replies) != 0 {
+		t.Errorf("Request() -> %v, %v", r.replies, r.err)
+	}
+}
+
+func TestClientConnection_RequestHeuristic(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := openRegistered(t, srv)
+	defer conn.Wait()
+	defer conn.Close()
+
+	if _, err := conn.Request(context.Background(), NewMessageWithoutPrefix(PrivmsgCommand, "#test", "Hi")); err == nil {
+		t.Error("responses to PRIVMSG should not be recognized")
+	}
+
+	bans := requestAsync(conn, NewMessageWithoutPrefix(ModeCommand, "#test", "+b"))
+	srv.expect("MODE #test +b")
+	topic := requestAsync(conn, NewMessageWithoutPrefix(TopicCommand, "#other"))
+	srv.expect("TOPIC #other")
+	list := requestAsync(conn, NewMessageWithoutPrefix(ModeCommand, "#nope", "b"))
+	srv.expect("MODE #nope b")
+	srv.send(":irc.example.com 367 johndoe #test *!*@spam.example.com op 1600000000",
+		":irc.example.com 367 johndoe #test *!*@evil.example.com op 1600000000",
+		":irc.example.com 368 johndoe #test :End of channel ban list",
+		":irc.example.com 332 johndoe #other :The topic",
+		":irc.example.com 333 johndoe #other op 1600000000",
+		":irc.example.com 403 johndoe #nope :No such channel")
+
+	if r := <-bans; r.err != nil || len(r.replies) != 3 {
+		t.Errorf("Request(MODE +b) -> %v, %v", commands(r.replies), r.err)
+	}
+	if r := <-topic; r.err != nil || len(r.replies) != 1 || r.replies[0].Command() != TopicReply {
+		t.Errorf("Request(TOPIC) -> %v, %v", commands(r.replies), r.err)
+	}
+	var replyErr *ReplyError
+	if r := <-list; !errors.As(r.err, &replyErr) || replyErr.Reply != NoSuchChannelError {
+		t.Errorf("Request(MODE b) -> %v, %v", commands(r.replies), r.err)
+	}
+
+	// Servers may refuse to send the channel list too often.
+	channels := requestAsync(conn, NewMessageWithoutPrefix(ListCommand))
+	srv.expect("LIST")
+	srv.send(":irc.example.com 263 johndoe LIST :Server load is temporarily too heavy. Please wait a while and try again.")
+	if r := <-channels; !errors.As(r.err, &replyErr) || replyErr.Reply != TryAgainReply || len(r.replies) != 1 {
+		t.Errorf("Request(LIST) -> %v, %v", commands(r.replies), r.err)
+	}
+}
+
+func TestClientConnection_RequestTimeout(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}), WithRequestTimeout(50*time.Millisecond),
+		WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Wait()
+	defer conn.Close()
+
+	if _, err := conn.Request(context.Background(), NewMessageWithoutPrefix(ListCommand)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request() -> %v, expected a timeout", err)
+	}
+}
+
+func TestResponseMatcher(t *testing.T) {
+	var testData = []struct {
+		request string
+		replies []string
+		// accepted contains the number of accepted replies, the last one must end the response.
+		accepted int
+	}{
+		{"MODE #test", []string{":s 324 me #test +nt", ":s 329 me #test 1600000000"}, 1},
+		{"MODE me", []string{":s 221 me +iw"}, 1},
+		{"MODE #test +e", []string{":s 348 me #other x!*@*", ":s 348 me #test y!*@*", ":s 349 me #test :End"}, 2},
+		{"NAMES #test", []string{":s 353 me = #test :me", ":s 366 me #TEST :End"}, 2},
+		{"LIST", []string{":s 321 me Channel :Users Name", ":s 322 me #test 2 :Topic", ":s 323 me :End"}, 3},
+		{"LIST", []string{":s 263 me WHO :Please wait", ":s 263 me LIST :Please wait"}, 1},
+		{"MONITOR L", []string{":s 732 me :jane,john", ":s 733 me :End of MONITOR list"}, 2},
+		{"WHO #test %tn,42", []string{":s 354 me 41 jane", ":s 354 me 42 john", ":s 315 me #test :End"}, 2},
+		{"WHOIS jane", []string{":s 401 me jane :No such nick", ":s 318 me jane :End"}, 2},
+		{"NAMES #nope", []string{":s 403 me #nope :No such channel"}, 1},
+		{"MODE #test", []string{":s 461 me MODE :Not enough parameters"}, 1},
+	}
+	for _, td := range testData {
+		req, _ := NewMessageFromString(td.request)
+		accept := responseMatcher(NewISupport(), req)
+		if accept == nil {
+			t.Errorf("%s: the response should be recognized", td.request)
+			continue
+		}
+		accepted, last := 0, false
+		for _, raw := range td.replies {
+			msg, _ := NewMessageFromString(raw)
+			var reply bool
+			if reply, last = accept(msg); reply {
+				accepted++
+			}
+			if reply && last {
+				break
+			}
+		}
+		if accepted != td.accepted || !last {
+			t.Errorf("%s: %d replies accepted (last: %v), expected %d", td.request, accepted, last, td.accepted)
+		}
+	}
+	for _, raw := range []string{"PRIVMSG #test :Hi", "NAMES #a,#b", "MODE #test +o jane", "MONITOR + jane"} {
+		req, _ := NewMessageFromString(raw)
+		if responseMatcher(NewISupport(), req) != nil {
+			t.Errorf("%s: the response should not be recognized", raw)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package irc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// saslChunkSize is the maximum length of the (base64-encoded) payload that may be
+// transmitted within a single AUTHENTICATE message.
+const saslChunkSize = 400
+
+// saslEmptyPayload is sent as AUTHENTICATE parameter to transmit an empty payload
+// or to indicate the end of a payload whose length is a multiple of saslChunkSize.
+const saslEmptyPayload = "+"
+
+// saslAbort is sent as AUTHENTICATE parameter by the client to abort the authentication.
+const saslAbort = "*"
+
+// SASLMechanism implements the client side of a SASL authentication mechanism.
+type SASLMechanism interface {
+	// Name returns the name of the mechanism as registered with IANA (e.g. "PLAIN").
+	Name() string
+	// Next computes the response to a challenge that has been sent by the server.
+	// The first challenge sent by the server is usually empty.
+	Next(challenge []byte) (response []byte, err error)
+}
+
+// SASLError is returned by Open(), if the SASL authentication has not been successful.
+type SASLError struct {
+	// Reply is the numeric reply that has been sent by the server to indicate the failure.
+	// It is empty if the authentication failed on the client side.
+	Reply Command
+	// Mechanism is the name of the mechanism that has been used.
+	Mechanism string
+	// Mechanisms contains the mechanisms supported by the server, if the server told us about them.
+	Mechanisms []string
+	// Message describes the failure.
+	Message string
+}
+
+func (e *SASLError) Error() string {
+	str := fmt.Sprintf("SASL authentication using %s failed", e.Mechanism)
+	if e.Reply != "" {
+		str += fmt.Sprintf(" (%s)", e.Reply)
+	}
+	if e.Message != "" {
+		str += ": " + e.Message
+	}
+	if len(e.Mechanisms) > 0 {
+		str += fmt.Sprintf(" (available mechanisms: %s)", strings.Join(e.Mechanisms, ", "))
+	}
+	return str
+}
+
+// WithSASL configures the connection to authenticate using the given SASL mechanism.
+// The "sasl" capability will be requested automatically. If the server does not support
+// SASL or if the authentication fails, Open() reports an error.
+func WithSASL(mechanism SASLMechanism) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.saslMechanism = mechanism
+		conn.wantedCapabilities = append(conn.wantedCapabilities, SASL)
+	}
+}
+
+// saslAuthentication tracks the progress of a SASL authentication.
+type saslAuthentication struct {
+	mechanism  SASLMechanism
+	started    bool
+	challenge  strings.Builder // base64-encoded challenge, assembled from several chunks.
+	mechanisms []string
+}
+
+// startSASLAuthentication initiates the SASL authentication, after the capability
+// negotiation has taken place. Calling the method more than once has no effect.
+func (conn *clientConnection) startSASLAuthentication() {
+	auth := conn.saslAuth
+	if auth.started {
+		return
+	}
+	auth.started = true
+	name := auth.mechanism.Name()
+	if !conn.HasCapability(SASL) {
+		conn.abortCapNegotiation(&SASLError{Mechanism: name, Message: "the server does not support SASL"})
+		return
+	}
+	if v, _ := conn.CapabilityValue(SASL); v != "" {
+		auth.mechanisms = strings.Split(v, ",")
+		if !containsString(auth.mechanisms, name) {
+			conn.abortCapNegotiation(&SASLError{Mechanism: name, Mechanisms: auth.mechanisms, Message: "mechanism not supported by the server"})
+			return
+		}
+	}
+	conn.out <- NewMessageWithoutPrefix(AuthenticateCommand, name)
+}
+
+// handleAuthenticateMessage processes the challenges that are sent by the server.
+func (conn *clientConnection) handleAuthenticateMessage(msg Message) {
+	auth := conn.saslAuth
+	if auth == nil || !auth.started || len(msg.Parameters()) == 0 {
+		return
+	}
+	chunk := msg.Parameters()[0]
+	if chunk != saslEmptyPayload {
+		auth.challenge.WriteString(chunk)
+	}
+	if len(chunk) == saslChunkSize {
+		// More chunks will follow.
+		return
+	}
+	encoded := auth.challenge.String()
+	auth.challenge.Reset()
+
+	challenge, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		conn.failSASLAuthentication(fmt.Sprintf("invalid challenge: %v", err))
+		return
+	}
+	response, err := auth.mechanism.Next(challenge)
+	if err != nil {
+		conn.failSASLAuthentication(err.Error())
+		return
+	}
+	for _, c := range chunkSASLPayload(base64.StdEncoding.EncodeToString(response)) {
+		conn.out <- NewMessageWithoutPrefix(AuthenticateCommand, c)
+	}
+}
+
+// failSASLAuthentication aborts an authentication due to a client-side problem.
+func (conn *clientConnection) failSASLAuthentication(reason string) {
+	conn.out <- NewMessageWithoutPrefix(AuthenticateCommand, saslAbort)
+	conn.abortCapNegotiation(&SASLError{Mechanism: conn.saslAuth.mechanism.Name(), Message: reason})
+}
+
+// handleSASLReply processes the numeric replies 900 to 908.
+func (conn *clientConnection) handleSASLReply(msg Message) {
+	auth := conn.saslAuth
+	if auth == nil || !auth.started {
+		return
+	}
+	params := msg.Parameters()
+	text := ""
+	if len(params) > 0 {
+		text = params[len(params)-1]
+	}
+	switch msg.Command() {
+	case SASLSuccessReply, SASLAlreadyError:
+		conn.endCapNegotiation(nil)
+	case SASLMechsReply:
+		if len(params) > 1 {
+			auth.mechanisms = strings.Split(params[1], ",")
+		}
+	case SASLFailError, SASLTooLongError, SASLAbortedError, NickLockedError:
+		conn.abortCapNegotiation(&SASLError{
+			Reply:      msg.Command(),
+			Mechanism:  auth.mechanism.Name(),
+			Mechanisms: auth.mechanisms,
+			Message:    text,
+		})
+	}
+}
+
+// chunkSASLPayload splits an encoded payload into chunks that can be sent using AUTHENTICATE.
+func chunkSASLPayload(payload string) (chunks []string) {
+	for len(payload) >= saslChunkSize {
+		chunks = append(chunks, payload[:saslChunkSize])
+		payload = payload[saslChunkSize:]
+	}
+	if payload == "" {
+		payload = saslEmptyPayload
+	}
+	chunks = append(chunks, payload)
+	return
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+type saslPlain struct {
+	authzid  string
+	username string
+	password string
+}
+
+// NewSASLPlain creates the "PLAIN" mechanism (RfC-4616), which transmits the username
+// and the password in clear text. It should only be used over encrypted connections.
+// The authorization identity authzid is usually left empty.
+func NewSASLPlain(authzid string, username string, password string) SASLMechanism {
+	return &saslPlain{
+		authzid:  authzid,
+		username: username,
+		password: password,
+	}
+}
+
+func (m *saslPlain) Name() string {
+	return "PLAIN"
+}
+
+func (m *saslPlain) Next(challenge []byte) ([]byte, error) {
+	return []byte(m.authzid + "\x00" + m.username + "\x00" + m.password), nil
+}
+
+type saslExternal struct {
+	authzid string
+}
+
+// NewSASLExternal creates the "EXTERNAL" mechanism (RfC-4422), which relies on credentials
+// that have been established outside of SASL, usually the client certificate that has been
+// presented during the TLS handshake (CertFP). The authorization identity authzid is usually
+// left empty.
+func NewSASLExternal(authzid string) SASLMechanism {
+	return &saslExternal{
+		authzid: authzid,
+	}
+}
+
+func (m *saslExternal) Name() string {
+	return "EXTERNAL"
+}
+
+func (m *saslExternal) Next(challenge []byte) ([]byte, error) {
+	return []byte(m.authzid), nil
+}
@@ -0,0 +1,205 @@
+package irc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/secure/precis"
+)
+
+// scramNonceLen is the number of random bytes used to generate the client nonce.
+const scramNonceLen = 24
+
+// scramMaxIterations limits the iteration count the server may ask for, so that a hostile
+// server cannot keep the client busy computing the salted password.
+const scramMaxIterations = 100000
+
+// scramGS2Header is the GS2 header sent by clients that don't support channel binding.
+const scramGS2Header = "n,"
+
+// scramSaslnameEscaper escapes user names as required by RfC-5802.
+var scramSaslnameEscaper = strings.NewReplacer("=", "=3D", ",", "=2C")
+
+// scramStep identifies the steps of a SCRAM authentication exchange.
+type scramStep int
+
+const (
+	scramClientFirst scramStep = iota
+	scramClientFinal
+	scramVerifyServer
+	scramDone
+)
+
+type saslScram struct {
+	name     string
+	hash     func() hash.Hash
+	authzid  string
+	username string
+	password string
+	nonce    func() (string, error)
+
+	step              scramStep
+	clientNonce       string
+	clientFirstBare   string
+	expectedSignature []byte
+}
+
+// NewSASLScramSHA256 creates the "SCRAM-SHA-256" mechanism (RfC-7677), which authenticates
+// the user without transmitting the password and also verifies the identity of the server.
+// The authorization identity authzid is usually left empty.
+func NewSASLScramSHA256(authzid string, username string, password string) SASLMechanism {
+	return &saslScram{
+		name:     "SCRAM-SHA-256",
+		hash:     sha256.New,
+		authzid:  authzid,
+		username: username,
+		password: password,
+		nonce:    generateScramNonce,
+	}
+}
+
+func (m *saslScram) Name() string {
+	return m.name
+}
+
+func (m *saslScram) Next(challenge []byte) (response []byte, err error) {
+	switch m.step {
+	case scramClientFirst:
+		response, err = m.clientFirstMessage()
+	case scramClientFinal:
+		response, err = m.clientFinalMessage(string(challenge))
+	case scramVerifyServer:
+		err = m.verifyServerFinalMessage(string(challenge))
+	default:
+		err = fmt.Errorf("unexpected challenge after the authentication exchange has been completed")
+	}
+	if err == nil {
+		m.step++
+	}
+	return
+}
+
+func (m *saslScram) gs2Header() string {
+	if m.authzid == "" {
+		return scramGS2Header + ","
+	}
+	return scramGS2Header + "a=" + scramSaslnameEscaper.Replace(m.authzid) + ","
+}
+
+func (m *saslScram) clientFirstMessage() ([]byte, error) {
+	// The names have to be normalized just like the password (see clientFinalMessage()).
+	var err error
+	if m.username, err = precis.OpaqueString.String(m.username); err != nil {
+		return nil, fmt.Errorf("invalid username: %v", err)
+	}
+	if m.authzid != "" {
+		if m.authzid, err = precis.OpaqueString.String(m.authzid); err != nil {
+			return nil, fmt.Errorf("invalid authorization identity: %v", err)
+		}
+	}
+	if m.clientNonce, err = m.nonce(); err != nil {
+		return nil, err
+	}
+	m.clientFirstBare = "n=" + scramSaslnameEscaper.Replace(m.username) + ",r=" + m.clientNonce
+	return []byte(m.gs2Header() + m.clientFirstBare), nil
+}
+
+func (m *saslScram) clientFinalMessage(serverFirst string) ([]byte, error) {
+	attrs := parseScramAttributes(serverFirst)
+	if e, ok := attrs['e']; ok {
+		return nil, fmt.Errorf("server reported an error: %s", e)
+	}
+	nonce := attrs['r']
+	if !strings.HasPrefix(nonce, m.clientNonce) || len(nonce) == len(m.clientNonce) {
+		return nil, fmt.Errorf("invalid server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	iterations, err := strconv.Atoi(attrs['i'])
+	if err != nil || iterations < 1 || iterations > scramMaxIterations {
+		return nil, fmt.Errorf("invalid iteration count: %s", attrs['i'])
+	}
+	// The password has to be normalized using SASLprep, which has been superseded by the
+	// OpaqueString profile of PRECIS (RfC-8265).
+	password, err := precis.OpaqueString.String(m.password)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password: %v", err)
+	}
+
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(m.gs2Header())) + ",r=" + nonce
+	authMessage := []byte(m.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	saltedPassword := m.hi([]byte(password), salt, iterations)
+	clientKey := m.hmac(saltedPassword, []byte("Client Key"))
+	h := m.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+	clientSignature := m.hmac(storedKey, authMessage)
+	clientProof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	serverKey := m.hmac(saltedPassword, []byte("Server Key"))
+	m.expectedSignature = m.hmac(serverKey, authMessage)
+
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(clientProof)), nil
+}
+
+func (m *saslScram) verifyServerFinalMessage(serverFinal string) error {
+	attrs := parseScramAttributes(serverFinal)
+	if e, ok := attrs['e']; ok {
+		return fmt.Errorf("server reported an error: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || !hmac.Equal(signature, m.expectedSignature) {
+		return fmt.Errorf("invalid server signature")
+	}
+	return nil
+}
+
+func (m *saslScram) hmac(key []byte, data []byte) []byte {
+	mac := hmac.New(m.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// hi implements the function Hi() as defined by RfC-5802, which is basically PBKDF2
+// with HMAC as pseudorandom function and an output length equal to the hash length.
+func (m *saslScram) hi(password []byte, salt []byte, iterations int) []byte {
+	u := m.hmac(password, append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		u = m.hmac(password, u)
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// parseScramAttributes splits a SCRAM message into its attributes.
+func parseScramAttributes(str string) map[byte]string {
+	attrs := make(map[byte]string)
+	for _, a := range strings.Split(str, ",") {
+		if len(a) >= 2 && a[1] == '=' {
+			attrs[a[0]] = a[2:]
+		}
+	}
+	return attrs
+}
+
+func generateScramNonce() (string, error) {
+	b := make([]byte, scramNonceLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate nonce: %v", err)
+	}
+	return base64.RawStdEncoding.EncodeToString(b), nil
+}
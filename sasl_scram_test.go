@@ -0,0 +1,79 @@
+package irc
+
+import (
+	"testing"
+)
+
+// Test vector taken from RfC-7677, section 3.
+func TestSASLScramSHA256(t *testing.T) {
+	m := NewSASLScramSHA256("", "user", "pencil").(*saslScram)
+	m.nonce = func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }
+
+	steps := []struct {
+		challenge string
+		response  string
+	}{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{
+			"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	}
+	for i, s := range steps {
+		response, err := m.Next([]byte(s.challenge))
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if string(response) != s.response {
+			t.Errorf("step %d: response -> \"%s\", expected: \"%s\"", i, response, s.response)
+		}
+	}
+}
+
+func TestSASLScramSHA256_InvalidServerSignature(t *testing.T) {
+	m := NewSASLScramSHA256("", "user", "pencil").(*saslScram)
+	m.nonce = func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }
+	m.Next(nil)
+	if _, err := m.Next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := m.Next([]byte("v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")); err == nil {
+		t.Error("expected server signature to be rejected")
+	}
+}
+
+func TestSASLScramSHA256_InvalidNonce(t *testing.T) {
+	m := NewSASLScramSHA256("", "user", "pencil").(*saslScram)
+	m.nonce = func() (string, error) { return "clientnonce", nil }
+	m.Next(nil)
+	if _, err := m.Next([]byte("r=othernonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Error("expected server nonce to be rejected")
+	}
+}
+
+func TestSASLScramSHA256_InvalidIterationCount(t *testing.T) {
+	for _, i := range []string{"0", "x", "2000000000"} {
+		m := NewSASLScramSHA256("", "user", "pencil").(*saslScram)
+		m.nonce = func() (string, error) { return "clientnonce", nil }
+		m.Next(nil)
+		if _, err := m.Next([]byte("r=clientnonceservernonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=" + i)); err == nil {
+			t.Errorf("expected iteration count %s to be rejected", i)
+		}
+	}
+}
+
+func TestSASLScramSHA256_NameNormalization(t *testing.T) {
+	// The names are normalized (NFC, non-ASCII spaces) before they are escaped.
+	m := NewSASLScramSHA256("Ad\u00a0Min", "Ju\u0308rgen=x", "pencil").(*saslScram)
+	m.nonce = func() (string, error) { return "clientnonce", nil }
+	response, err := m.Next(nil)
+	if expected := "n,a=Ad Min,n=J\u00fcrgen=3Dx,r=clientnonce"; err != nil || string(response) != expected {
+		t.Errorf("response -> \"%s\", %v, expected: \"%s\"", response, err, expected)
+	}
+
+	m = NewSASLScramSHA256("", "user\u0007", "pencil").(*saslScram)
+	if _, err := m.Next(nil); err == nil {
+		t.Error("expected the username to be rejected")
+	}
+}
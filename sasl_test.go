@@ -0,0 +1,147 @@
+package irc
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// negotiateSASL lets the fake server advertise and acknowledge the "sasl" capability.
+func negotiateSASL(srv *fakeServer, mechanisms string) {
+	srv.accept()
+	srv.expect("CAP LS 302")
+	srv.send(":irc.example.com CAP * LS :sasl=" + mechanisms)
+	srv.expect("CAP REQ sasl")
+	srv.send(":irc.example.com CAP * ACK :sasl")
+}
+
+func TestClientConnection_SASLPlain(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithSASL(NewSASLPlain("", "jdoe", "secret")), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	negotiateSASL(srv, "PLAIN,EXTERNAL")
+	srv.expect("AUTHENTICATE PLAIN")
+	srv.send("AUTHENTICATE +")
+	msg := srv.expect("AUTHENTICATE")
+	if payload, _ := base64.StdEncoding.DecodeString(msg.Parameters()[0]); string(payload) != "\x00jdoe\x00secret" {
+		t.Errorf("unexpected PLAIN payload: %q", payload)
+	}
+	srv.send(
+		":irc.example.com 900 * *!jdoe@localhost jdoe :You are now logged in as jdoe",
+		":irc.example.com 903 * :SASL authentication successful",
+	)
+	srv.expect("CAP END")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+}
+
+func TestClientConnection_SASLFailure(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithSASL(NewSASLPlain("", "jdoe", "wrong")), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	negotiateSASL(srv, "PLAIN")
+	srv.expect("AUTHENTICATE PLAIN")
+	srv.send("AUTHENTICATE +")
+	srv.expect("AUTHENTICATE")
+	srv.send(":irc.example.com 904 * :SASL authentication failed")
+
+	err := <-result
+	var saslErr *SASLError
+	if !errors.As(err, &saslErr) {
+		t.Fatalf("Open() -> %v, expected a SASLError", err)
+	}
+	if saslErr.Reply != SASLFailError || saslErr.Mechanism != "PLAIN" {
+		t.Errorf("unexpected SASL error: %v", saslErr)
+	}
+}
+
+func TestClientConnection_SASLUnsupportedMechanism(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithSASL(NewSASLExternal("")), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	negotiateSASL(srv, "PLAIN")
+	var saslErr *SASLError
+	if err := <-result; !errors.As(err, &saslErr) {
+		t.Fatalf("Open() -> %v, expected a SASLError", err)
+	}
+	if len(saslErr.Mechanisms) != 1 || saslErr.Mechanisms[0] != "PLAIN" {
+		t.Errorf("unexpected mechanisms reported: %v", saslErr.Mechanisms)
+	}
+}
+
+func TestClientConnection_SASLExternal(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithSASL(NewSASLExternal("")), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	negotiateSASL(srv, "EXTERNAL")
+	srv.expect("AUTHENTICATE EXTERNAL")
+	srv.send("AUTHENTICATE +")
+	srv.expect("AUTHENTICATE +")
+	srv.send(":irc.example.com 903 * :SASL authentication successful")
+	srv.expect("CAP END")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+}
+
+func TestClientConnection_SASLChunking(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	// 300 bytes of payload result in exactly 400 bytes of base64.
+	password := strings.Repeat("x", 300-len("\x00jdoe\x00"))
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithSASL(NewSASLPlain("", "jdoe", password)), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+
+	negotiateSASL(srv, "PLAIN")
+	srv.expect("AUTHENTICATE PLAIN")
+	srv.send("AUTHENTICATE +")
+	if msg := srv.expect("AUTHENTICATE"); len(msg.Parameters()[0]) != saslChunkSize {
+		t.Errorf("expected chunk of %d bytes, got %d bytes", saslChunkSize, len(msg.Parameters()[0]))
+	}
+	srv.expect("AUTHENTICATE +")
+	srv.send(":irc.example.com 903 * :SASL authentication successful")
+	srv.expect("CAP END")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+}
+
+func TestChunkSASLPayload(t *testing.T) {
+	var testdata = []struct {
+		len    int
+		chunks []int
+	}{
+		{0, []int{1}},
+		{10, []int{10}},
+		{400, []int{400, 1}},
+		{401, []int{400, 1}},
+		{900, []int{400, 400, 100}},
+	}
+	for _, tt := range testdata {
+		chunks := chunkSASLPayload(strings.Repeat("A", tt.len))
+		if len(chunks) != len(tt.chunks) {
+			t.Errorf("chunkSASLPayload(<%d bytes>) -> %d chunks, expected: %d", tt.len, len(chunks), len(tt.chunks))
+			continue
+		}
+		for i, c := range chunks {
+			if len(c) != tt.chunks[i] {
+				t.Errorf("chunkSASLPayload(<%d bytes>)[%d] has %d bytes, expected: %d", tt.len, i, len(c), tt.chunks[i])
+			}
+		}
+	}
+}
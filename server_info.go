@@ -12,15 +12,40 @@ type ServerInfo struct {
 	hostname     string
 	port         int
 	capabilities map[Capability]bool
+	isupport     *ISupport
 }
 
 func NewServerInfo(hostname string, port int) *ServerInfo {
 	return &ServerInfo{
 		hostname: hostname,
 		port:     port,
+		isupport: NewISupport(),
 	}
 }
 
+func (server ServerInfo) Hostname() string {
+	return server.hostname
+}
+
+func (server ServerInfo) Port() int {
+	return server.port
+}
+
+// HasCapability checks if the server has offered the given capability.
+func (server ServerInfo) HasCapability(c Capability) bool {
+	_, ok := server.capabilities[c]
+	return ok
+}
+
+// ISupport returns the features that the server has announced using RPL_ISUPPORT.
+// The defaults apply, if the server hasn't announced anything (yet).
+func (server ServerInfo) ISupport() *ISupport {
+	if server.isupport == nil {
+		return NewISupport()
+	}
+	return server.isupport
+}
+
 // NewServerInfoFromUrl creates a new server descriptor from the
 // given URL url. If the given URL is invalid, the returned error err
 // will be non-nil.
@@ -35,3 +35,35 @@ func TestServerInfoSort(t *testing.T) {
 		t.Error("sort order doesn't match expected result")
 	}
 }
+
+func TestClientConnection_ServerInfo(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "john[doe]"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "john[doe]")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send(":irc.example.com 005 john[doe] NETWORK=Example CASEMAPPING=ascii NICKLEN=30 :are supported by this server",
+		":john{doe}!johndoe@localhost NICK johnny",
+		"PING sync")
+	srv.expect("PONG")
+
+	info := conn.ServerInfo()
+	if info.Hostname() != "127.0.0.1" || info.Port() != srv.port() {
+		t.Errorf("ServerInfo() -> %s", info)
+	}
+	if is := info.ISupport(); is.Network != "Example" || is.Casemapping != CasemappingASCII || is.NickLen != 30 {
+		t.Errorf("ISupport() -> %+v", is)
+	}
+	if conn.Casemapping() != CasemappingASCII {
+		t.Errorf("Casemapping() -> %q", conn.Casemapping())
+	}
+	if conn.Nickname() != "john[doe]" {
+		t.Errorf("nick changes of other users should be ignored with ascii casemapping, but Nickname() -> %q", conn.Nickname())
+	}
+	conn.Close()
+	conn.Wait()
+}
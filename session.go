@@ -0,0 +1,164 @@
+package irc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultQuitTimeout is the time the server gets to close the connection after a QUIT
+// message has been sent by Close().
+const defaultQuitTimeout = 3 * time.Second
+
+// session represents a single connection to a server. It is the only owner of the socket:
+// the socket is read by the INPUT goroutine, written by the OUTPUT goroutine and closed by
+// close(), which may be called any number of times.
+type session struct {
+	activity  int64 // time of the last received message (Unix nanoseconds), accessed atomically.
+	sock      net.Conn
+	done      chan struct{} // closed once the INPUT goroutine has ended.
+	closed    int32         // set once close() has been called, accessed atomically.
+	pongs     chan string   // tokens of received PONG messages, consumed by the keepalive.
+	urgent    chan Message  // messages that bypass Out() (e.g. PONG replies), sent by the OUTPUT goroutine.
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newSession(sock net.Conn) *session {
+	s := &session{sock: sock, done: make(chan struct{}), pongs: make(chan string, 1), urgent: make(chan Message, 8)}
+	s.touch()
+	return s
+}
+
+// touch records that a message has been received from the server.
+func (s *session) touch() {
+	atomic.StoreInt64(&s.activity, time.Now().UnixNano())
+}
+
+// lastActivity returns the time at which the last message has been received.
+func (s *session) lastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.activity))
+}
+
+// close closes the socket, which terminates the INPUT goroutine and thus the session.
+func (s *session) close() error {
+	s.closeOnce.Do(func() {
+		atomic.StoreInt32(&s.closed, 1)
+		s.closeErr = s.sock.Close()
+	})
+	return s.closeErr
+}
+
+// sendUrgent hands a message to the OUTPUT goroutine, bypassing Out(). It never blocks:
+// if the OUTPUT goroutine is stuck, the message is dropped.
+func (s *session) sendUrgent(msg Message) {
+	select {
+	case s.urgent <- msg:
+	default:
+	}
+}
+
+// isClosed checks if close() has been called.
+func (s *session) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) == 1
+}
+
+// WithQuitMessage configures the reason that will be sent along with the QUIT message
+// when the connection is being closed.
+func WithQuitMessage(reason string) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.quitMessage = reason
+	}
+}
+
+// WithQuitTimeout configures how long Close() waits for the server to close the
+// connection after the QUIT message has been sent. Defaults to three seconds.
+func WithQuitTimeout(timeout time.Duration) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.quitTimeout = timeout
+	}
+}
+
+// dial establishes the connection to the given server using the configured transport
+// (see WithTransport() and Server.Transport) and proxy (see WithProxy() and Server.Proxy).
+// The dial will be aborted once the given context gets cancelled.
+func (conn *clientConnection) dial(ctx context.Context, server Server) (net.Conn, error) {
+	if server.Proxy == nil {
+		server.Proxy = conn.proxy
+	}
+	transport, err := conn.serverTransport(server)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Dial(ctx, server)
+}
+
+// closingContext returns a context that gets cancelled once Close() has been called or
+// once the given context gets cancelled. The returned function must be called to release
+// the resources associated with the context.
+func (conn *clientConnection) closingContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	closing := conn.closing
+	go func() {
+		select {
+		case <-closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// notifyState publishes a state change on the State() channel without ever blocking. If
+// nobody consumes the state changes, the oldest ones are being dropped.
+func (conn *clientConnection) notifyState(state ConnectionState) {
+	conn.stateMu.Lock()
+	defer conn.stateMu.Unlock()
+	for {
+		select {
+		case conn.state <- state:
+			return
+		default:
+			select {
+			case <-conn.state:
+			default:
+			}
+		}
+	}
+}
+
+// Close shuts the connection down. If a session with the server is established, a QUIT
+// message is sent and the server is given some time (see WithQuitTimeout()) to close the
+// connection, before it is closed forcibly. Pending reconnect attempts are cancelled.
+// Close can safely be called multiple times; only the first call has an effect. Use
+// Wait() to wait until all the goroutines of the connection have terminated.
+// If the underlying socket cannot be closed, the resulting error will be returned.
+func (conn *clientConnection) Close() (err error) {
+	conn.connMu.Lock()
+	if !conn.active || conn.isClosing() {
+		conn.connMu.Unlock()
+		return
+	}
+	close(conn.closing)
+	s := conn.session
+	conn.connMu.Unlock()
+	conn.notifyState(ConnectionStateClosing)
+	if s == nil {
+		return
+	}
+
+	timer := time.NewTimer(conn.quitTimeout)
+	defer timer.Stop()
+	select {
+	case conn.out <- NewQuitMessage(EmptyPrefix, conn.quitMessage):
+		select {
+		case <-s.done:
+		case <-timer.C:
+		}
+	case <-s.done:
+	case <-timer.C:
+	}
+	return s.close()
+}
@@ -0,0 +1,131 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientConnection_OpenContextCancelled(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithQuitTimeout(fakeServerQuitTimeout))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := conn.OpenContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("OpenContext() -> %v, expected: %v", err, context.Canceled)
+	}
+	conn.Wait()
+}
+
+func TestClientConnection_OpenContextDeadline(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- conn.OpenContext(ctx)
+	}()
+	srv.accept()
+	srv.expect("CAP LS")
+	// The server never answers, so the deadline will be exceeded.
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("OpenContext() -> %v, expected: %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("OpenContext() should give up once the deadline has been exceeded")
+	}
+	conn.Wait()
+}
+
+func TestClientConnection_GracefulClose(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithRegistration(Registration{Nickname: "johndoe"}),
+		WithQuitMessage("Goodbye"),
+		WithQuitTimeout(fakeServerTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- conn.Close()
+	}()
+	srv.expect("QUIT Goodbye")
+	srv.send("ERROR :Closing Link: johndoe (Quit: Goodbye)")
+	srv.conn.Close()
+	select {
+	case <-closed:
+	case <-time.After(fakeServerTimeout / 2):
+		t.Fatal("Close() should return once the server has closed the connection")
+	}
+	conn.Wait()
+	if err := conn.Close(); err != nil {
+		t.Errorf("closing the connection again should be a no-op, but returned: %v", err)
+	}
+}
+
+func TestClientConnection_CloseWithoutConsumers(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	// Neither In() nor State() nor Err() are being consumed.
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close() before Open() -> %v", err)
+	}
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	for i := 0; i < 2*connectionMsgBufSize; i++ {
+		srv.send(":alice!alice@localhost PRIVMSG johndoe :spam")
+	}
+	done := make(chan struct{})
+	go func() {
+		conn.Close()
+		conn.Close()
+		conn.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(fakeServerTimeout):
+		t.Fatal("the connection should shut down, even if nobody consumes its channels")
+	}
+}
+
+func TestClientConnection_PingWithoutConsumers(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	// Neither State() nor Err() are being consumed.
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}),
+		WithoutInChannel(), WithQuitTimeout(fakeServerQuitTimeout))
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	defer conn.Wait()
+	defer conn.Close()
+	c := conn.(*clientConnection)
+	for i := 0; i < 2*cap(c.err); i++ {
+		c.reportError(errors.New("unconsumed"))
+	}
+	srv.send("PING :irc.example.com")
+	srv.expect("PONG irc.example.com")
+	if len(c.err) != cap(c.err) {
+		t.Errorf("%d errors are buffered, expected %d", len(c.err), cap(c.err))
+	}
+}
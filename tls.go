@@ -0,0 +1,106 @@
+package irc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// WithTLS configures the connection to be established using TLS. The given configuration
+// can be used to supply custom root CAs, client certificates (as used for CertFP and SASL
+// EXTERNAL) and the like. If config is nil, a default configuration will be used. If no
+// server name has been set, the hostname of the connection will be used for SNI and for
+// the verification of the server's certificate.
+func WithTLS(config *tls.Config) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		conn.tlsConfig = config
+	}
+}
+
+// WithCertificatePins restricts the server certificates that will be accepted to the ones
+// matching any of the given SHA-256 fingerprints (hex-encoded, colons are permitted).
+// Once pins have been configured, the certificate chain will no longer be verified
+// against the root CAs, so self-signed certificates can be used as well. A
+// VerifyPeerCertificate function of the TLS configuration is still invoked once the
+// pins have been checked. Open() fails, if any of the fingerprints is invalid.
+// The option implies WithTLS, if TLS hasn't been configured explicitly.
+func WithCertificatePins(fingerprints ...string) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		if conn.tlsConfig == nil {
+			conn.tlsConfig = &tls.Config{}
+		}
+		for _, f := range fingerprints {
+			conn.certificatePins = append(conn.certificatePins, normalizeFingerprint(f))
+		}
+	}
+}
+
+// CertificateFingerprint computes the hex-encoded SHA-256 fingerprint of the given certificate.
+// This is the format that is expected by WithCertificatePins and that is commonly used for CertFP.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint converts a fingerprint into the format returned by CertificateFingerprint.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.Replace(fingerprint, ":", "", -1))
+}
+
+// clientTLSConfig derives the TLS configuration that will be used for dialing the server
+// with the given hostname.
+func (conn *clientConnection) clientTLSConfig(hostname string) *tls.Config {
+	config := &tls.Config{}
+	if conn.tlsConfig != nil {
+		config = conn.tlsConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = hostname
+	}
+	if len(conn.certificatePins) > 0 {
+		pins := conn.certificatePins
+		verify := config.VerifyPeerCertificate
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if err := verifyCertificatePins(rawCerts, pins); err != nil {
+				return err
+			}
+			if verify != nil {
+				return verify(rawCerts, verifiedChains)
+			}
+			return nil
+		}
+	}
+	return config
+}
+
+// validateCertificatePins checks if the given pins are hex-encoded SHA-256 fingerprints.
+func validateCertificatePins(pins []string) error {
+	for _, p := range pins {
+		if pin, err := hex.DecodeString(p); err != nil || len(pin) != sha256.Size {
+			return fmt.Errorf("invalid certificate fingerprint: %s", p)
+		}
+	}
+	return nil
+}
+
+// verifyCertificatePins checks if the server's (leaf) certificate matches any of the pinned fingerprints.
+func verifyCertificatePins(rawCerts [][]byte, pins []string) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("server did not present a certificate")
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	for _, p := range pins {
+		if pin, err := hex.DecodeString(p); err == nil && bytes.Equal(pin, sum[:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate with fingerprint %s does not match any of the pinned certificates", hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,189 @@
+package irc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSelfSignedCertificate creates a certificate that can be used by fake servers and clients.
+func newSelfSignedCertificate(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// openTLS opens the connection against the fake server and reports the result of Open().
+func openTLS(srv *fakeServer, conn ClientConnection) error {
+	drainConnection(conn)
+	result := openAsync(conn)
+	go func() {
+		if c, err := srv.listener.Accept(); err == nil {
+			srv.conn = c
+			c.Write([]byte(":irc.example.com 421 * CAP :Unknown command\r\n"))
+		}
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(fakeServerTimeout):
+		return fmt.Errorf("Open() did not return in time")
+	}
+}
+
+func TestClientConnection_TLSWithRootCAs(t *testing.T) {
+	cert := newSelfSignedCertificate(t, "irc.example.com")
+	srv := newFakeTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer srv.close()
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithTLS(&tls.Config{RootCAs: roots, ServerName: "irc.example.com"}), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := openTLS(srv, conn); err != nil {
+		t.Errorf("Open() -> %v", err)
+	}
+}
+
+func TestClientConnection_TLSUntrusted(t *testing.T) {
+	cert := newSelfSignedCertificate(t, "irc.example.com")
+	srv := newFakeTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer srv.close()
+
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithTLS(nil), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := openTLS(srv, conn); err == nil {
+		t.Error("expected untrusted server certificate to be rejected")
+	}
+}
+
+func TestClientConnection_TLSCertificatePins(t *testing.T) {
+	cert := newSelfSignedCertificate(t, "irc.example.com")
+	srv := newFakeTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer srv.close()
+
+	fingerprint := CertificateFingerprint(cert.Leaf)
+	var colonized []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colonized = append(colonized, strings.ToUpper(fingerprint[i:i+2]))
+	}
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithCertificatePins(strings.Join(colonized, ":")), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := openTLS(srv, conn); err != nil {
+		t.Errorf("Open() -> %v", err)
+	}
+
+	srv2 := newFakeTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer srv2.close()
+	conn = NewClientConnection("127.0.0.1", srv2.port(), WithCertificatePins(strings.Repeat("00", 32)), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := openTLS(srv2, conn); err == nil || !strings.Contains(err.Error(), fingerprint) {
+		t.Errorf("expected mismatching pin to be rejected, got: %v", err)
+	}
+
+	conn = NewClientConnection("127.0.0.1", srv2.port(), WithCertificatePins("no:hex"), WithQuitTimeout(fakeServerQuitTimeout))
+	if err := conn.Open(); err == nil {
+		t.Error("expected invalid pin to be rejected")
+	}
+}
+
+func TestClientConnection_TLSCertificatePinsWithCustomVerification(t *testing.T) {
+	cert := newSelfSignedCertificate(t, "irc.example.com")
+	srv := newFakeTLSServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	defer srv.close()
+
+	verified := false
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithTLS(&tls.Config{VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			verified = true
+			return nil
+		}}),
+		WithCertificatePins(CertificateFingerprint(cert.Leaf)),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	if err := openTLS(srv, conn); err != nil {
+		t.Errorf("Open() -> %v", err)
+	}
+	if !verified {
+		t.Error("the custom verification should have been invoked")
+	}
+}
+
+func TestClientConnection_TLSClientCertificate(t *testing.T) {
+	serverCert := newSelfSignedCertificate(t, "irc.example.com")
+	clientCert := newSelfSignedCertificate(t, "johndoe")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+	srv := newFakeTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	defer srv.close()
+
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithTLS(&tls.Config{Certificates: []tls.Certificate{clientCert}}),
+		WithCertificatePins(CertificateFingerprint(serverCert.Leaf)),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	if err := openTLS(srv, conn); err != nil {
+		t.Errorf("Open() -> %v", err)
+	}
+}
+
+func TestNewClientConnectionFromURL(t *testing.T) {
+	var testdata = []struct {
+		url  string
+		port int
+		tls  bool
+	}{
+		{"irc://irc.example.com", DefaultServerPort, false},
+		{"ircs://irc.example.com", DefaultServerPortTls, true},
+		{"ircs://irc.example.com:7000/#channel", 7000, true},
+		{"ws://irc.example.com", DefaultServerPortWebSocket, false},
+	}
+	for _, tt := range testdata {
+		url, _ := NewURL(tt.url)
+		conn, err := NewClientConnectionFromURL(url)
+		if err != nil {
+			t.Errorf("NewClientConnectionFromURL(%s) -> %v", tt.url, err)
+			continue
+		}
+		if conn.Hostname() != "irc.example.com" || conn.Port() != tt.port {
+			t.Errorf("NewClientConnectionFromURL(%s) -> %s:%d, expected: irc.example.com:%d", tt.url, conn.Hostname(), conn.Port(), tt.port)
+		}
+		if usesTLS := conn.(*clientConnection).tlsConfig != nil; usesTLS != tt.tls {
+			t.Errorf("NewClientConnectionFromURL(%s) uses TLS: %v, expected: %v", tt.url, usesTLS, tt.tls)
+		}
+	}
+	url, _ := NewURL("wss://irc.example.com/webirc")
+	conn, _ := NewClientConnectionFromURL(url)
+	expected := Server{Hostname: "irc.example.com", Port: uint(DefaultServerPortWebSocketTls), TLS: true, Transport: TransportWebSocket, Path: "/webirc"}
+	if server := conn.(*clientConnection).currentServer(); server != expected {
+		t.Errorf("NewClientConnectionFromURL(%s) connects to %+v, expected: %+v", url, server, expected)
+	}
+}
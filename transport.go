@@ -0,0 +1,146 @@
+package irc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Names of the transports that can be used to reach a server (see Server.Transport).
+const (
+	// TransportTCP connects to the server using a (plain or TLS encrypted) TCP connection.
+	// This is the default.
+	TransportTCP = "tcp"
+	// TransportUnix connects to a Unix domain socket (e.g. a local bouncer). The socket's
+	// path is taken from Server.Path.
+	TransportUnix = "unix"
+	// TransportWebSocket connects to the server using the IRCv3 WebSocket protocol. The
+	// resource to connect to is taken from Server.Path.
+	TransportWebSocket = "websocket"
+)
+
+// Transport establishes the stream over which IRC messages are being exchanged with a
+// server. The returned connection must carry the messages line by line, terminated by
+// CR-LF, regardless of the way they are actually being transmitted.
+type Transport interface {
+	Dial(ctx context.Context, server Server) (net.Conn, error)
+}
+
+// TransportFunc is an adapter to allow the use of ordinary functions as transports.
+type TransportFunc func(ctx context.Context, server Server) (net.Conn, error)
+
+// Dial calls f(ctx, server).
+func (f TransportFunc) Dial(ctx context.Context, server Server) (net.Conn, error) {
+	return f(ctx, server)
+}
+
+// WithTransport configures the transport that is used to connect to all the servers,
+// overriding the transports selected by Server.Transport and Server.TLS.
+func WithTransport(transport Transport) ClientConnectionOption {
+	return func(conn *clientConnection) {
+		conn.transport = transport
+	}
+}
+
+// TCPTransport connects to servers using plain TCP connections.
+type TCPTransport struct {
+	// Dialer is used to establish the connection. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+}
+
+// Dial connects to the server's hostname and port, using the server's proxy (if any).
+func (t *TCPTransport) Dial(ctx context.Context, server Server) (net.Conn, error) {
+	return dialTCP(ctx, t.Dialer, server)
+}
+
+// TLSTransport connects to servers using TLS encrypted TCP connections.
+type TLSTransport struct {
+	// Dialer is used to establish the TCP connection. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+	// Config is the TLS configuration to use. If its ServerName is empty, the server's
+	// hostname will be verified.
+	Config *tls.Config
+}
+
+// Dial connects to the server's hostname and port, using the server's proxy (if any),
+// and performs the TLS handshake.
+func (t *TLSTransport) Dial(ctx context.Context, server Server) (net.Conn, error) {
+	config := &tls.Config{}
+	if t.Config != nil {
+		config = t.Config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = server.Hostname
+	}
+	sock, err := dialTCP(ctx, t.Dialer, server)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(sock, config)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		sock.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// UnixTransport connects to servers listening on a Unix domain socket.
+type UnixTransport struct {
+	// Dialer is used to establish the connection. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+}
+
+// Dial connects to the socket found at the server's path.
+func (t *UnixTransport) Dial(ctx context.Context, server Server) (net.Conn, error) {
+	if server.Path == "" {
+		return nil, fmt.Errorf("no path to a unix socket has been specified")
+	}
+	return netDialer(t.Dialer).DialContext(ctx, "unix", server.Path)
+}
+
+// dialTCP establishes a TCP connection to the given server, either directly or through
+// the server's proxy.
+func dialTCP(ctx context.Context, dialer *net.Dialer, server Server) (net.Conn, error) {
+	if server.Proxy != nil {
+		return server.Proxy.dialContext(ctx, netDialer(dialer), serverAddress(server))
+	}
+	return netDialer(dialer).DialContext(ctx, "tcp", serverAddress(server))
+}
+
+func netDialer(dialer *net.Dialer) *net.Dialer {
+	if dialer == nil {
+		return &net.Dialer{}
+	}
+	return dialer
+}
+
+// serverAddress returns the "host:port" address of the given server.
+func serverAddress(server Server) string {
+	return net.JoinHostPort(server.Hostname, strconv.Itoa(int(server.Port)))
+}
+
+// serverTransport returns the transport that is used to connect to the given server.
+func (conn *clientConnection) serverTransport(server Server) (Transport, error) {
+	if conn.transport != nil {
+		return conn.transport, nil
+	}
+	useTLS := conn.tlsConfig != nil || server.TLS
+	switch server.Transport {
+	case "", TransportTCP:
+		if useTLS {
+			return &TLSTransport{Config: conn.clientTLSConfig(server.Hostname)}, nil
+		}
+		return &TCPTransport{}, nil
+	case TransportUnix:
+		return &UnixTransport{}, nil
+	case TransportWebSocket:
+		ws := &WebSocketTransport{}
+		if useTLS {
+			ws.TLSConfig = conn.clientTLSConfig(server.Hostname)
+		}
+		return ws, nil
+	}
+	return nil, fmt.Errorf("unknown transport: %s", server.Transport)
+}
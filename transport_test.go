@@ -0,0 +1,129 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientConnection_UnixTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "irc.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	srv := &fakeServer{t: t, listener: l}
+	defer srv.close()
+	url, err := NewURL("unix://" + path)
+	if err != nil {
+		t.Fatalf("NewURL() -> %v", err)
+	}
+	conn, err := NewClientConnectionFromURL(url, WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	if err != nil {
+		t.Fatalf("NewClientConnectionFromURL() -> %v", err)
+	}
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send("PING :irc.example.com")
+	srv.expect("PONG irc.example.com")
+	conn.Close()
+	conn.Wait()
+}
+
+func TestWithTransport(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	var dialed []Server
+	transport := TransportFunc(func(ctx context.Context, server Server) (net.Conn, error) {
+		dialed = append(dialed, server)
+		return (&TCPTransport{}).Dial(ctx, server)
+	})
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithTransport(transport), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	conn.Close()
+	conn.Wait()
+	if len(dialed) != 1 || dialed[0].Port != uint(srv.port()) {
+		t.Errorf("transport has dialed %v, expected: the fake server", dialed)
+	}
+}
+
+func TestClientConnection_UnknownTransport(t *testing.T) {
+	conn, _ := NewClientConnectionForNetwork(Network{Servers: []Server{{Hostname: "127.0.0.1", Port: 6667, Transport: "carrier-pigeon"}}})
+	drainConnection(conn)
+	if err := conn.Open(); err == nil {
+		t.Error("Open() should fail for unknown transports")
+	}
+	conn.Wait()
+}
+
+func TestTransport_DialErrors(t *testing.T) {
+	if _, err := (&UnixTransport{}).Dial(context.Background(), Server{Transport: TransportUnix}); err == nil {
+		t.Error("Dial() should fail if no path has been specified")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (&TCPTransport{}).Dial(ctx, Server{Hostname: "127.0.0.1", Port: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Dial() with a cancelled context -> %v, expected: %v", err, context.Canceled)
+	}
+}
+
+// failingConn is a connection whose writes fail once fail has been set.
+type failingConn struct {
+	net.Conn
+	fail int32
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.fail) == 1 {
+		return 0, errors.New("broken pipe")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestClientConnection_WriteError(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	var sock *failingConn
+	transport := TransportFunc(func(ctx context.Context, server Server) (net.Conn, error) {
+		c, err := (&TCPTransport{}).Dial(ctx, server)
+		if err != nil {
+			return nil, err
+		}
+		sock = &failingConn{Conn: c}
+		return sock, nil
+	})
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithTransport(transport), WithQuitTimeout(fakeServerQuitTimeout))
+	go func() {
+		for {
+			select {
+			case <-conn.In():
+			case <-conn.State():
+			}
+		}
+	}()
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	atomic.StoreInt32(&sock.fail, 1)
+	conn.Out() <- NewMessageWithoutPrefix(PrivmsgCommand, "jane", "Hello")
+	if err := <-conn.Err(); err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("Err() -> %v, expected the write error", err)
+	}
+	// The session has been closed, so Wait() returns without calling Close().
+	conn.Wait()
+}
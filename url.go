@@ -13,15 +13,30 @@ const DefaultServerPort int = 6667
 // See RfC-7194 for further details.
 const DefaultServerPortTls int = 6697
 
+// Default port to be used for IRC server connections over WebSocket.
+const DefaultServerPortWebSocket int = 80
+
+// Default port to be used for IRC server connections over WebSocket when using TLS.
+const DefaultServerPortWebSocketTls int = 443
+
 // urlRegex can be used to match valid IRC URLs.
 var urlRegexp = regexp.MustCompile(`(?P<Protocol>ircs?)(?:://)(?P<Host>[a-z0-9\\.\\-]*)(?::(?P<Port>\d+))?(?:/(?P<Channel>[#&+!][a-zA-Z0-9#&+!]{1,50}))?`)
 
+// webSocketURLRegexp can be used to match URLs of IRC servers that are reachable via WebSocket.
+var webSocketURLRegexp = regexp.MustCompile(`^(?P<Protocol>wss?)(?:://)(?P<Host>[a-zA-Z0-9\.\-]+)(?::(?P<Port>\d+))?(?P<Path>/[^\s?#]*)?$`)
+
+// unixURLRegexp can be used to match URLs of IRC servers that listen on a Unix socket.
+var unixURLRegexp = regexp.MustCompile(`^(?P<Protocol>unix)(?:://)(?P<Path>/\S+)$`)
+
 type URL interface {
 	String() string
 	IsValid() bool
 	Protocol() string
 	Hostname() string
 	Port() int
+	// Path returns the path of the Unix socket ("unix://") or the resource of the
+	// WebSocket endpoint ("ws://" and "wss://"). It is empty for other URLs.
+	Path() string
 }
 
 type url struct {
@@ -30,6 +45,7 @@ type url struct {
 	port     int
 	hostname string
 	protocol string
+	path     string
 }
 
 // NewURL creates a new IRC URL.
@@ -38,18 +54,25 @@ type url struct {
 // the returned error err will be non-nil.
 func NewURL(str string) (u URL, err error) {
 	uStruct := &url{
-		str:   str,
-		valid: urlRegexp.MatchString(str),
+		str: str,
 	}
 	u = uStruct
-	if !u.IsValid() {
+	var re *regexp.Regexp
+	for _, candidate := range []*regexp.Regexp{unixURLRegexp, webSocketURLRegexp, urlRegexp} {
+		if candidate.MatchString(str) {
+			re = candidate
+			break
+		}
+	}
+	if re == nil {
 		err = fmt.Errorf("invalid URL: %s", u)
 		return
 	}
+	uStruct.valid = true
 
-	match := urlRegexp.FindStringSubmatch(u.String())
+	match := re.FindStringSubmatch(u.String())
 	portSet := false
-	for i, name := range urlRegexp.SubexpNames() {
+	for i, name := range re.SubexpNames() {
 		value := match[i]
 		switch name {
 		case "Protocol":
@@ -57,8 +80,12 @@ func NewURL(str string) (u URL, err error) {
 		case "Host":
 			uStruct.hostname = value
 		case "Port":
-			uStruct.port, _ = strconv.Atoi(value)
-			portSet = true
+			if value != "" {
+				uStruct.port, _ = strconv.Atoi(value)
+				portSet = true
+			}
+		case "Path":
+			uStruct.path = value
 		}
 
 	}
@@ -70,6 +97,12 @@ func NewURL(str string) (u URL, err error) {
 			uStruct.port = DefaultServerPort
 		case "ircs":
 			uStruct.port = DefaultServerPortTls
+		case "ws":
+			uStruct.port = DefaultServerPortWebSocket
+		case "wss":
+			uStruct.port = DefaultServerPortWebSocketTls
+		case "unix":
+			// Unix sockets don't have a port.
 		default:
 			// We should NOT be able to reach this point, because the regular
 			// expression doesn't allow it. But... hey... can we be really sure? ;-)
@@ -80,6 +113,23 @@ func NewURL(str string) (u URL, err error) {
 	return
 }
 
+// serverFromURL derives the server configuration from the given URL.
+func serverFromURL(u URL) Server {
+	server := Server{Hostname: u.Hostname(), Port: uint(u.Port()), Path: u.Path()}
+	switch u.Protocol() {
+	case "ircs":
+		server.TLS = true
+	case "ws":
+		server.Transport = TransportWebSocket
+	case "wss":
+		server.Transport = TransportWebSocket
+		server.TLS = true
+	case "unix":
+		server.Transport = TransportUnix
+	}
+	return server
+}
+
 func (u *url) Hostname() string {
 	return u.hostname
 }
@@ -92,12 +142,16 @@ func (u *url) Port() int {
 	return u.port
 }
 
+func (u *url) Path() string {
+	return u.path
+}
+
 // String returns a string representation of the IRC URL.
 func (u *url) String() string {
 	return u.str
 }
 
-// IsValid validates the correctness of a given irc://-URL.
+// IsValid validates the correctness of a given irc://, ircs://, ws://, wss:// or unix:// URL.
 func (u *url) IsValid() bool {
 	return u.valid
 }
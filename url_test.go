@@ -13,10 +13,15 @@ var validUrlStrs = [...]string{
 	"irc://127.0.0.1:6667",
 	"irc://127.0.0.1/#channel",
 	"ircs://irc.example.com:6697/#channel",
+	"ws://irc.example.com/webirc",
+	"wss://irc.example.com:8097",
+	"unix:///run/znc/znc.sock",
 }
 
 var invalidUrlStrs = [...]string{
 	"http://www.example.com/",
+	"unix://relative.sock",
+	"wss://",
 }
 
 func TestNewUrl(t *testing.T) {
@@ -51,3 +56,42 @@ func TestURL_String(t *testing.T) {
 		}
 	}
 }
+
+func TestURL_Port(t *testing.T) {
+	var testdata = []struct {
+		str  string
+		port int
+	}{
+		{"irc://irc.example.com", DefaultServerPort},
+		{"ircs://irc.example.com/#channel", DefaultServerPortTls},
+		{"ircs://irc.example.com:7000", 7000},
+		{"ws://irc.example.com/webirc", DefaultServerPortWebSocket},
+		{"wss://irc.example.com", DefaultServerPortWebSocketTls},
+		{"wss://irc.example.com:8097/", 8097},
+		{"unix:///run/znc/znc.sock", 0},
+	}
+	for _, tt := range testdata {
+		url, _ := NewURL(tt.str)
+		if port := url.Port(); port != tt.port {
+			t.Errorf("NewURL(%s).Port() -> %d, expected: %d", tt.str, port, tt.port)
+		}
+	}
+}
+
+func TestURL_Path(t *testing.T) {
+	var testdata = []struct {
+		str  string
+		path string
+	}{
+		{"irc://irc.example.com/#channel", ""},
+		{"ws://irc.example.com/webirc", "/webirc"},
+		{"wss://irc.example.com", ""},
+		{"unix:///run/znc/znc.sock", "/run/znc/znc.sock"},
+	}
+	for _, tt := range testdata {
+		url, _ := NewURL(tt.str)
+		if path := url.Path(); path != tt.path {
+			t.Errorf("NewURL(%s).Path() -> %q, expected: %q", tt.str, path, tt.path)
+		}
+	}
+}
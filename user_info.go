@@ -6,6 +6,17 @@ import "fmt"
 type UserInfo struct {
 	Nickname string
 	Operator bool
+	// Username and Hostname are the "user" and "host" parts of the user's prefix.
+	Username string
+	Hostname string
+	Realname string
+	// Account is the name of the account the user is logged into (empty if unknown or
+	// not logged in).
+	Account     string
+	Away        bool
+	AwayMessage string
+	// Channels contains the names of the channels the user shares with the client.
+	Channels []string
 }
 
 // Creates a new user.
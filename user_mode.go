@@ -4,6 +4,7 @@ import "fmt"
 
 type UserMode rune
 
+// User modes as specified by RfC-2812.
 const (
 	UserModeAway                    UserMode = 'a'
 	UserModeInvisible               UserMode = 'i'
@@ -14,6 +15,33 @@ const (
 	UserModeReceiptForServerNotices UserMode = 's'
 )
 
+// User modes that are not part of RfC-2812, but are commonly supported by servers. Their
+// meaning may vary slightly between server implementations.
+const (
+	// UserModeBot marks the user as a bot (see the IRCv3 "bot" mode, BOT ISUPPORT token).
+	UserModeBot UserMode = 'B'
+	// UserModeCallerID only lets users on the accept list send private messages.
+	UserModeCallerID UserMode = 'g'
+	// UserModeCloaked hides the user's real hostname.
+	UserModeCloaked UserMode = 'x'
+	// UserModeDeaf prevents channel messages from being received.
+	UserModeDeaf UserMode = 'D'
+	// UserModeHideChannels hides the user's channels from WHOIS replies.
+	UserModeHideChannels UserMode = 'p'
+	// UserModeHideOperator hides the user's operator status.
+	UserModeHideOperator UserMode = 'H'
+	// UserModeNoCTCP blocks CTCP messages.
+	UserModeNoCTCP UserMode = 'T'
+	// UserModeRegisteredPrivmsgOnly only lets registered users send private messages.
+	UserModeRegisteredPrivmsgOnly UserMode = 'R'
+	// UserModeSecureConnection marks a user that is connected using TLS.
+	UserModeSecureConnection UserMode = 'Z'
+	// UserModeService marks a network service.
+	UserModeService UserMode = 'S'
+	// UserModeWhoisNotice notifies the user whenever someone performs a WHOIS on them.
+	UserModeWhoisNotice UserMode = 'W'
+)
+
 // Numeric returns the numeric representation of the user mode.
 // The only use of this numeric representation is during emission of the
 // USER message, whereas according to the specification only modes 'i' and 'w'
@@ -29,6 +57,11 @@ func (um UserMode) Numeric() int {
 	}
 }
 
+// hasNumeric checks if the user mode can be represented within the bitmask of the USER message.
+func (um UserMode) hasNumeric() bool {
+	return um == UserModeReceivesWallops || um == UserModeInvisible
+}
+
 func UserModesFromBitmask(mask int) UserModes {
 	var um UserModes
 	if mask&4 == 4 {
@@ -49,3 +82,56 @@ func (modes UserModes) Bitmask() int {
 	}
 	return m
 }
+
+// Has checks if the given mode is part of the modes.
+func (modes UserModes) Has(mode UserMode) bool {
+	for _, um := range modes {
+		if um == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the modes that result from applying the given changes (see
+// ParseUserModeChanges()).
+func (modes UserModes) Apply(changes ModeChanges) UserModes {
+	result := append(UserModes(nil), modes...)
+	for _, c := range changes {
+		if c.Adding && !result.Has(UserMode(c.Mode)) {
+			result = append(result, UserMode(c.Mode))
+		} else if !c.Adding {
+			for i, um := range result {
+				if um == UserMode(c.Mode) {
+					result = append(result[:i], result[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// UserModesFromString parses the given user modes (e.g. "+iw").
+func UserModesFromString(modes string) UserModes {
+	return UserModes(nil).Apply(ParseUserModeChanges(modes))
+}
+
+// UserModesFromReply parses the user modes contained in RPL_UMODEIS (221), which the
+// server sends in response to a MODE query for the client's own nickname.
+func UserModesFromReply(msg Message) (UserModes, error) {
+	params := msg.Parameters()
+	if msg.Command() != UModeIsReply || len(params) < 2 {
+		return nil, fmt.Errorf("not a valid RPL_UMODEIS reply: %s", msg)
+	}
+	return UserModesFromString(params[1]), nil
+}
+
+// String returns the mode characters of all the user modes (e.g. "iw").
+func (modes UserModes) String() string {
+	runes := make([]rune, len(modes))
+	for i, um := range modes {
+		runes[i] = rune(um)
+	}
+	return string(runes)
+}
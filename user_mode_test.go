@@ -0,0 +1,32 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUserModesFromReply(t *testing.T) {
+	msg, _ := NewMessageFromString(":irc.example.com 221 johndoe +iwxZ")
+	modes, err := UserModesFromReply(msg)
+	if err != nil {
+		t.Fatalf("UserModesFromReply(%s) -> %v", msg, err)
+	}
+	expected := UserModes{UserModeInvisible, UserModeReceivesWallops, UserModeCloaked, UserModeSecureConnection}
+	if !reflect.DeepEqual(modes, expected) {
+		t.Errorf("UserModesFromReply(%s) -> %v, expected: %v", msg, modes, expected)
+	}
+	if _, err := UserModesFromReply(NewMessage(EmptyPrefix, WelcomeReply, "johndoe", "Welcome")); err == nil {
+		t.Error("UserModesFromReply() should fail for other messages")
+	}
+}
+
+func TestUserModes_Apply(t *testing.T) {
+	modes := UserModesFromString("+iw")
+	modes = modes.Apply(ParseUserModeChanges("-w+xB"))
+	if s := modes.String(); s != "ixB" {
+		t.Errorf("Apply() -> %s, expected: ixB", s)
+	}
+	if !modes.Has(UserModeBot) || modes.Has(UserModeReceivesWallops) {
+		t.Errorf("Has() reports wrong modes for %s", modes)
+	}
+}
@@ -0,0 +1,379 @@
+package irc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WHOX queries sent by the library request the following fields (see WhoSpcrplReply):
+// token, channel, user, host, server, nick, flags, account and realname. The queries are
+// tagged with tokens from "600" to "699" (see isWhoxToken()), so that the replies can
+// be told apart from the replies to WHOX queries sent by the application.
+const (
+	whoxFields    = "tcuhsnfar"
+	whoxTokenBase = 600
+	whoxTokens    = 100
+)
+
+// isWhoxToken checks if the given token has been assigned to a WHOX query of the library.
+func isWhoxToken(token string) bool {
+	n, err := strconv.Atoi(token)
+	return err == nil && len(token) == 3 && n >= whoxTokenBase && n < whoxTokenBase+whoxTokens
+}
+
+// UserRegistry keeps track of the users the client knows about: their nicknames, users,
+// hosts, realnames, accounts, away states and the channels they share with the client.
+// Like the ChannelTracker, the registry has to be fed with all the messages received
+// from the server; it learns from JOIN (including extended-join), NAMES (including
+// userhost-in-names), WHO/WHOX and WHOIS replies as well as from NICK, QUIT, AWAY,
+// ACCOUNT, CHGHOST and SETNAME messages. Users that don't share a channel with the
+// client (anymore) are being forgotten: WHO and WHOIS replies about other users only
+// update what is known about them already.
+// Connections maintain a registry of their own, which can be queried using
+// ClientConnection.User().
+type UserRegistry struct {
+	mu       sync.RWMutex
+	nickname string
+	users    map[string]*registeredUser // keyed by the lowercase nickname
+	isupport *ISupport
+}
+
+type registeredUser struct {
+	info     UserInfo          // without channels
+	channels map[string]string // lowercase channel name -> channel name
+}
+
+// NewUserRegistry creates a registry that does not know about any users yet.
+func NewUserRegistry() *UserRegistry {
+	r := &UserRegistry{}
+	r.Reset()
+	return r
+}
+
+// Reset forgets about all users. This is necessary whenever a new connection to the
+// server is being established.
+func (r *UserRegistry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nickname = ""
+	r.users = make(map[string]*registeredUser)
+	r.isupport = NewISupport()
+}
+
+// User returns a snapshot of what is known about the user with the given nickname.
+func (r *UserRegistry) User(nickname string) (user UserInfo, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.users[r.toLowercase(nickname)]
+	if ok {
+		user = u.snapshot()
+	}
+	return
+}
+
+// Users returns snapshots of all known users, sorted by their nicknames.
+func (r *UserRegistry) Users() []UserInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make(UserInfoSlice, 0, len(r.users))
+	for _, u := range r.users {
+		users = append(users, u.snapshot())
+	}
+	sort.Sort(users)
+	return users
+}
+
+// UserFromPrefix resolves the prefix of a message into a user record. If the user is not
+// known, a record containing the information of the prefix is returned and ok is false.
+func (r *UserRegistry) UserFromPrefix(prefix Prefix) (user UserInfo, ok bool) {
+	if prefix == nil || prefix.Nickname() == "" {
+		return
+	}
+	if user, ok = r.User(prefix.Nickname()); ok {
+		return
+	}
+	return UserInfo{Nickname: prefix.Nickname(), Username: prefix.User(), Hostname: prefix.Host()}, false
+}
+
+// Process updates the registry according to the given message.
+func (r *UserRegistry) Process(msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	params := msg.Parameters()
+	var u *registeredUser
+	if pfx := msg.Prefix(); pfx != nil && pfx.Nickname() != "" {
+		u = r.users[r.toLowercase(pfx.Nickname())]
+		if u != nil && pfx.User() != "" {
+			u.info.Username, u.info.Hostname = pfx.User(), pfx.Host()
+		}
+		if account, ok := msg.Tags().Get(AccountMessageTag); ok && u != nil {
+			u.info.Account = account
+		}
+	}
+
+	switch msg.Command() {
+	case WelcomeReply:
+		if len(params) > 0 {
+			r.nickname = params[0]
+			r.user(params[0])
+		}
+	case ISupportReply:
+		r.isupport.Update(msg)
+	case JoinCommand:
+		if pfx := msg.Prefix(); pfx != nil && pfx.Nickname() != "" && len(params) > 0 {
+			u = r.user(pfx.Nickname())
+			u.info.Username, u.info.Hostname = pfx.User(), pfx.Host()
+			u.channels[r.toLowercase(params[0])] = params[0]
+			// extended-join: "JOIN <channel> <account> :<realname>"
+			if len(params) > 2 {
+				u.info.Account = accountName(params[1], "*")
+				u.info.Realname = params[2]
+			}
+		}
+	case PartCommand:
+		if pfx := msg.Prefix(); pfx != nil && len(params) > 0 {
+			for _, name := range splitList(params[0]) {
+				r.leave(name, pfx.Nickname())
+			}
+		}
+	case KickCommand:
+		if len(params) > 1 {
+			r.leave(params[0], params[1])
+		}
+	case QuitCommand:
+		if u != nil && !r.isSelf(u.info.Nickname) {
+			delete(r.users, r.toLowercase(u.info.Nickname))
+		}
+	case NickCommand:
+		if u != nil && len(params) > 0 {
+			if r.isSelf(u.info.Nickname) {
+				r.nickname = params[0]
+			}
+			delete(r.users, r.toLowercase(u.info.Nickname))
+			u.info.Nickname = params[0]
+			r.users[r.toLowercase(params[0])] = u
+		}
+	case AwayCommand:
+		// away-notify: "AWAY [:<message>]"
+		if u != nil {
+			u.info.Away = len(params) > 0 && params[0] != ""
+			u.info.AwayMessage = ""
+			if u.info.Away {
+				u.info.AwayMessage = params[0]
+			}
+		}
+	case AccountCommand:
+		if u != nil && len(params) > 0 {
+			u.info.Account = accountName(params[0], "*")
+		}
+	case ChghostCommand:
+		if u != nil && len(params) > 1 {
+			u.info.Username, u.info.Hostname = params[0], params[1]
+		}
+	case SetnameCommand:
+		if u != nil && len(params) > 0 {
+			u.info.Realname = params[0]
+		}
+	case AwayReply:
+		// "<client> <nick> :<message>"
+		if len(params) > 2 {
+			if u = r.known(params[1]); u != nil {
+				u.info.Away, u.info.AwayMessage = true, params[2]
+			}
+		}
+	case WhoisUserReply:
+		// "<client> <nick> <username> <host> * :<realname>"
+		if len(params) > 5 {
+			if u = r.known(params[1]); u != nil {
+				u.info.Username, u.info.Hostname, u.info.Realname = params[2], params[3], params[5]
+			}
+		}
+	case WhoisAccountReply:
+		if len(params) > 2 {
+			if u = r.known(params[1]); u != nil {
+				u.info.Account = params[2]
+			}
+		}
+	case WhoReply:
+		// "<client> <channel> <user> <host> <server> <nick> <flags> :<hopcount> <realname>"
+		if len(params) > 7 {
+			if u = r.member(params[5], params[1]); u == nil {
+				break
+			}
+			u.info.Username, u.info.Hostname = params[2], params[3]
+			if i := strings.IndexByte(params[7], ' '); i != -1 {
+				u.info.Realname = params[7][i+1:]
+			}
+			r.applyWhoFlags(u, params[6])
+			r.addChannel(u, params[1])
+		}
+	case WhoSpcrplReply:
+		// "<client> <token> <channel> <user> <host> <server> <nick> <flags> <account> :<realname>"
+		if len(params) > 9 && isWhoxToken(params[1]) {
+			if u = r.member(params[6], params[2]); u == nil {
+				break
+			}
+			u.info.Username, u.info.Hostname, u.info.Realname = params[3], params[4], params[9]
+			u.info.Account = accountName(params[8], "0")
+			r.applyWhoFlags(u, params[7])
+			r.addChannel(u, params[2])
+		}
+	case NamesReply:
+		// "<client> <symbol> <channel> :<names>" (older servers omit the symbol).
+		if len(params) > 2 {
+			r.addNames(params[len(params)-2], params[len(params)-1])
+		}
+	}
+}
+
+// accountName returns the given account name, unless it denotes that the user is not
+// logged in at all.
+func accountName(account string, none string) string {
+	if account == none {
+		return ""
+	}
+	return account
+}
+
+// toLowercase converts the given nickname or channel name according to the casemapping
+// of the server.
+func (r *UserRegistry) toLowercase(str string) string {
+	return r.isupport.Casemapping.ToLower(str)
+}
+
+// isSelf checks if the given nickname is our own one.
+func (r *UserRegistry) isSelf(nickname string) bool {
+	return r.nickname != "" && r.toLowercase(nickname) == r.toLowercase(r.nickname)
+}
+
+// user returns the user with the given nickname, which is being registered if necessary.
+func (r *UserRegistry) user(nickname string) *registeredUser {
+	key := r.toLowercase(nickname)
+	u, ok := r.users[key]
+	if !ok {
+		u = &registeredUser{info: UserInfo{Nickname: nickname}, channels: make(map[string]string)}
+		r.users[key] = u
+	}
+	return u
+}
+
+// known returns the user with the given nickname if it is known already, nil otherwise.
+func (r *UserRegistry) known(nickname string) *registeredUser {
+	return r.users[r.toLowercase(nickname)]
+}
+
+// member returns the user with the given nickname, which is being registered if it is
+// listed as member of a channel the client has joined. Otherwise, nil is returned for
+// users that aren't known already.
+func (r *UserRegistry) member(nickname string, channel string) *registeredUser {
+	if u := r.known(nickname); u != nil {
+		return u
+	}
+	if !r.isJoined(channel) {
+		return nil
+	}
+	return r.user(nickname)
+}
+
+// isJoined checks if the client is a member of the given channel.
+func (r *UserRegistry) isJoined(name string) bool {
+	self, ok := r.users[r.toLowercase(r.nickname)]
+	if !ok {
+		return false
+	}
+	_, joined := self.channels[r.toLowercase(name)]
+	return joined
+}
+
+// addChannel records that the user is a member of the given channel, as long as the
+// client is a member of the channel as well.
+func (r *UserRegistry) addChannel(u *registeredUser, name string) {
+	if r.isJoined(name) {
+		u.channels[r.toLowercase(name)] = name
+	}
+}
+
+// applyWhoFlags interprets the flags of WHO replies (e.g. "G*@"): 'H' (here) and 'G'
+// (gone) tell the away state, '*' marks operators.
+func (r *UserRegistry) applyWhoFlags(u *registeredUser, flags string) {
+	if strings.HasPrefix(flags, "G") {
+		u.info.Away = true
+	} else if strings.HasPrefix(flags, "H") {
+		u.info.Away, u.info.AwayMessage = false, ""
+	}
+	u.info.Operator = strings.Contains(flags, "*")
+}
+
+func (r *UserRegistry) leave(name string, nickname string) {
+	key := r.toLowercase(name)
+	if r.isSelf(nickname) {
+		for _, u := range r.users {
+			delete(u.channels, key)
+		}
+		for nick, u := range r.users {
+			if len(u.channels) == 0 && !r.isSelf(u.info.Nickname) {
+				delete(r.users, nick)
+			}
+		}
+		return
+	}
+	if u, ok := r.users[r.toLowercase(nickname)]; ok {
+		delete(u.channels, key)
+		if len(u.channels) == 0 {
+			delete(r.users, r.toLowercase(nickname))
+		}
+	}
+}
+
+// addNames registers the members listed within a RPL_NAMREPLY, as long as the client is a
+// member of the channel as well. If the server has sent their users and hosts
+// (userhost-in-names), these are being recorded as well.
+func (r *UserRegistry) addNames(name string, names string) {
+	if !r.isJoined(name) {
+		return
+	}
+	for _, entry := range strings.Fields(names) {
+		entry = strings.TrimLeft(entry, r.isupport.PrefixSymbols)
+		if entry == "" {
+			continue
+		}
+		pfx := NewPrefixFromString(entry)
+		nickname := pfx.Nickname()
+		if nickname == "" {
+			nickname = entry
+		}
+		u := r.user(nickname)
+		if pfx.User() != "" {
+			u.info.Username, u.info.Hostname = pfx.User(), pfx.Host()
+		}
+		u.channels[r.toLowercase(name)] = name
+	}
+}
+
+// snapshot returns a copy of the user's record, including the names of its channels.
+func (u *registeredUser) snapshot() UserInfo {
+	info := u.info
+	info.Channels = make([]string, 0, len(u.channels))
+	for _, name := range u.channels {
+		info.Channels = append(info.Channels, name)
+	}
+	sort.Strings(info.Channels)
+	return info
+}
+
+// User returns a snapshot of what is known about the user with the given nickname.
+func (conn *clientConnection) User(nickname string) (UserInfo, bool) {
+	return conn.users.User(nickname)
+}
+
+// Users returns snapshots of all the users the connection knows about.
+func (conn *clientConnection) Users() []UserInfo {
+	return conn.users.Users()
+}
+
+// UserFromPrefix resolves the prefix of a message into a user record.
+func (conn *clientConnection) UserFromPrefix(prefix Prefix) (UserInfo, bool) {
+	return conn.users.UserFromPrefix(prefix)
+}
@@ -0,0 +1,254 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+// processUsers feeds the given raw messages into the registry.
+func processUsers(t *testing.T, registry *UserRegistry, lines ...string) {
+	for _, line := range lines {
+		msg, err := NewMessageFromString(line)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", line, err)
+		}
+		registry.Process(msg)
+	}
+}
+
+// joinedRegistry returns a registry that has joined "#test" along with a few other users.
+func joinedRegistry(t *testing.T) *UserRegistry {
+	registry := NewUserRegistry()
+	processUsers(t, registry,
+		":irc.example.com 001 me :Welcome",
+		":me!me@localhost JOIN #test",
+		":irc.example.com 353 me = #test :@alice!al@alice.example.com +bob me",
+		":irc.example.com 366 me #test :End of /NAMES list.",
+	)
+	return registry
+}
+
+func TestUserRegistry_Names(t *testing.T) {
+	registry := joinedRegistry(t)
+	alice, ok := registry.User("ALICE")
+	if !ok {
+		t.Fatal("alice should be known")
+	}
+	want := UserInfo{Nickname: "alice", Username: "al", Hostname: "alice.example.com", Channels: []string{"#test"}}
+	if !reflect.DeepEqual(alice, want) {
+		t.Errorf("User(alice) -> %+v, want %+v", alice, want)
+	}
+	if bob, ok := registry.User("bob"); !ok || bob.Hostname != "" {
+		t.Errorf("User(bob) -> %+v, %v", bob, ok)
+	}
+	var nicknames []string
+	for _, u := range registry.Users() {
+		nicknames = append(nicknames, u.Nickname)
+	}
+	if !reflect.DeepEqual(nicknames, []string{"alice", "bob", "me"}) {
+		t.Errorf("Users() -> %v", nicknames)
+	}
+}
+
+func TestUserRegistry_ExtendedJoin(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":carol!c@carol.example.com JOIN #test carolacc :Carol Smith",
+		":dave!d@dave.example.com JOIN #test * :Dave",
+	)
+	carol, _ := registry.User("carol")
+	if carol.Account != "carolacc" || carol.Realname != "Carol Smith" || carol.Hostname != "carol.example.com" {
+		t.Errorf("User(carol) -> %+v", carol)
+	}
+	if dave, _ := registry.User("dave"); dave.Account != "" || dave.Realname != "Dave" {
+		t.Errorf("User(dave) -> %+v", dave)
+	}
+}
+
+func TestUserRegistry_Updates(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":bob!b@bob.example.com PRIVMSG #test :hi",
+		":bob!b@bob.example.com AWAY :Gone fishing",
+		":bob!b@bob.example.com ACCOUNT bobacc",
+		":bob!b@bob.example.com CHGHOST bobby cloak.example.com",
+		":bob!bobby@cloak.example.com SETNAME :Bob Builder",
+	)
+	want := UserInfo{
+		Nickname:    "bob",
+		Username:    "bobby",
+		Hostname:    "cloak.example.com",
+		Realname:    "Bob Builder",
+		Account:     "bobacc",
+		Away:        true,
+		AwayMessage: "Gone fishing",
+		Channels:    []string{"#test"},
+	}
+	if bob, _ := registry.User("bob"); !reflect.DeepEqual(bob, want) {
+		t.Errorf("User(bob) -> %+v, want %+v", bob, want)
+	}
+	processUsers(t, registry,
+		":bob!bobby@cloak.example.com AWAY",
+		":bob!bobby@cloak.example.com ACCOUNT *",
+		"@account=other :alice!al@alice.example.com PRIVMSG #test :hi",
+	)
+	if bob, _ := registry.User("bob"); bob.Away || bob.AwayMessage != "" || bob.Account != "" {
+		t.Errorf("User(bob) -> %+v", bob)
+	}
+	if alice, _ := registry.User("alice"); alice.Account != "other" {
+		t.Errorf("User(alice) -> %+v", alice)
+	}
+}
+
+func TestUserRegistry_Who(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":irc.example.com 352 me #test b bob.example.com irc.example.com bob G* :0 Bob",
+		":irc.example.com 352 me #test c carol.example.com irc.example.com carol H :0 Carol",
+		":irc.example.com 354 me 616 #test al alice.example.net irc.example.com alice H aliceacc :Alice",
+		":irc.example.com 354 me 616 #elsewhere e eve.example.com irc.example.com eve H 0 :Eve",
+		":irc.example.com 354 me 999 #test x x.example.com irc.example.com mallory H 0 :Mallory",
+	)
+	bob, _ := registry.User("bob")
+	if !bob.Away || !bob.Operator || bob.Realname != "Bob" || bob.Hostname != "bob.example.com" {
+		t.Errorf("User(bob) -> %+v", bob)
+	}
+	alice, _ := registry.User("alice")
+	if alice.Away || alice.Account != "aliceacc" || alice.Hostname != "alice.example.net" || alice.Realname != "Alice" {
+		t.Errorf("User(alice) -> %+v", alice)
+	}
+	if carol, ok := registry.User("carol"); !ok || !reflect.DeepEqual(carol.Channels, []string{"#test"}) {
+		t.Errorf("User(carol) -> %+v, %v", carol, ok)
+	}
+	if eve, ok := registry.User("eve"); ok {
+		t.Errorf("User(eve) -> %+v, eve does not share a channel", eve)
+	}
+	if _, ok := registry.User("mallory"); ok {
+		t.Error("WHOX replies with foreign tokens should be ignored")
+	}
+}
+
+func TestUserRegistry_Whois(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":irc.example.com 311 me bob b bob.example.com * :Bob",
+		":irc.example.com 330 me bob bobacc :is logged in as",
+		":irc.example.com 301 me bob :Away",
+		":irc.example.com 311 me zed z zed.example.com * :Zed",
+		":irc.example.com 330 me zed zedacc :is logged in as",
+		":irc.example.com 301 me zed :Away",
+	)
+	want := UserInfo{Nickname: "bob", Username: "b", Hostname: "bob.example.com", Realname: "Bob",
+		Account: "bobacc", Away: true, AwayMessage: "Away", Channels: []string{"#test"}}
+	if bob, _ := registry.User("bob"); !reflect.DeepEqual(bob, want) {
+		t.Errorf("User(bob) -> %+v, want %+v", bob, want)
+	}
+	// Users that don't share a channel would never be forgotten.
+	if zed, ok := registry.User("zed"); ok {
+		t.Errorf("User(zed) -> %+v, zed does not share a channel", zed)
+	}
+}
+
+func TestUserRegistry_NickAndQuit(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":alice!al@alice.example.com NICK alicia",
+		":me!me@localhost NICK myself",
+		":bob!b@bob.example.com QUIT :Bye",
+	)
+	if _, ok := registry.User("alice"); ok {
+		t.Error("alice should have been renamed")
+	}
+	if alicia, ok := registry.User("alicia"); !ok || alicia.Username != "al" {
+		t.Errorf("User(alicia) -> %+v, %v", alicia, ok)
+	}
+	if _, ok := registry.User("bob"); ok {
+		t.Error("bob should have been forgotten")
+	}
+	processUsers(t, registry, ":myself!me@localhost QUIT :Bye")
+	if _, ok := registry.User("myself"); !ok {
+		t.Error("the client itself should never be forgotten")
+	}
+}
+
+func TestUserRegistry_Leave(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":me!me@localhost JOIN #other",
+		":irc.example.com 353 me = #other :me bob",
+		":alice!al@alice.example.com PART #test",
+	)
+	if _, ok := registry.User("alice"); ok {
+		t.Error("alice does not share a channel anymore")
+	}
+	processUsers(t, registry, ":op!op@localhost KICK #test me :Out")
+	bob, ok := registry.User("bob")
+	if !ok || !reflect.DeepEqual(bob.Channels, []string{"#other"}) {
+		t.Errorf("User(bob) -> %+v, %v", bob, ok)
+	}
+	processUsers(t, registry, ":me!me@localhost PART #other")
+	if users := registry.Users(); len(users) != 1 || users[0].Nickname != "me" {
+		t.Errorf("Users() -> %+v", users)
+	}
+}
+
+func TestUserRegistry_PartMultipleChannels(t *testing.T) {
+	registry := joinedRegistry(t)
+	processUsers(t, registry,
+		":me!me@localhost JOIN #other",
+		":irc.example.com 353 me = #other :me bob carol",
+		":me!me@localhost PART #test,#other :Bye",
+	)
+	if users := registry.Users(); len(users) != 1 || users[0].Nickname != "me" {
+		t.Errorf("Users() -> %+v", users)
+	}
+}
+
+func TestUserRegistry_NamesOfOtherChannels(t *testing.T) {
+	registry := joinedRegistry(t)
+	before := registry.Users()
+	processUsers(t, registry,
+		":irc.example.com 353 me = #elsewhere :@eve bob",
+		":irc.example.com 366 me #elsewhere :End of /NAMES list.",
+	)
+	if users := registry.Users(); !reflect.DeepEqual(users, before) {
+		t.Errorf("Users() -> %+v, expected: %+v", users, before)
+	}
+}
+
+func TestUserRegistry_UserFromPrefix(t *testing.T) {
+	registry := joinedRegistry(t)
+	if u, ok := registry.UserFromPrefix(NewPrefixFromString("alice!x@y")); !ok || u.Hostname != "alice.example.com" {
+		t.Errorf("UserFromPrefix(alice) -> %+v, %v", u, ok)
+	}
+	u, ok := registry.UserFromPrefix(NewPrefixFromString("stranger!s@stranger.example.com"))
+	if ok || u.Nickname != "stranger" || u.Username != "s" || u.Hostname != "stranger.example.com" {
+		t.Errorf("UserFromPrefix(stranger) -> %+v, %v", u, ok)
+	}
+}
+
+func TestClientConnection_User(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(), WithRegistration(Registration{Nickname: "johndoe"}), WithQuitTimeout(fakeServerQuitTimeout))
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	srv.send(":johndoe!johndoe@localhost JOIN #test",
+		":irc.example.com 353 johndoe = #test :@johndoe +jane!j@jane.example.com",
+		":irc.example.com 366 johndoe #test :End of /NAMES list.",
+		"PING sync")
+	srv.expect("PONG")
+
+	if jane, ok := conn.User("JANE"); !ok || jane.Hostname != "jane.example.com" {
+		t.Errorf("User(jane) -> %+v, %v", jane, ok)
+	}
+	if len(conn.Users()) != 2 {
+		t.Errorf("Users() -> %+v", conn.Users())
+	}
+	conn.Close()
+	conn.Wait()
+}
@@ -0,0 +1,353 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Subprotocols defined by the IRCv3 WebSocket specification.
+const (
+	// WebSocketBinarySubprotocol transmits every message as a binary frame.
+	WebSocketBinarySubprotocol = "binary.ircv3.net"
+	// WebSocketTextSubprotocol transmits every message as a (UTF-8) text frame.
+	WebSocketTextSubprotocol = "text.ircv3.net"
+)
+
+// webSocketGUID is used to compute the Sec-WebSocket-Accept header (see RfC-6455, 1.3).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketFrameSize limits the size of the frames that are accepted from the server.
+// IRC messages (including tags) are way smaller than that.
+const maxWebSocketFrameSize = 64 * 1024
+
+// WebSocket opcodes (see RfC-6455, 5.2).
+const (
+	webSocketOpContinuation byte = 0x0
+	webSocketOpText         byte = 0x1
+	webSocketOpBinary       byte = 0x2
+	webSocketOpClose        byte = 0x8
+	webSocketOpPing         byte = 0x9
+	webSocketOpPong         byte = 0xA
+)
+
+// WebSocketTransport connects to servers using the IRCv3 WebSocket protocol. Each IRC
+// message is transmitted as a single frame, without a trailing CR-LF.
+type WebSocketTransport struct {
+	// Dialer is used to establish the TCP connection. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+	// TLSConfig is used for "wss" connections. If it is non-nil, TLS is used even for
+	// servers that don't have Server.TLS set.
+	TLSConfig *tls.Config
+	// Binary prefers the binary subprotocol over the text subprotocol. The server may
+	// still select the text subprotocol, though.
+	Binary bool
+	// Header contains additional headers for the opening handshake (e.g. "Origin").
+	Header http.Header
+}
+
+// Dial connects to the server and performs the WebSocket opening handshake. The resource
+// is taken from the server's path and defaults to "/".
+func (t *WebSocketTransport) Dial(ctx context.Context, server Server) (net.Conn, error) {
+	var sock net.Conn
+	var err error
+	scheme := "ws"
+	if t.TLSConfig != nil || server.TLS {
+		scheme = "wss"
+		sock, err = (&TLSTransport{Dialer: t.Dialer, Config: t.TLSConfig}).Dial(ctx, server)
+	} else {
+		sock, err = (&TCPTransport{Dialer: t.Dialer}).Dial(ctx, server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The handshake must not outlive the context either.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sock.Close()
+		case <-stop:
+		}
+	}()
+	ws, err := t.handshake(sock, scheme, server)
+	if err != nil {
+		sock.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return ws, nil
+}
+
+func (t *WebSocketTransport) handshake(sock net.Conn, scheme string, server Server) (*webSocketConn, error) {
+	path := server.Path
+	if path == "" {
+		path = "/"
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+serverAddress(server)+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range t.Header {
+		req.Header[name] = values
+	}
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	subprotocols := []string{WebSocketTextSubprotocol, WebSocketBinarySubprotocol}
+	if t.Binary {
+		subprotocols[0], subprotocols[1] = subprotocols[1], subprotocols[0]
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", strings.Join(subprotocols, ", "))
+	if err = req.Write(sock); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(sock)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebSocket handshake: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("WebSocket handshake has been rejected: %s", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("invalid WebSocket handshake: missing upgrade header")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, fmt.Errorf("invalid WebSocket handshake: wrong Sec-WebSocket-Accept header")
+	}
+	opcode := webSocketOpText
+	switch resp.Header.Get("Sec-WebSocket-Protocol") {
+	case WebSocketBinarySubprotocol:
+		opcode = webSocketOpBinary
+	case WebSocketTextSubprotocol, "":
+		// Servers that don't select a subprotocol are expected to use text frames.
+	default:
+		return nil, fmt.Errorf("server selected an unknown WebSocket subprotocol: %s", resp.Header.Get("Sec-WebSocket-Protocol"))
+	}
+	return &webSocketConn{Conn: sock, reader: reader, opcode: opcode}, nil
+}
+
+// webSocketAccept computes the value of the Sec-WebSocket-Accept header for the given key.
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// webSocketConn translates between the line-based IRC protocol and WebSocket frames:
+// Every line written to it is sent as a single frame, every frame that is received can
+// be read as a line terminated by CR-LF.
+type webSocketConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	opcode  byte         // opcode of the data frames, depending on the subprotocol.
+	pending bytes.Buffer // received data that hasn't been read yet.
+	partial []byte       // written data that doesn't end with a line break yet.
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Read returns the payload of the received data frames, each one terminated by CR-LF.
+// Control frames are handled transparently.
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	for c.pending.Len() == 0 {
+		if err := c.readMessage(); err != nil {
+			return 0, err
+		}
+	}
+	return c.pending.Read(p)
+}
+
+// readMessage reads frames until a complete data message has been received.
+func (c *webSocketConn) readMessage() error {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(c.reader)
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case webSocketOpPing:
+			if err := c.writeFrame(webSocketOpPong, payload); err != nil {
+				return err
+			}
+			continue
+		case webSocketOpPong:
+			continue
+		case webSocketOpClose:
+			c.writeFrame(webSocketOpClose, payload)
+			return io.EOF
+		case webSocketOpText, webSocketOpBinary, webSocketOpContinuation:
+			message = append(message, payload...)
+			if len(message) > maxWebSocketFrameSize {
+				return fmt.Errorf("WebSocket message exceeds %d bytes", maxWebSocketFrameSize)
+			}
+		default:
+			return fmt.Errorf("unknown WebSocket opcode: %#x", opcode)
+		}
+		if fin {
+			break
+		}
+	}
+	// Line breaks within a message would allow the server to inject further messages.
+	if i := bytes.IndexAny(message, "\r\n"); i >= 0 {
+		message = message[:i]
+	}
+	c.pending.Write(message)
+	c.pending.WriteString("\r\n")
+	return nil
+}
+
+// Write sends every complete line (terminated by LF or CR-LF) as a separate frame.
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	c.partial = append(c.partial, p...)
+	for {
+		i := bytes.IndexByte(c.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(c.partial[:i], "\r")
+		c.partial = c.partial[i+1:]
+		if len(line) == 0 {
+			continue
+		}
+		if c.opcode == webSocketOpText && !utf8.Valid(line) {
+			line = bytes.ToValidUTF8(line, []byte("\uFFFD"))
+		}
+		if err := c.writeFrame(c.opcode, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// isUTF8Only checks if the text subprotocol has been selected, which only allows UTF-8.
+func (c *webSocketConn) isUTF8Only() bool {
+	return c.opcode == webSocketOpText
+}
+
+// Close sends a close frame (if that hasn't happened yet) and closes the socket.
+func (c *webSocketConn) Close() error {
+	// The close frame is a courtesy; a stalled connection must not block closing it.
+	c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(webSocketOpClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	return c.Conn.Close()
+}
+
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	if opcode == webSocketOpClose {
+		c.closed = true
+	}
+	return writeWebSocketFrame(c.Conn, opcode, payload, true)
+}
+
+// writeWebSocketFrame writes a single, unfragmented frame. Frames sent by clients must
+// be masked, frames sent by servers must not.
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	data := payload
+	if masked {
+		header[1] |= 0x80
+		key := make([]byte, 4)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		header = append(header, key...)
+		data = make([]byte, len(payload))
+		for i, b := range payload {
+			data[i] = b ^ key[i%4]
+		}
+	}
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+// readWebSocketFrame reads a single frame and unmasks its payload, if necessary.
+func readWebSocketFrame(r io.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(r, ext); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(r, ext); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > maxWebSocketFrameSize {
+		err = errors.New("WebSocket frame is too large")
+		return
+	}
+	var key []byte
+	if masked {
+		key = make([]byte, 4)
+		if _, err = io.ReadFull(r, key); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return
+}
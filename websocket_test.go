@@ -0,0 +1,199 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// webSocketListener performs the server side of the WebSocket handshake for every accepted
+// connection and translates the frames to lines, so that fakeServer can be used as is.
+type webSocketListener struct {
+	net.Listener
+	subprotocol string
+	requests    chan *http.Request
+	opcodes     chan byte // opcodes of the data frames received from the client.
+}
+
+func newFakeWebSocketServer(t *testing.T, subprotocol string) (*fakeServer, *webSocketListener) {
+	srv := newFakeServer(t)
+	l := &webSocketListener{
+		Listener:    srv.listener,
+		subprotocol: subprotocol,
+		requests:    make(chan *http.Request, 1),
+		opcodes:     make(chan byte, 64),
+	}
+	srv.listener = l
+	return srv, l
+}
+
+func (l *webSocketListener) Accept() (net.Conn, error) {
+	sock, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(sock)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	l.requests <- req
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(req.Header.Get("Sec-WebSocket-Key")) + "\r\n"
+	if l.subprotocol != "" {
+		response += "Sec-WebSocket-Protocol: " + l.subprotocol + "\r\n"
+	}
+	if _, err = sock.Write([]byte(response + "\r\n")); err != nil {
+		sock.Close()
+		return nil, err
+	}
+	opcode := webSocketOpText
+	if l.subprotocol == WebSocketBinarySubprotocol {
+		opcode = webSocketOpBinary
+	}
+	server, bridge := net.Pipe()
+	go func() {
+		defer bridge.Close()
+		for {
+			_, op, payload, err := readWebSocketFrame(reader)
+			if err != nil || op == webSocketOpClose {
+				return
+			}
+			select {
+			case l.opcodes <- op:
+			default:
+			}
+			if _, err := bridge.Write(append(payload, "\r\n"...)); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer sock.Close()
+		scanner := bufio.NewScanner(bridge)
+		for scanner.Scan() {
+			if err := writeWebSocketFrame(sock, opcode, scanner.Bytes(), false); err != nil {
+				return
+			}
+		}
+	}()
+	return server, nil
+}
+
+func TestClientConnection_WebSocketTransport(t *testing.T) {
+	srv, l := newFakeWebSocketServer(t, WebSocketTextSubprotocol)
+	defer srv.close()
+	url, err := NewURL("ws://127.0.0.1:" + strconv.Itoa(srv.port()) + "/webirc")
+	if err != nil {
+		t.Fatalf("NewURL() -> %v", err)
+	}
+	conn, err := NewClientConnectionFromURL(url, WithRegistration(Registration{Nickname: "johndoe"}),
+		WithEncoding(charmap.ISO8859_1), WithQuitTimeout(fakeServerQuitTimeout))
+	if err != nil {
+		t.Fatalf("NewClientConnectionFromURL() -> %v", err)
+	}
+	drainConnection(conn)
+	result := openAsync(conn)
+	registerWithFakeServer(srv, "johndoe")
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	req := <-l.requests
+	if req.URL.Path != "/webirc" {
+		t.Errorf("handshake requested %s, expected: /webirc", req.URL.Path)
+	}
+	if offered := req.Header.Get("Sec-WebSocket-Protocol"); !strings.HasPrefix(offered, WebSocketTextSubprotocol) {
+		t.Errorf("client offered %q, expected the text subprotocol first", offered)
+	}
+	srv.send("PING :irc.example.com")
+	srv.expect("PONG irc.example.com")
+	if op := <-l.opcodes; op != webSocketOpText {
+		t.Errorf("client sent frames with opcode %#x, expected text frames", op)
+	}
+	// Text frames must be UTF-8, so the legacy encoding doesn't apply.
+	conn.Out() <- NewMessageWithoutPrefix(PrivmsgCommand, "alice", "très bien")
+	srv.expect("PRIVMSG alice :très bien")
+	conn.Close()
+	conn.Wait()
+}
+
+func TestClientConnection_WebSocketTransportBinary(t *testing.T) {
+	srv, l := newFakeWebSocketServer(t, WebSocketBinarySubprotocol)
+	defer srv.close()
+	conn := NewClientConnection("127.0.0.1", srv.port(),
+		WithTransport(&WebSocketTransport{Binary: true}),
+		WithEncoding(charmap.ISO8859_1),
+		WithQuitTimeout(fakeServerQuitTimeout),
+	)
+	drainConnection(conn)
+	result := openAsync(conn)
+	skipCapNegotiation(srv)
+	if err := <-result; err != nil {
+		t.Fatalf("Open() -> %v", err)
+	}
+	if offered := (<-l.requests).Header.Get("Sec-WebSocket-Protocol"); !strings.HasPrefix(offered, WebSocketBinarySubprotocol) {
+		t.Errorf("client offered %q, expected the binary subprotocol first", offered)
+	}
+	if op := <-l.opcodes; op != webSocketOpBinary {
+		t.Errorf("client sent frames with opcode %#x, expected binary frames", op)
+	}
+	conn.Out() <- NewMessageWithoutPrefix(PrivmsgCommand, "alice", "très bien")
+	srv.expect("PRIVMSG alice :tr\xe8s bien")
+	conn.Close()
+	conn.Wait()
+}
+
+func TestWebSocketConn_ControlFrames(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	ws := &webSocketConn{Conn: client, reader: bufio.NewReader(client), opcode: webSocketOpText}
+	defer client.Close()
+	go func() {
+		writeWebSocketFrame(server, webSocketOpPing, []byte("hello"), false)
+		// A fragmented message, whose second fragment tries to inject another message.
+		server.Write([]byte{0x01, 0x05})
+		server.Write([]byte("PING "))
+		writeWebSocketFrame(server, webSocketOpContinuation, []byte(":irc\r\nQUIT"), false)
+	}()
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(ws).ReadString('\n')
+		lines <- line
+	}()
+	// The ping frame has to be answered with a (masked) pong frame first.
+	fin, op, payload, err := readWebSocketFrame(server)
+	if err != nil || !fin || op != webSocketOpPong || string(payload) != "hello" {
+		t.Fatalf("readWebSocketFrame() -> %v, %#x, %q, %v, expected a pong frame", fin, op, payload, err)
+	}
+	if line := <-lines; line != "PING :irc\r\n" {
+		t.Errorf("Read() -> %q, expected: %q", line, "PING :irc\r\n")
+	}
+}
+
+func TestWebSocketTransport_Rejected(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		sock, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer sock.Close()
+		http.ReadRequest(bufio.NewReader(sock))
+		sock.Write([]byte("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"))
+	}()
+	server := Server{Hostname: "127.0.0.1", Port: uint(l.Addr().(*net.TCPAddr).Port)}
+	if _, err := (&WebSocketTransport{}).Dial(context.Background(), server); err == nil {
+		t.Error("Dial() should fail if the server rejects the handshake")
+	}
+}